@@ -1,6 +1,9 @@
 package app
 
 import (
+	"strings"
+
+	"gioui.org/io/key"
 	"gioui.org/layout"
 )
 
@@ -84,3 +87,23 @@ type WindowNavigator interface {
 	// 如果显示模态框，则还应调用模态框的 Handle() 方法。
 	Reload()
 }
+
+// KeyEventHandler 应该由想要处理按键事件的 Page 和 Modal 来实现。
+type KeyEventHandler interface {
+	// KeysToHandle 返回页面或模态框需要处理的按键集合，只有匹配的按键事件才会传递给 HandleKeyPress。
+	// 返回空集合表示不处理任何按键。
+	KeysToHandle() key.Set
+	// HandleKeyPress 在 KeysToHandle 中的某个按键被按下时调用。
+	HandleKeyPress(evt *key.Event)
+}
+
+// CombineKeys 将多个按键集合合并为一个，忽略空集合。
+func CombineKeys(sets ...key.Set) key.Set {
+	var combined []string
+	for _, set := range sets {
+		if set != "" {
+			combined = append(combined, string(set))
+		}
+	}
+	return key.Set(strings.Join(combined, "|"))
+}
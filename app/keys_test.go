@@ -0,0 +1,49 @@
+package app
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+)
+
+type testKeyPage struct {
+	*testPage
+	keys    key.Set
+	pressed []string
+}
+
+func (tPage *testKeyPage) KeysToHandle() key.Set {
+	return tPage.keys
+}
+
+func (tPage *testKeyPage) HandleKeyPress(evt *key.Event) {
+	tPage.pressed = append(tPage.pressed, evt.Name)
+}
+
+func TestCombineKeys(t *testing.T) {
+	if got := CombineKeys("", "Short-F", "", key.NameEscape); got != "Short-F|"+key.NameEscape {
+		t.Errorf("unexpected combined keys %q", got)
+	}
+	if got := CombineKeys("", ""); got != "" {
+		t.Errorf("expected no keys, got %q", got)
+	}
+}
+
+func TestMasterPageKeys(t *testing.T) {
+	masterPage := NewMasterPage("master")
+	if keys := masterPage.KeysToHandle(); keys != "" {
+		t.Errorf("expected no keys without sub pages, got %q", keys)
+	}
+	masterPage.HandleKeyPress(&key.Event{Name: "F"})
+
+	subPage := &testKeyPage{testPage: newTestPage("sub", t.Logf), keys: "Short-F"}
+	masterPage.subPages.Push(subPage, masterPage)
+	if keys := masterPage.KeysToHandle(); keys != "Short-F" {
+		t.Errorf("expected keys of sub page, got %q", keys)
+	}
+	masterPage.HandleKeyPress(&key.Event{Name: "F", Modifiers: key.ModShortcut})
+	masterPage.HandleKeyPress(&key.Event{Name: "G", Modifiers: key.ModShortcut})
+	if len(subPage.pressed) != 1 || subPage.pressed[0] != "F" {
+		t.Errorf("expected only handled keys to be forwarded, got %v", subPage.pressed)
+	}
+}
@@ -1,5 +1,7 @@
 package app
 
+import "gioui.org/io/key"
+
 // MasterPage  是一个可以显示子页面的页面.
 // 它是 GenericPageModal 的扩展，提供对用于显示 MasterPage 的 Window 或 PageNavigator 的访问.
 // MasterPage 的 ParentNavigator 通常在 MasterPage 被 WindowNavigator 或 PageNavigator 推入显示窗口时设置.
@@ -76,3 +78,21 @@ func (masterPage *MasterPage) CloseAllPages() {
 	masterPage.subPages.Reset()
 	masterPage.ParentWindow().Reload()
 }
+
+// KeysToHandle 返回当前子页面需要处理的按键集合，如果当前子页面不处理按键事件，则返回空集合。
+// Part of the KeyEventHandler interface.
+func (masterPage *MasterPage) KeysToHandle() key.Set {
+	if handler, ok := masterPage.CurrentPage().(KeyEventHandler); ok {
+		return handler.KeysToHandle()
+	}
+	return ""
+}
+
+// HandleKeyPress 将按键事件传递给当前子页面，前提是该子页面处理这个按键。
+// Part of the KeyEventHandler interface.
+func (masterPage *MasterPage) HandleKeyPress(evt *key.Event) {
+	handler, ok := masterPage.CurrentPage().(KeyEventHandler)
+	if ok && handler.KeysToHandle().Contains(evt.Name, evt.Modifiers) {
+		handler.HandleKeyPress(evt)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"gioui.org/widget"
 	"wechat_ui/ui/assets"
 	"wechat_ui/ui/pkg/widget/material"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/v"
 	"wechat_ui/ui/values"
 )
@@ -21,6 +22,9 @@ type NavHandler struct {
 	ImageInactive *v.Image
 	Title         string
 	PageID        string
+	// Badge, if set, returns the count presented over the icon, such as
+	// the number of unread messages. Nothing is presented for zero.
+	Badge func() int
 }
 
 type NavDrawer struct {
@@ -55,7 +59,7 @@ func (nd *NavDrawer) Layout(gtx C) D {
 	gtx.Constraints.Min.Y = gtx.Constraints.Max.Y
 
 	// 填充背景色
-	v.Fill(gtx, values.DarkGray)
+	v.Fill(gtx, theme.Current().Nav)
 
 	return layout.Flex{
 		Axis:    nd.axis,
@@ -90,7 +94,16 @@ func (nd *NavDrawer) Layout(gtx C) D {
 				}
 				return item.Clickable.Button.Layout(gtx, func(gtx C) D {
 					return layout.UniformInset(values.MarginPadding10).Layout(gtx, func(gtx C) D {
-						return nd.direction.Layout(gtx, img.Layout20dp)
+						return nd.direction.Layout(gtx, func(gtx C) D {
+							if item.Badge == nil {
+								return img.Layout20dp(gtx)
+							}
+							count := item.Badge()
+							if count == 0 {
+								return img.Layout20dp(gtx)
+							}
+							return material.Badge(assets.Theme, count).Corner(gtx, img.Layout20dp)
+						})
 					})
 				})
 			})
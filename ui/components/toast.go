@@ -0,0 +1,204 @@
+package components
+
+import (
+	"sync"
+	"time"
+	"wechat_ui/ui/assets"
+	"wechat_ui/ui/pkg/notify"
+	"wechat_ui/ui/theme"
+	"wechat_ui/ui/v"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+const (
+	// ToastDuration is how long a toast is presented before it is
+	// dismissed.
+	ToastDuration = 5 * time.Second
+	// MaxToasts is how many toasts are stacked at most. Older toasts are
+	// dismissed to make room for new ones.
+	MaxToasts = 3
+)
+
+// ToastWidth is the width of toasts.
+var ToastWidth = unit.Dp(300)
+
+var closeIcon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationClose)
+	return icon
+}()
+
+// Toast presents a notification in the window, until it expires or is
+// dismissed.
+type Toast struct {
+	notify.Notification
+	// Expires is when the toast is dismissed.
+	Expires time.Time
+	// open tracks clicks on the toast, and close clicks on the button
+	// dismissing it.
+	open, close widget.Clickable
+}
+
+// Toasts presents notifications in the top right corner of the window,
+// above every page, the most recent first. Push may be called from any
+// goroutine.
+type Toasts struct {
+	// Invalidate is called once a toast is pushed, to present it.
+	Invalidate func()
+	// Open is called with the tag of a toast when it is clicked, if set.
+	Open func(tag string)
+
+	mu   sync.Mutex
+	list []*Toast
+}
+
+// NewToasts returns the toasts of a window, laid out again by invalidate.
+func NewToasts(invalidate func()) *Toasts {
+	return &Toasts{Invalidate: invalidate}
+}
+
+// Push presents n until ToastDuration elapsed, replacing the toast of the
+// same tag, if any, and dismissing the oldest toasts beyond MaxToasts.
+func (ts *Toasts) Push(n notify.Notification) {
+	t := &Toast{Notification: n, Expires: time.Now().Add(ToastDuration)}
+	ts.mu.Lock()
+	list := []*Toast{t}
+	for _, other := range ts.list {
+		if other.Tag != t.Tag && len(list) < MaxToasts {
+			list = append(list, other)
+		}
+	}
+	ts.list = list
+	ts.mu.Unlock()
+	if ts.Invalidate != nil {
+		ts.Invalidate()
+	}
+}
+
+// Dismiss stops presenting t.
+func (ts *Toasts) Dismiss(t *Toast) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for ii := range ts.list {
+		if ts.list[ii] == t {
+			ts.list = append(ts.list[:ii], ts.list[ii+1:]...)
+			return
+		}
+	}
+}
+
+// List returns the toasts presented, the most recent first.
+func (ts *Toasts) List() []*Toast {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]*Toast(nil), ts.list...)
+}
+
+// expire dismisses the toasts expired at now. It returns when the next
+// toast expires, zero if none are left.
+func (ts *Toasts) expire(now time.Time) time.Time {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	var next time.Time
+	list := ts.list[:0]
+	for _, t := range ts.list {
+		if !now.Before(t.Expires) {
+			continue
+		}
+		list = append(list, t)
+		if next.IsZero() || t.Expires.Before(next) {
+			next = t.Expires
+		}
+	}
+	ts.list = list
+	return next
+}
+
+// Layout lays out the toasts stacked in the top right corner. Clicking a
+// toast dismisses it and passes its tag to Open.
+func (ts *Toasts) Layout(gtx C) D {
+	for _, t := range ts.List() {
+		if t.close.Clicked() {
+			ts.Dismiss(t)
+		}
+		if t.open.Clicked() {
+			ts.Dismiss(t)
+			if ts.Open != nil {
+				ts.Open(t.Tag)
+			}
+		}
+	}
+	if next := ts.expire(gtx.Now); !next.IsZero() {
+		op.InvalidateOp{At: next}.Add(gtx.Ops)
+	}
+	list := ts.List()
+	if len(list) == 0 {
+		return D{}
+	}
+	return layout.NE.Layout(gtx, func(gtx C) D {
+		return layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx C) D {
+			if width := gtx.Dp(ToastWidth); width < gtx.Constraints.Max.X {
+				gtx.Constraints.Max.X = width
+			}
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			toasts := make([]layout.FlexChild, 0, 2*len(list))
+			for _, t := range list {
+				t := t
+				if len(toasts) > 0 {
+					toasts = append(toasts, layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout))
+				}
+				toasts = append(toasts, layout.Rigid(t.Layout))
+			}
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, toasts...)
+		})
+	})
+}
+
+// Layout lays out the title of the toast above its body, beside the button
+// dismissing it.
+func (t *Toast) Layout(gtx C) D {
+	th, palette := assets.Theme, theme.Current()
+	card := v.Card{Radius: v.Radius(8)}
+	return v.NewShadow().Layout(gtx, func(gtx C) D {
+		return card.Layout(gtx, func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return t.open.Layout(gtx, func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Constraints.Max.X
+						return layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx C) D {
+							return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+								layout.Rigid(func(gtx C) D {
+									lbl := material.Body1(th, t.Title)
+									lbl.Color = palette.Text
+									lbl.Font.Weight = font.Bold
+									lbl.MaxLines = 1
+									return lbl.Layout(gtx)
+								}),
+								layout.Rigid(func(gtx C) D {
+									lbl := material.Body2(th, t.Body)
+									lbl.Color = palette.Hint
+									lbl.MaxLines = 2
+									return lbl.Layout(gtx)
+								}),
+							)
+						})
+					})
+				}),
+				layout.Rigid(func(gtx C) D {
+					btn := material.IconButton(th, &t.close, closeIcon, "Dismiss")
+					btn.Background = palette.Surface
+					btn.Color = palette.Hint
+					btn.Size = unit.Dp(16)
+					btn.Inset = layout.UniformInset(unit.Dp(8))
+					return btn.Layout(gtx)
+				}),
+			)
+		})
+	})
+}
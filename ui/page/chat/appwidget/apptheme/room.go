@@ -1,6 +1,7 @@
 package apptheme
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"time"
@@ -15,6 +16,9 @@ import (
 	matchat "wechat_ui/ui/pkg/widget/material"
 )
 
+// markColor highlights the marks prefixing the summary of a room.
+var markColor = color.NRGBA{R: 0xfa, G: 0x51, B: 0x51, A: 0xff}
+
 // RoomStyle lays out a room select card.
 type RoomStyle struct {
 	*appwidget.Room
@@ -24,6 +28,19 @@ type RoomStyle struct {
 	TimeStamp material.LabelStyle
 	Indicator color.NRGBA
 	Overlay   color.NRGBA
+	// Badge presents the number of unread messages, if any, over the
+	// corner of the image.
+	Badge *matchat.BadgeStyle
+	// Mention, if its text is set, prefixes the summary to mark unread
+	// messages mentioning the local user.
+	Mention material.LabelStyle
+	// Draft, if its text is set, prefixes the summary to mark it as the
+	// unsent content of the editor.
+	Draft material.LabelStyle
+	// Pinned is the background of pinned rooms.
+	Pinned color.NRGBA
+	// Menu, if set, is shown when the room is right-clicked.
+	Menu *component.MenuStyle
 }
 
 // RoomConfig configures room item display.
@@ -36,16 +53,71 @@ type RoomConfig struct {
 	Content string
 	// SentAt timestamp of the latest message.
 	SentAt time.Time
+	// Unread is the number of messages the local user has not read.
+	Unread int
+	// Mentions is the number of unread messages that mention the local
+	// user. They are marked ahead of the summary, and muted rooms present
+	// their unread count when mentioned.
+	Mentions int
+	// Draft is the unsent content of the editor, presented in place of the
+	// latest message if set.
+	Draft string
+	// Typing describes who is typing in the room, presented in place of the
+	// latest message if set.
+	Typing string
+	// Pinned rooms are presented over a darker background.
+	Pinned bool
+	// Muted rooms present their unread messages with a dot rather than a
+	// count.
+	Muted bool
+	// Menu, if set, is the context menu of the room.
+	Menu *component.MenuState
 }
 
 // Room creates a style type that can lay out the data for a room.
 func Room(th *material.Theme, interact *appwidget.Room, room *RoomConfig) RoomStyle {
 	interact.Image.Cache(room.Image)
+	var badge *matchat.BadgeStyle
+	switch {
+	case room.Unread > 0 && room.Muted && room.Mentions == 0:
+		b := matchat.Dot(th)
+		badge = &b
+	case room.Unread > 0:
+		b := matchat.Badge(th, room.Unread)
+		badge = &b
+	}
+	var menu *component.MenuStyle
+	if room.Menu != nil {
+		m := component.Menu(th, room.Menu)
+		menu = &m
+	}
+	summary := material.Label(th, unit.Sp(12), room.Content)
+	var mention, draft material.LabelStyle
+	switch {
+	case room.Mentions == 1:
+		mention = material.Label(th, unit.Sp(12), "[@me] ")
+	case room.Mentions > 1:
+		mention = material.Label(th, unit.Sp(12), fmt.Sprintf("[@me ×%d] ", room.Mentions))
+	}
+	mention.Color = markColor
+	switch {
+	case room.Draft != "":
+		summary.Text = room.Draft
+		draft = material.Label(th, unit.Sp(12), "[Draft] ")
+		draft.Color = markColor
+	case room.Typing != "":
+		summary.Text = room.Typing
+		summary.Color = th.ContrastBg
+	}
+	var pinned color.NRGBA
+	if room.Pinned {
+		pinned = component.WithAlpha(th.Fg, 20)
+	}
 	return RoomStyle{
 		Room: interact,
 		// TODO(jfm): name could use bold text.
 		Name:      material.Label(th, unit.Sp(14), room.Name),
-		Summary:   material.Label(th, unit.Sp(12), room.Content),
+		Summary:   summary,
 		TimeStamp: material.Label(th, unit.Sp(12), room.SentAt.Local().Format("15:04")),
 		Image: matchat.Image{
 			Image: widget.Image{
@@ -58,6 +130,11 @@ func Room(th *material.Theme, interact *appwidget.Room, room *RoomConfig) RoomSt
 		},
 		Indicator: th.ContrastBg,
 		Overlay:   component.WithAlpha(th.Fg, 50),
+		Badge:     badge,
+		Mention:   mention,
+		Draft:     draft,
+		Pinned:    pinned,
+		Menu:      menu,
 	}
 }
 
@@ -66,6 +143,9 @@ func (room RoomStyle) Layout(gtx C) D {
 		surface = func(gtx C, w layout.Widget) D { return w(gtx) }
 		dims    layout.Dimensions
 	)
+	if room.Pinned.A > 0 {
+		surface = chatlayout.Background(room.Pinned).Layout
+	}
 	if room.Active {
 		surface = chatlayout.Background(room.Overlay).Layout
 		//TODO: 在左边添加一条选中的竖线
@@ -90,11 +170,14 @@ func (room RoomStyle) Layout(gtx C) D {
 					Alignment: layout.Middle,
 				}.Layout(
 					gtx,
-					// 头像
+					// 头像 未读数
 					layout.Rigid(func(gtx C) D {
 						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(40))
 						gtx.Constraints.Min.Y = gtx.Dp(unit.Dp(40))
-						return room.Image.Layout(gtx)
+						if room.Badge == nil {
+							return room.Image.Layout(gtx)
+						}
+						return room.Badge.Corner(gtx, room.Image.Layout)
 					}),
 					// 间隔
 					layout.Rigid(layout.Spacer{Width: unit.Dp(5)}.Layout),
@@ -120,9 +203,19 @@ func (room RoomStyle) Layout(gtx C) D {
 							}),
 							layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
 
-							// 最新一条信息
+							// 最新一条信息 或草稿, 前面标记 @我 和草稿
 							layout.Rigid(func(gtx C) D {
-								return component.TruncatingLabelStyle(room.Summary).Layout(gtx)
+								var children []layout.FlexChild
+								for _, mark := range []material.LabelStyle{room.Mention, room.Draft} {
+									if mark.Text != "" {
+										children = append(children, layout.Rigid(mark.Layout))
+									}
+								}
+								if len(children) == 0 {
+									return component.TruncatingLabelStyle(room.Summary).Layout(gtx)
+								}
+								children = append(children, layout.Flexed(1, component.TruncatingLabelStyle(room.Summary).Layout))
+								return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
 							}),
 						)
 					}),
@@ -130,5 +223,13 @@ func (room RoomStyle) Layout(gtx C) D {
 			})
 		})
 	})
+	if room.Menu != nil {
+		// 右键菜单
+		gtx.Constraints.Min = dims.Size
+		room.ContextArea.Layout(gtx, func(gtx C) D {
+			gtx.Constraints.Min = image.Point{}
+			return room.Menu.Layout(gtx)
+		})
+	}
 	return dims
 }
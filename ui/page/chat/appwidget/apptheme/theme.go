@@ -4,6 +4,8 @@ import (
 	"github.com/lucasb-eyer/go-colorful"
 	"image/color"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/theme"
+	"wechat_ui/ui/values"
 
 	"gioui.org/layout"
 	"gioui.org/unit"
@@ -22,29 +24,6 @@ var (
 	DefaultAvatarSize      = unit.Dp(24)
 )
 
-var (
-	Light = Palette{
-		Error:         rgb(0xB00020),
-		Surface:       rgb(0xFFFFFF),
-		Bg:            rgb(0xDCDCDC),
-		BgSecondary:   rgb(0xEBEBEB),
-		OnError:       rgb(0xFFFFFF),
-		OnSurface:     rgb(0x000000),
-		OnBg:          rgb(0x000000),
-		OnBgSecondary: rgb(0x000000),
-	}
-	Dark = Palette{
-		Error:         rgb(0xB00020),
-		Surface:       rgb(0x222222),
-		Bg:            rgb(0x000000),
-		BgSecondary:   rgb(0x444444),
-		OnError:       rgb(0xFFFFFF),
-		OnSurface:     rgb(0xFFFFFF),
-		OnBg:          rgb(0xEEEEEE),
-		OnBgSecondary: rgb(0xFFFFFF),
-	}
-)
-
 // ToNRGBA converts a colorful.Color to the nearest representable color.NRGBA.
 func ToNRGBA(c colorful.Color) color.NRGBA {
 	r, g, b, a := c.RGBA()
@@ -83,20 +62,37 @@ type Palette struct {
 	OnBgSecondary color.NRGBA
 }
 
+// NewPalette returns the semantic colors matching the palette p of the
+// application.
+func NewPalette(p values.Palette) Palette {
+	return Palette{
+		Error:         rgb(0xB00020),
+		OnError:       values.White,
+		Surface:       p.Surface,
+		OnSurface:     p.Text,
+		Bg:            p.Bg,
+		OnBg:          p.Text,
+		BgSecondary:   p.Hover,
+		OnBgSecondary: p.Text,
+	}
+}
+
 // UserColorData tracks both a color and its luminance.
 type UserColorData struct {
 	color.NRGBA
 	Luminance float64
 }
 
-// NewTheme instantiates a theme using the provided fonts.
+// NewTheme instantiates a theme using the provided fonts. It colors a copy
+// of assets.Theme with the palette the application is presented with.
 func NewTheme() *Theme {
+	mth := *assets.Theme
 	th := Theme{
-		Theme:      assets.Theme,
+		Theme:      &mth,
 		UserColors: make(map[string]UserColorData),
 		AvatarSize: DefaultAvatarSize,
 	}
-	th.UsePalette(Light)
+	th.UsePalette(NewPalette(theme.Current()))
 	return &th
 }
 
@@ -107,15 +103,6 @@ func (t *Theme) UsePalette(p Palette) {
 	t.Theme.Fg = t.Palette.OnBg
 }
 
-// Toggle the active theme between pre-configured Light and Dark palettes.
-func (t *Theme) Toggle() {
-	if t.Palette == Light {
-		t.UsePalette(Dark)
-	} else {
-		t.UsePalette(Light)
-	}
-}
-
 // UserColor returns a color for the provided username. It will choose a
 // new color if the username is new.
 func (t *Theme) UserColor(username string) UserColorData {
@@ -127,7 +114,7 @@ func (t *Theme) UserColor(username string) UserColorData {
 	uc := UserColorData{
 		NRGBA: ToNRGBA(c),
 	}
-	uc.Luminance = (0.299*float64(uc.NRGBA.R) + 0.587*float64(uc.NRGBA.G) + 0.114*float64(uc.NRGBA.B)) / 255
+	uc.Luminance = luminance(uc.NRGBA)
 	t.UserColors[username] = uc
 	return uc
 }
@@ -138,7 +125,7 @@ func (t *Theme) LocalUserColor() UserColorData {
 	c := t.Palette.Surface
 	return UserColorData{
 		NRGBA:     c,
-		Luminance: (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255,
+		Luminance: luminance(c),
 	}
 }
 
@@ -149,12 +136,13 @@ func (t *Theme) LocalUserColor() UserColorData {
 //
 // Note this will depend on the specific palette in question, and may not be a
 // good generalization particularly for low-contrast palettes.
-func (t *Theme) Contrast(luminance float64) color.NRGBA {
+func (t *Theme) Contrast(lum float64) color.NRGBA {
 	var (
-		contrast = luminance < 0.5
+		contrast = lum < 0.5
 	)
-	if t.Palette == Dark {
-		contrast = luminance > 0.5
+	// Dark palettes have a dark background.
+	if luminance(t.Palette.Bg) < 0.5 {
+		contrast = lum > 0.5
 	}
 	if contrast {
 		return t.Palette.Bg
@@ -162,6 +150,11 @@ func (t *Theme) Contrast(luminance float64) color.NRGBA {
 	return t.Palette.OnBg
 }
 
+// luminance of c, between 0 and 1.
+func luminance(c color.NRGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
+
 func rgb(c uint32) color.NRGBA {
 	return argb(0xff000000 | c)
 }
@@ -2,6 +2,7 @@ package appwidget
 
 import (
 	"gioui.org/widget"
+	"gioui.org/x/component"
 	chatwidget "wechat_ui/ui/pkg/widget"
 )
 
@@ -10,4 +11,6 @@ type Room struct {
 	widget.Clickable
 	Image  chatwidget.CachedImage
 	Active bool
+	// ContextArea holds the clicks state for the right-click context menu.
+	ContextArea component.ContextArea
 }
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"wechat_ui/ui/page/chat/model"
+)
+
+// MaxAttachmentSize is the size, in bytes, of the largest file that can be
+// attached to a message.
+const MaxAttachmentSize = 8 << 20
+
+// DescribeFile returns the attachment describing the local file at path.
+// The attachment refers to the file with a file:// URL until it is
+// uploaded with Backend.Attach.
+func DescribeFile(path string) (model.Attachment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return model.Attachment{}, fmt.Errorf("describing file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return model.Attachment{}, fmt.Errorf("describing file: %w", err)
+	}
+	if info.IsDir() {
+		return model.Attachment{}, fmt.Errorf("describing file: %s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return model.Attachment{}, fmt.Errorf("describing file: %s exceeds %d bytes", path, MaxAttachmentSize)
+	}
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(path)))
+	if mimeType == "" {
+		// Sniff the content when the extension is unknown.
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return model.Attachment{}, fmt.Errorf("describing file: %w", err)
+		}
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(head[:n]))
+	}
+	return model.Attachment{
+		Name: filepath.Base(path),
+		Size: info.Size(),
+		MIME: mimeType,
+		URL:  FileURL(path),
+	}, nil
+}
+
+// FileURL returns the file:// URL of the local file at path.
+func FileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		// Windows paths start with a volume name.
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// LocalPath returns the path of the local file a file:// URL refers to, and
+// whether rawURL is such a URL.
+func LocalPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(p), true
+}
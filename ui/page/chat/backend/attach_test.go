@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDescribeFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	type testcase struct {
+		name string
+		path string
+		mime string
+	}
+	for _, tc := range []testcase{
+		{name: "by extension", path: write("notes.txt", []byte("hello")), mime: "text/plain"},
+		{name: "by content", path: write("picture", png), mime: "image/png"},
+		{name: "unknown", path: write("blob", []byte{0, 1, 2}), mime: "application/octet-stream"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := DescribeFile(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, _ := os.Stat(tc.path)
+			if a.Name != filepath.Base(tc.path) || a.Size != info.Size() || a.MIME != tc.mime {
+				t.Errorf("unexpected attachment %+v", a)
+			}
+			if path, ok := LocalPath(a.URL); !ok || path != tc.path {
+				t.Errorf("expected URL of %s, got %s", tc.path, a.URL)
+			}
+		})
+	}
+	if _, err := DescribeFile(dir); err == nil {
+		t.Errorf("expected error describing a directory")
+	}
+	if _, err := DescribeFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected error describing a missing file")
+	}
+	if _, ok := LocalPath("https://example.com/a.png"); ok {
+		t.Errorf("expected https URL not to be local")
+	}
+}
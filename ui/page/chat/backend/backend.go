@@ -0,0 +1,193 @@
+/*
+Package backend defines the contract between the chat interface and the
+source of its messages, along with a demo implementation that generates
+fake data.
+*/
+package backend
+
+import (
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// Backend is the facade to the business api of a chat service.
+// It is the source of truth for rooms and messages; the UI only ever
+// presents what the Backend reports.
+//
+// Methods may block on I/O and should not be called from the goroutine
+// that is performing layout.
+type Backend interface {
+	// Users returns the directory of known users.
+	Users() *model.Users
+	// Local returns the user this client acts on behalf of.
+	Local() *model.User
+	// Rooms lists the rooms visible to the local user, along with their
+	// members and the number of messages in each that the local user has
+	// not read.
+	Rooms() []*model.Room
+	// CreateRoom returns the room with the given name, creating it if it
+	// does not exist yet. Created rooms are included in later calls to
+	// Rooms.
+	CreateRoom(name string) (*model.Room, error)
+	// Load fetches message history for the named room. It fulfills the
+	// list.Loader contract, and is invoked concurrently by the room's
+	// list.Manager.
+	Load(room string, dir list.Direction, relativeTo list.Serial) ([]list.Element, bool)
+	// LoadAround fetches the message history of the named room surrounding
+	// the message with the provided serial. It fulfills the
+	// list.AroundLoader contract, and lets the room's list.Manager jump to
+	// messages far from the ones it has loaded.
+	LoadAround(room string, serial list.Serial) ([]list.Element, bool, bool)
+	// Compose prepares a message with the given content from the local
+	// user to the named room. The message is assigned its serial and is
+	// pending until it is passed to Send.
+	Compose(room, content string) model.Message
+	// Send delivers a composed message to the named room, returning it as
+	// stored by the backend with its status advanced to sent. If delivery
+	// fails the backend keeps the message with a failed status, until it
+	// is removed with Delete.
+	Send(room string, msg model.Message) (model.Message, error)
+	// Attach uploads the local file described by an attachment, as
+	// returned by DescribeFile, so that it can be sent to the named room.
+	// It returns the attachment to set on the message, which refers to the
+	// uploaded contents.
+	Attach(room string, a model.Attachment) (model.Attachment, error)
+	// Delete removes the message with the provided serial from the named
+	// room.
+	Delete(room string, serial list.Serial) error
+	// MarkRead records that the local user has read the named room up to
+	// and including the message with the provided serial. It returns the
+	// number of messages of the room that remain unread.
+	MarkRead(room string, serial list.Serial) (int, error)
+	// Edit replaces the content of the message with the provided serial,
+	// sent by the local user, returning the message as stored by the
+	// backend. Messages can only be edited for a while after they are
+	// sent.
+	Edit(room string, serial list.Serial, content string) (model.Message, error)
+	// Recall withdraws the message with the provided serial, sent by the
+	// local user, returning the message as stored by the backend: it
+	// remains in the room without its contents, as a notice that it was
+	// recalled. Messages can only be recalled for a while after they are
+	// sent.
+	Recall(room string, serial list.Serial) (model.Message, error)
+	// React adds the reaction of the local user with emoji to the message
+	// with the provided serial, or removes it if add is false. It returns
+	// the message as stored by the backend.
+	React(room string, serial list.Serial, emoji string, add bool) (model.Message, error)
+	// ChangeGroup applies a change to the members or settings of the named
+	// room on behalf of the local user. It returns the updated group, along
+	// with the notice recording the change in the room.
+	ChangeGroup(room string, change model.GroupChange) (model.Group, model.Message, error)
+	// Typing tells the other participants of the named room whether the
+	// local user is composing a message. They expire the signal after a
+	// while unless it is renewed.
+	Typing(room string, composing bool) error
+	// Subscribe returns a channel on which the backend pushes events that
+	// originate outside of this client, such as messages sent by other
+	// users. Implementations may return the same channel on every call.
+	Subscribe() <-chan Event
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+// Event is a change pushed by the backend.
+type Event interface {
+	// RoomName returns the name of the room the event pertains to.
+	RoomName() string
+}
+
+// MessageEvent reports a new message in a room.
+type MessageEvent struct {
+	Room    string
+	Message model.Message
+}
+
+// RoomName returns the name of the room the message was sent to.
+func (e MessageEvent) RoomName() string {
+	return e.Room
+}
+
+// DeleteEvent reports the removal of a message from a room.
+type DeleteEvent struct {
+	Room   string
+	Serial list.Serial
+}
+
+// RoomName returns the name of the room the message was removed from.
+func (e DeleteEvent) RoomName() string {
+	return e.Room
+}
+
+// ComposingEvent reports that a user started or stopped composing a
+// message in a room.
+type ComposingEvent struct {
+	Room      string
+	User      string
+	Composing bool
+}
+
+// RoomName returns the name of the room the user is composing in.
+func (e ComposingEvent) RoomName() string {
+	return e.Room
+}
+
+// StatusEvent reports a change in the delivery status of a message sent
+// by the local user. Message holds the updated message.
+type StatusEvent struct {
+	Room    string
+	Message model.Message
+}
+
+// RoomName returns the name of the room the message was sent to.
+func (e StatusEvent) RoomName() string {
+	return e.Room
+}
+
+// ReactionEvent reports a change in the reactions to a message. Message
+// holds the updated message.
+type ReactionEvent struct {
+	Room    string
+	Message model.Message
+}
+
+// RoomName returns the name of the room the message was sent to.
+func (e ReactionEvent) RoomName() string {
+	return e.Room
+}
+
+// UpdateEvent reports that the sender of a message edited or recalled it.
+// Message holds the updated message.
+type UpdateEvent struct {
+	Room    string
+	Message model.Message
+}
+
+// RoomName returns the name of the room the message was sent to.
+func (e UpdateEvent) RoomName() string {
+	return e.Room
+}
+
+// ReadEvent reports that a user has read a room up to and including the
+// message with the provided serial.
+type ReadEvent struct {
+	Room   string
+	User   string
+	Serial list.Serial
+}
+
+// RoomName returns the name of the room that was read.
+func (e ReadEvent) RoomName() string {
+	return e.Room
+}
+
+// GroupEvent reports a change to the members or settings of a room made by
+// another user. The notice recording it is reported by a MessageEvent.
+type GroupEvent struct {
+	Room  string
+	Group model.Group
+}
+
+// RoomName returns the name of the room that changed.
+func (e GroupEvent) RoomName() string {
+	return e.Room
+}
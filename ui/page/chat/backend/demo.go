@@ -0,0 +1,700 @@
+package backend
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+	"wechat_ui/ui/page/chat/gen"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/store"
+	"wechat_ui/ui/pkg/list"
+
+	lorem "github.com/drhodes/golorem"
+)
+
+// DemoConfig configures a DemoBackend.
+type DemoConfig struct {
+	// Latency specifies maximum latency (in millis) to simulate.
+	Latency int
+	// LoadSize specifies maximum number of items to load at a time.
+	LoadSize int
+	// HistorySize specifies how many historic messages to generate per room.
+	HistorySize int
+	// FailureRate is the probability in [0, 1] that sending a message
+	// fails.
+	FailureRate float64
+	// StorePath is the file messages are persisted to. Empty keeps them
+	// in memory only.
+	StorePath string
+	// RecallWindow is how long after sending a message the local user
+	// can edit or recall it. Defaults to model.DefaultRecallWindow.
+	RecallWindow time.Duration
+	// FetchImage fetches an image of the given size. Defaults to
+	// downloading random images.
+	FetchImage func(image.Point) image.Image
+}
+
+// DemoBackend implements Backend with generated fake data.
+// It spins up a simulated actor per user that periodically composes and
+// sends lorem ipsum into random rooms.
+type DemoBackend struct {
+	// SimulateLatency is the maximum latency in milliseconds to
+	// simulate on loads.
+	SimulateLatency int
+	// FailureRate is the probability that sending a message fails.
+	FailureRate float64
+	// RecallWindow is how long after sending a message the local user
+	// can edit or recall it.
+	RecallWindow time.Duration
+	generator    *gen.Generator
+	users        *model.Users
+	local        *model.User
+	rooms        *model.Rooms
+	// messages holds the message history of every room.
+	messages *store.Store
+	events   chan Event
+	simulate sync.Once
+	// stop is closed by Close, to stop the simulated activity.
+	stop      chan struct{}
+	closeOnce sync.Once
+	// rosterMu serializes the changes to the roster, and guards groups.
+	rosterMu sync.Mutex
+	// groups holds the members and settings of each room. Rooms without
+	// an entry are open.
+	groups map[string]model.Group
+}
+
+var _ Backend = (*DemoBackend)(nil)
+
+// rosterKey is the store metadata key of the demo roster.
+const rosterKey = "demo.roster"
+
+// roster is the generated demo data that must survive restarts for the
+// persisted history to remain meaningful.
+type roster struct {
+	Users  []model.User
+	Rooms  []string
+	Local  string
+	Groups map[string]model.Group
+}
+
+// NewDemo constructs a DemoBackend. The generated users and rooms are
+// persisted alongside the messages, and are reused when the store is
+// reopened.
+func NewDemo(conf DemoConfig) (*DemoBackend, error) {
+	if conf.FetchImage == nil {
+		conf.FetchImage = func(sz image.Point) image.Image {
+			img, _ := randomImage(sz)
+			return img
+		}
+	}
+	if conf.HistorySize <= 0 {
+		conf.HistorySize = 100
+	}
+	if conf.RecallWindow <= 0 {
+		conf.RecallWindow = model.DefaultRecallWindow
+	}
+	messages, err := store.Open(conf.StorePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening message store: %w", err)
+	}
+	messages.PageSize = conf.LoadSize
+	d := &DemoBackend{
+		SimulateLatency: conf.Latency,
+		FailureRate:     conf.FailureRate,
+		RecallWindow:    conf.RecallWindow,
+		generator: &gen.Generator{
+			FetchImage: conf.FetchImage,
+		},
+		messages: messages,
+		events:   make(chan Event, 64),
+		stop:     make(chan struct{}),
+		groups:   make(map[string]model.Group),
+	}
+	var r roster
+	found, err := messages.Meta(rosterKey, &r)
+	if err != nil {
+		log.Printf("discarding demo roster: %v", err)
+		found = false
+	}
+	if found {
+		d.restore(r, conf.FetchImage)
+	} else if err := d.generate(conf.HistorySize); err != nil {
+		messages.Close()
+		return nil, err
+	}
+	for _, room := range d.rooms.List() {
+		if latest, ok := messages.Latest(room.Name); ok {
+			d.generator.Resume(latest.Serial())
+		}
+		unread := d.unread(room.Name)
+		room.Unread = len(unread)
+		room.Mentions = mentions(unread, d.local.Name)
+	}
+	return d, nil
+}
+
+// generate the demo data and seed the history of each room.
+func (d *DemoBackend) generate(historySize int) error {
+	var (
+		g     = d.generator
+		rooms = g.GenRooms(3, 10)
+		users = g.GenUsers(10, 30)
+		local = users.Random()
+	)
+	d.rooms, d.users, d.local = rooms, users, local
+	for ii, room := range rooms.List() {
+		room.Group = d.genGroup(ii)
+		d.groups[room.Name] = room.Group
+		for i := 0; i < historySize; i++ {
+			sender, _ := users.Lookup(room.Members[rand.Intn(len(room.Members))].Name)
+			if err := d.messages.Put(room.Name, g.GenHistoricMessage(sender)); err != nil {
+				return fmt.Errorf("seeding history: %w", err)
+			}
+		}
+	}
+	return d.saveRoster()
+}
+
+// genGroup generates the members of the ii-th room: the local user and
+// about half of the other users. The local user owns every other room, and
+// administers some of the rest.
+func (d *DemoBackend) genGroup(ii int) model.Group {
+	var g model.Group
+	role := model.RoleMember
+	switch {
+	case ii%2 == 0:
+		role = model.RoleOwner
+	case ii%3 == 1:
+		role = model.RoleAdmin
+	}
+	g.Members = append(g.Members, model.Member{Name: d.local.Name, Role: role})
+	for _, u := range d.users.List() {
+		if u.Name == d.local.Name || rand.Intn(2) == 0 {
+			continue
+		}
+		m := model.Member{Name: u.Name, Role: model.RoleMember}
+		if role != model.RoleOwner && len(g.Members) == 1 {
+			m.Role = model.RoleOwner
+		}
+		g.Members = append(g.Members, m)
+	}
+	if len(g.Members) == 1 {
+		// Nobody else to own the room.
+		g.Members[0].Role = model.RoleOwner
+	}
+	return g
+}
+
+// saveRoster persists the current users and rooms. It must be called with
+// rosterMu held, unless no other goroutine uses the backend yet.
+func (d *DemoBackend) saveRoster() error {
+	r := roster{Local: d.local.Name, Groups: d.groups}
+	for _, u := range d.users.List() {
+		r.Users = append(r.Users, *u)
+	}
+	for _, room := range d.rooms.List() {
+		r.Rooms = append(r.Rooms, room.Name)
+	}
+	if err := d.messages.SetMeta(rosterKey, r); err != nil {
+		return fmt.Errorf("saving demo roster: %w", err)
+	}
+	return nil
+}
+
+// restore the demo data from a persisted roster.
+func (d *DemoBackend) restore(r roster, fetchImage func(image.Point) image.Image) {
+	d.users = &model.Users{}
+	for _, u := range r.Users {
+		d.users.Add(u)
+	}
+	d.local, _ = d.users.Lookup(r.Local)
+	d.rooms = &model.Rooms{}
+	for name, g := range r.Groups {
+		d.groups[name] = g
+	}
+	for _, name := range r.Rooms {
+		d.rooms.Add(model.Room{
+			Name:  name,
+			Image: fetchImage(image.Pt(64, 64)),
+			Group: d.groups[name],
+		})
+	}
+}
+
+// Users returns the generated users.
+func (d *DemoBackend) Users() *model.Users {
+	return d.users
+}
+
+// Local returns the generated user acting as this client.
+func (d *DemoBackend) Local() *model.User {
+	return d.local
+}
+
+// Rooms returns the generated rooms the local user is a member of.
+func (d *DemoBackend) Rooms() []*model.Room {
+	return d.visibleRooms(d.local.Name)
+}
+
+// visibleRooms returns the rooms that user can see.
+func (d *DemoBackend) visibleRooms(user string) []*model.Room {
+	d.rosterMu.Lock()
+	defer d.rosterMu.Unlock()
+	var rooms []*model.Room
+	for _, room := range d.rooms.List() {
+		if d.groups[room.Name].Visible(user) {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
+// participants returns the names of the users that can see the room, but
+// the excluded one.
+func (d *DemoBackend) participants(room, exclude string) []string {
+	d.rosterMu.Lock()
+	g := d.groups[room]
+	d.rosterMu.Unlock()
+	var names []string
+	for _, u := range d.users.List() {
+		if u.Name != exclude && g.Visible(u.Name) {
+			names = append(names, u.Name)
+		}
+	}
+	return names
+}
+
+// CreateRoom returns the named room, adding an empty one to the roster
+// if it does not exist.
+func (d *DemoBackend) CreateRoom(name string) (*model.Room, error) {
+	d.rosterMu.Lock()
+	defer d.rosterMu.Unlock()
+	if room, ok := d.rooms.Lookup(name); ok {
+		return room, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("creating room: empty name")
+	}
+	d.rooms.Add(model.Room{
+		Name:  name,
+		Image: d.generator.FetchImage(image.Pt(64, 64)),
+	})
+	if err := d.saveRoster(); err != nil {
+		return nil, fmt.Errorf("creating room: %w", err)
+	}
+	room, _ := d.rooms.Lookup(name)
+	return room, nil
+}
+
+// Load loads history from the message store, simulating network latency.
+func (d *DemoBackend) Load(room string, dir list.Direction, relativeTo list.Serial) ([]list.Element, bool) {
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	return d.messages.Load(room, dir, relativeTo)
+}
+
+// LoadAround loads the history surrounding a message from the message
+// store, simulating network latency.
+func (d *DemoBackend) LoadAround(room string, serial list.Serial) ([]list.Element, bool, bool) {
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	return d.messages.LoadAround(room, serial)
+}
+
+// Attach simulates uploading a file. The demo runs in a single process, so
+// the attachment keeps referring to the local file.
+func (d *DemoBackend) Attach(room string, a model.Attachment) (model.Attachment, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Attachment{}, fmt.Errorf("attaching file: unknown room %q", room)
+	}
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	return a, nil
+}
+
+// Compose generates a pending message from the local user.
+func (d *DemoBackend) Compose(room, content string) model.Message {
+	msg := d.generator.GenNewMessage(d.local, content)
+	msg.Read = true
+	return msg
+}
+
+// Send stores a message from the local user, failing at random according
+// to FailureRate. Successfully sent messages are delivered to and read by
+// the simulated users after a while.
+func (d *DemoBackend) Send(room string, msg model.Message) (model.Message, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Message{}, fmt.Errorf("sending message: unknown room %q", room)
+	}
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	if rand.Float64() < d.FailureRate {
+		msg.Status = model.StatusFailed
+		if err := d.messages.Put(room, msg); err != nil {
+			return model.Message{}, fmt.Errorf("sending message: %w", err)
+		}
+		return msg, fmt.Errorf("sending message: simulated failure")
+	}
+	msg.Status = model.StatusSent
+	if err := d.messages.Put(room, msg); err != nil {
+		return model.Message{}, fmt.Errorf("sending message: %w", err)
+	}
+	go d.simulateDelivery(room, msg)
+	return msg, nil
+}
+
+// simulateDelivery advances the status of a sent message as the
+// simulated users receive and read it.
+func (d *DemoBackend) simulateDelivery(room string, msg model.Message) {
+	for _, status := range []model.Status{model.StatusDelivered, model.StatusRead} {
+		if !d.sleep(time.Millisecond * time.Duration(500+rand.Intn(3000))) {
+			return
+		}
+		updated, ok, err := d.messages.Modify(room, msg.Serial(), func(msg *model.Message) bool {
+			if !msg.Status.CanAdvance(status) {
+				return false
+			}
+			msg.Status = status
+			return true
+		})
+		if err != nil {
+			log.Printf("simulating delivery: %v", err)
+			return
+		} else if !ok {
+			// Deleted in the meantime.
+			return
+		}
+		if !d.emit(StatusEvent{Room: room, Message: updated}) {
+			return
+		}
+	}
+}
+
+// send generates a message from user mentioning the named users, and
+// stores it in the room.
+func (d *DemoBackend) send(room, user, content string, mentions ...string) (model.Message, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Message{}, fmt.Errorf("sending message: unknown room %q", room)
+	}
+	u, ok := d.users.Lookup(user)
+	if !ok {
+		return model.Message{}, fmt.Errorf("sending message: unknown user %q", user)
+	}
+	msg := d.generator.GenNewMessage(u, content)
+	msg.Status = model.StatusNone
+	msg.Mentions = mentions
+	if err := d.messages.Put(room, msg); err != nil {
+		return model.Message{}, fmt.Errorf("sending message: %w", err)
+	}
+	return msg, nil
+}
+
+// Delete removes a message from the message store.
+func (d *DemoBackend) Delete(room string, serial list.Serial) error {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return fmt.Errorf("deleting message: unknown room %q", room)
+	}
+	return d.messages.Delete(room, serial)
+}
+
+// MarkRead marks the messages of other users in the room up to and
+// including serial as read by the local user.
+func (d *DemoBackend) MarkRead(room string, serial list.Serial) (int, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return 0, fmt.Errorf("marking room read: unknown room %q", room)
+	}
+	count := 0
+	for _, unread := range d.unread(room) {
+		if model.SerialLessThan(serial, unread.Serial()) {
+			count++
+			continue
+		}
+		_, _, err := d.messages.Modify(room, unread.Serial(), func(msg *model.Message) bool {
+			msg.Read = true
+			return true
+		})
+		if err != nil {
+			return 0, fmt.Errorf("marking room read: %w", err)
+		}
+	}
+	return count, nil
+}
+
+// unread returns the messages of other users in the room that the local
+// user has not read, latest first. Pages are walked backwards from the
+// latest message until one that was read is found.
+func (d *DemoBackend) unread(room string) []model.Message {
+	var (
+		unread     []model.Message
+		relativeTo = list.NoSerial
+	)
+	for {
+		elems, more := d.messages.LoadLimit(room, list.Before, relativeTo, 0)
+		for ii := len(elems) - 1; ii >= 0; ii-- {
+			msg := elems[ii].(model.Message)
+			if msg.Sender == d.local.Name {
+				continue
+			}
+			if msg.Read {
+				return unread
+			}
+			unread = append(unread, msg)
+		}
+		if !more || len(elems) == 0 {
+			return unread
+		}
+		relativeTo = elems[0].Serial()
+	}
+}
+
+// mentions returns the serials of the messages that mention user.
+func mentions(msgs []model.Message, user string) []list.Serial {
+	var serials []list.Serial
+	for _, msg := range msgs {
+		if msg.Mentioned(user) {
+			serials = append(serials, msg.Serial())
+		}
+	}
+	return serials
+}
+
+// Edit replaces the content of a message sent by the local user.
+func (d *DemoBackend) Edit(room string, serial list.Serial, content string) (model.Message, error) {
+	return d.change(room, serial, "editing message", func(msg *model.Message, now time.Time) error {
+		if err := msg.CheckEdit(d.local.Name, content, now, d.RecallWindow); err != nil {
+			return err
+		}
+		msg.Content = content
+		msg.EditedAt = now
+		return nil
+	})
+}
+
+// Recall recalls a message sent by the local user.
+func (d *DemoBackend) Recall(room string, serial list.Serial) (model.Message, error) {
+	return d.change(room, serial, "recalling message", func(msg *model.Message, now time.Time) error {
+		if err := msg.CheckChange(d.local.Name, now, d.RecallWindow); err != nil {
+			return err
+		}
+		*msg = msg.Recall()
+		return nil
+	})
+}
+
+// change applies fn to a stored message, failing with the error returned
+// by fn, simulating network latency. what describes the change for errors.
+func (d *DemoBackend) change(room string, serial list.Serial, what string, fn func(msg *model.Message, now time.Time) error) (model.Message, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Message{}, fmt.Errorf("%s: unknown room %q", what, room)
+	}
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	var err error
+	msg, ok, storeErr := d.messages.Modify(room, serial, func(msg *model.Message) bool {
+		err = fn(msg, time.Now())
+		return err == nil
+	})
+	switch {
+	case storeErr != nil:
+		return model.Message{}, fmt.Errorf("%s: %w", what, storeErr)
+	case err != nil:
+		return model.Message{}, fmt.Errorf("%s: %w", what, err)
+	case !ok:
+		return model.Message{}, fmt.Errorf("%s: unknown message %q", what, serial)
+	}
+	return msg, nil
+}
+
+// React stores the reaction of the local user to a message.
+func (d *DemoBackend) React(room string, serial list.Serial, emoji string, add bool) (model.Message, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Message{}, fmt.Errorf("reacting to message: unknown room %q", room)
+	}
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	return d.react(room, serial, d.local.Name, emoji, add)
+}
+
+// react stores the reaction of user to a message.
+func (d *DemoBackend) react(room string, serial list.Serial, user, emoji string, add bool) (model.Message, error) {
+	msg, _, err := d.messages.Modify(room, serial, func(msg *model.Message) bool {
+		if msg.Reactions.Has(emoji, user) == add {
+			return false
+		}
+		msg.Reactions = msg.Reactions.With(emoji, user, add)
+		return true
+	})
+	if err != nil {
+		return model.Message{}, fmt.Errorf("reacting to message: %w", err)
+	}
+	if msg.SerialID == "" {
+		return model.Message{}, fmt.Errorf("reacting to message: unknown message %q", serial)
+	}
+	return msg, nil
+}
+
+// simulatedReactions are the emoji simulated users react with.
+var simulatedReactions = []string{"👍", "😂", "❤️", "🎉", "😮"}
+
+// simulateReaction makes user react to one of the latest messages of the
+// room.
+func (d *DemoBackend) simulateReaction(room, user string) {
+	elems, _ := d.messages.Load(room, list.Before, list.NoSerial)
+	if len(elems) == 0 {
+		return
+	}
+	target := elems[len(elems)-1-rand.Intn(len(elems))]
+	emoji := simulatedReactions[rand.Intn(len(simulatedReactions))]
+	msg, err := d.react(room, target.Serial(), user, emoji, true)
+	if err != nil {
+		log.Printf("simulating reaction: %v", err)
+		return
+	}
+	d.emit(ReactionEvent{Room: room, Message: msg})
+}
+
+// ChangeGroup applies a change to the members or settings of a room made
+// by the local user, and records it in the room.
+func (d *DemoBackend) ChangeGroup(room string, change model.GroupChange) (model.Group, model.Message, error) {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return model.Group{}, model.Message{}, fmt.Errorf("changing group: unknown room %q", room)
+	}
+	if change.Kind == model.ChangeAdd {
+		for _, name := range change.Users {
+			if _, ok := d.users.Lookup(name); !ok {
+				return model.Group{}, model.Message{}, fmt.Errorf("changing group: unknown user %q", name)
+			}
+		}
+	}
+	if d.SimulateLatency > 0 {
+		time.Sleep(time.Millisecond * time.Duration(rand.Intn(d.SimulateLatency)))
+	}
+	d.rosterMu.Lock()
+	defer d.rosterMu.Unlock()
+	g, text, err := d.groups[room].Apply(d.local.Name, change)
+	if err != nil {
+		return model.Group{}, model.Message{}, fmt.Errorf("changing group: %w", err)
+	}
+	d.groups[room] = g
+	if err := d.saveRoster(); err != nil {
+		return model.Group{}, model.Message{}, fmt.Errorf("changing group: %w", err)
+	}
+	msg := d.generator.GenNewMessage(d.local, text)
+	notice := model.Notice(msg.Serial(), d.local.Name, text, msg.SentAt)
+	notice.Read = true
+	if err := d.messages.Put(room, notice); err != nil {
+		return model.Group{}, model.Message{}, fmt.Errorf("changing group: %w", err)
+	}
+	return g, notice, nil
+}
+
+// Typing accepts the composing state of the local user. The simulated users
+// take no notice of it.
+func (d *DemoBackend) Typing(room string, composing bool) error {
+	if _, ok := d.rooms.Lookup(room); !ok {
+		return fmt.Errorf("sending typing state: unknown room %q", room)
+	}
+	return nil
+}
+
+// Close stops the simulated activity and closes the message store.
+// Calling it again has no effect.
+func (d *DemoBackend) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		err = d.messages.Close()
+	})
+	return err
+}
+
+// emit pushes e to the subscriber, reporting false if the backend is
+// closed first.
+func (d *DemoBackend) emit(e Event) bool {
+	select {
+	case d.events <- e:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
+
+// sleep waits for duration, reporting false if the backend is closed
+// first.
+func (d *DemoBackend) sleep(duration time.Duration) bool {
+	t := time.NewTimer(duration)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
+
+// Subscribe starts the simulated users on first call and returns the
+// channel their activity is pushed on.
+func (d *DemoBackend) Subscribe() <-chan Event {
+	d.simulate.Do(d.simulateUsers)
+	return d.events
+}
+
+// simulateUsers spins up a bunch of async actors to send messages to rooms.
+func (d *DemoBackend) simulateUsers() {
+	for _, u := range d.users.List() {
+		u := u
+		if u.Name == d.local.Name {
+			continue
+		}
+		go func() {
+			for {
+				var (
+					respond = time.Second * time.Duration(1)
+					compose = time.Second * time.Duration(1)
+					rooms   = d.visibleRooms(u.Name)
+				)
+				if !d.sleep(respond) {
+					return
+				}
+				if len(rooms) == 0 {
+					continue
+				}
+				room := rooms[rand.Intn(len(rooms))]
+				if !d.emit(ComposingEvent{Room: room.Name, User: u.Name, Composing: true}) ||
+					!d.sleep(compose) ||
+					!d.emit(ComposingEvent{Room: room.Name, User: u.Name, Composing: false}) {
+					return
+				}
+				var (
+					content  = lorem.Paragraph(1, 4)
+					mentions []string
+				)
+				if others := d.participants(room.Name, u.Name); len(others) > 0 && rand.Intn(4) == 0 {
+					mentions = []string{others[rand.Intn(len(others))]}
+					content = "@" + mentions[0] + " " + content
+				}
+				msg, err := d.send(room.Name, u.Name, content, mentions...)
+				if err != nil {
+					log.Printf("simulating user: %v", err)
+					continue
+				}
+				if !d.emit(MessageEvent{Room: room.Name, Message: msg}) {
+					return
+				}
+				if rand.Intn(3) == 0 {
+					d.simulateReaction(room.Name, u.Name)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gioui.org/app"
+)
+
+// randomImage returns a random image at the given size.
+// Downloads some number of random images from unplash and caches them on disk.
+//
+// TODO(jfm) [performance]: download images concurrently (parallel downloads,
+// async to the gui event loop).
+func randomImage(sz image.Point) (image.Image, error) {
+	mkCacheDir := func(base string) string {
+		return filepath.Join(base, "chat", fmt.Sprintf("%dx%d", sz.X, sz.Y))
+	}
+	cache := mkCacheDir(os.TempDir())
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		if !errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("preparing cache directory: %w", err)
+		}
+		dir, err := app.DataDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed finding application data dir: %w", err)
+		}
+		cache = mkCacheDir(dir)
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			return nil, fmt.Errorf("preparing fallback cache directory: %w", err)
+		}
+	}
+	entries, err := ioutil.ReadDir(cache)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache entries: %w", err)
+	}
+	entries = filter(entries, isFile)
+	if len(entries) == 0 {
+		for ii := 0; ii < 10; ii++ {
+			ii := ii
+			if err := func() error {
+				r, err := http.Get(fmt.Sprintf("https://source.unsplash.com/random/%dx%d?nature", sz.X, sz.Y))
+				if err != nil {
+					return fmt.Errorf("fetching image data: %w", err)
+				}
+				defer r.Body.Close()
+				imgf, err := os.Create(filepath.Join(cache, strconv.Itoa(ii)))
+				if err != nil {
+					return fmt.Errorf("creating image file on disk: %w", err)
+				}
+				defer imgf.Close()
+				if _, err := io.Copy(imgf, r.Body); err != nil {
+					return fmt.Errorf("downloading image: %w", err)
+				}
+				return nil
+			}(); err != nil {
+				return nil, fmt.Errorf("populating image cache: %w", err)
+			}
+		}
+		return randomImage(sz)
+	}
+	selection := entries[rand.Intn(len(entries))]
+	imgf, err := os.Open(filepath.Join(cache, selection.Name()))
+	if err != nil {
+		return nil, fmt.Errorf("opening image file: %w", err)
+	}
+	defer imgf.Close()
+	img, _, err := image.Decode(imgf)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	// Copy the image into a GPU friendly format.
+	dst := image.NewRGBA(image.Rectangle{
+		Max: img.Bounds().Size(),
+	})
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	return dst, nil
+}
+
+// isFile filters out non-file entries.
+func isFile(info fs.FileInfo) bool {
+	return !info.IsDir()
+}
+
+func filter(list []fs.FileInfo, predicate func(fs.FileInfo) bool) (filtered []fs.FileInfo) {
+	for _, item := range list {
+		if predicate(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -8,13 +8,14 @@ import (
 	"image/color"
 	"math"
 	"math/rand"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
 
 	lorem "github.com/drhodes/golorem"
-	matchat "wechat_ui/ui/pkg/widget/material"
 )
 
 // inflection point in the theoretical message timeline.
@@ -58,6 +59,21 @@ func (g *Generator) GenNewMessage(user *model.User, content string) model.Messag
 	return GenMessage(user, content, inflection+g.new.Increment(), time.Now(), nil)
 }
 
+// Resume advances the serial counter for new messages past the provided
+// serial, so that messages generated after a restart do not collide with
+// persisted ones.
+func (g *Generator) Resume(serial list.Serial) {
+	n, err := strconv.Atoi(string(serial))
+	if err != nil || n <= inflection {
+		return
+	}
+	g.new.Lock()
+	if n-inflection > g.new.v {
+		g.new.v = n - inflection
+	}
+	g.new.Unlock()
+}
+
 // GenMessage generates a message with sensible defaults.
 func GenMessage(
 	user *model.User,
@@ -91,11 +107,14 @@ func GenMessage(
 		Read: func() bool {
 			return serial < inflection
 		}(),
-		Status: func() string {
+		Status: func() model.Status {
+			if serial >= inflection {
+				return model.StatusPending
+			}
 			if rand.Int()%10 == 0 {
-				return matchat.FailedToSend
+				return model.StatusFailed
 			}
-			return ""
+			return model.StatusRead
 		}(),
 	}
 }
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"wechat_ui/ui/pkg/markup"
+)
+
+// Attachment describes a file sent with a message.
+type Attachment struct {
+	// Name of the file, without its directory.
+	Name string
+	// Size of the file in bytes.
+	Size int64
+	// MIME is the media type of the file.
+	MIME string
+	// URL locates the contents of the file. Files that have not been
+	// uploaded yet have a file:// URL.
+	URL string
+}
+
+// IsImage reports whether the attachment is an image that can be
+// presented inline.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIME, "image/")
+}
+
+// Preview returns a single line summary of the message, suitable for
+// presenting it in a room list. The content is stripped of its formatting
+// markers.
+func (m Message) Preview() string {
+	switch {
+	case m.Recalled:
+		return m.Sender + " recalled a message"
+	case m.Voice != nil:
+		return fmt.Sprintf("[语音] %d\"", m.Voice.Seconds())
+	case m.Content != "" || m.Attachment == nil:
+		return markup.Plain(m.Content)
+	case m.Attachment.IsImage():
+		return "[图片]"
+	default:
+		return "[文件] " + m.Attachment.Name
+	}
+}
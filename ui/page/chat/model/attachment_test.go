@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessagePreview(t *testing.T) {
+	type testcase struct {
+		msg  Message
+		want string
+	}
+	for _, tc := range []testcase{
+		{msg: Message{Content: "hello"}, want: "hello"},
+		{msg: Message{}, want: ""},
+		{msg: Message{Content: "**bold** `code`"}, want: "bold code"},
+		{
+			msg:  Message{Attachment: &Attachment{Name: "cat.png", MIME: "image/png"}},
+			want: "[图片]",
+		},
+		{
+			msg:  Message{Attachment: &Attachment{Name: "report.pdf", MIME: "application/pdf"}},
+			want: "[文件] report.pdf",
+		},
+		{
+			msg:  Message{Voice: &Voice{Duration: 4200 * time.Millisecond}, Attachment: &Attachment{Name: "voice.wav"}},
+			want: "[语音] 5\"",
+		},
+		{
+			msg:  Message{Content: "see attached", Attachment: &Attachment{Name: "report.pdf"}},
+			want: "see attached",
+		},
+	} {
+		if got := tc.msg.Preview(); got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.msg, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// ComposingTimeout is how long a user remains composing after signalling
+// it, unless the signal is renewed or withdrawn in the meantime. It expires
+// the signals of users whose stop signal never arrives.
+const ComposingTimeout = 6 * time.Second
+
+// SetComposing sets the composing status of a user for this room.
+func (r *Room) SetComposing(user string, isComposing bool) {
+	if isComposing {
+		r.Composing.Store(user, time.Now())
+	}
+	if !isComposing {
+		r.Composing.Delete(user)
+	}
+}
+
+// ComposingUsers returns the users composing a message in this room at now,
+// sorted by name, along with when the first of their signals expires.
+// Expired signals are dropped.
+func (r *Room) ComposingUsers(now time.Time) (users []string, expiry time.Time) {
+	r.Composing.Range(func(key, value interface{}) bool {
+		at, _ := value.(time.Time)
+		end := at.Add(ComposingTimeout)
+		if !now.Before(end) {
+			r.Composing.Delete(key)
+			return true
+		}
+		users = append(users, key.(string))
+		if expiry.IsZero() || end.Before(expiry) {
+			expiry = end
+		}
+		return true
+	})
+	sort.Strings(users)
+	return users, expiry
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComposingUsers(t *testing.T) {
+	var r Room
+	if users, expiry := r.ComposingUsers(time.Now()); len(users) != 0 || !expiry.IsZero() {
+		t.Errorf("expected nobody composing, got %v until %v", users, expiry)
+	}
+	start := time.Now()
+	r.SetComposing("bob", true)
+	r.SetComposing("alice", true)
+	r.SetComposing("carol", true)
+	r.SetComposing("carol", false)
+	users, expiry := r.ComposingUsers(time.Now())
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(users, want) {
+		t.Errorf("expected %v composing, got %v", want, users)
+	}
+	if expiry.Before(start.Add(ComposingTimeout)) || expiry.After(time.Now().Add(ComposingTimeout)) {
+		t.Errorf("unexpected expiry %v", expiry)
+	}
+	// Signals expire if they are not renewed.
+	if users, _ := r.ComposingUsers(time.Now().Add(ComposingTimeout)); len(users) != 0 {
+		t.Errorf("expected signals to expire, got %v", users)
+	}
+	if _, ok := r.Composing.Load("alice"); ok {
+		t.Errorf("expected expired signals to be dropped")
+	}
+}
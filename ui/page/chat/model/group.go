@@ -0,0 +1,276 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wechat_ui/ui/pkg/list"
+)
+
+// Role is the standing of a member in a group.
+type Role string
+
+const (
+	RoleMember Role = "member"
+	RoleAdmin  Role = "admin"
+	RoleOwner  Role = "owner"
+)
+
+// rank orders the roles by the changes they are allowed to make.
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 1
+	case RoleOwner:
+		return 2
+	}
+	return 0
+}
+
+// Member is a user participating in a group.
+type Member struct {
+	Name string
+	Role Role
+}
+
+// Group holds the membership and settings of a room. A room without
+// members is open: every user can see it, post to it and manage it.
+type Group struct {
+	// Title is the name the room is presented under, set when it is
+	// renamed. Empty presents the name of the room, which identifies it
+	// and never changes.
+	Title string
+	// Announcement is a notice from the managers of the room to its
+	// members.
+	Announcement string
+	// Members of the room, in the order they joined.
+	Members []Member
+}
+
+// Open reports whether the room is open to every user.
+func (g Group) Open() bool {
+	return len(g.Members) == 0
+}
+
+// Member looks up the member with the provided name.
+func (g Group) Member(name string) (Member, bool) {
+	for _, m := range g.Members {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Visible reports whether user can see the room.
+func (g Group) Visible(user string) bool {
+	_, ok := g.Member(user)
+	return ok || g.Open()
+}
+
+// CanManage reports whether user can rename the room, change its
+// announcement and add members to it: its owner and admins can.
+func (g Group) CanManage(user string) bool {
+	if g.Open() {
+		return true
+	}
+	m, ok := g.Member(user)
+	return ok && m.Role.rank() >= RoleAdmin.rank()
+}
+
+// CanRemove reports whether user can remove the named member from the
+// room: the owner can remove anyone else, and admins can remove the
+// members without a role.
+func (g Group) CanRemove(user, name string) bool {
+	m, ok := g.Member(user)
+	target, found := g.Member(name)
+	return ok && found && user != name && m.Role.rank() >= RoleAdmin.rank() && m.Role.rank() > target.Role.rank()
+}
+
+// CanSetRole reports whether user can change the role of the named member
+// of the room: only the owner can, for anyone else.
+func (g Group) CanSetRole(user, name string) bool {
+	m, ok := g.Member(user)
+	_, found := g.Member(name)
+	return ok && found && user != name && m.Role == RoleOwner
+}
+
+// ChangeKind enumerates the changes to a group.
+type ChangeKind string
+
+const (
+	ChangeRename   ChangeKind = "rename"
+	ChangeAnnounce ChangeKind = "announce"
+	ChangeAdd      ChangeKind = "add"
+	ChangeRemove   ChangeKind = "remove"
+	ChangeLeave    ChangeKind = "leave"
+	ChangeRole     ChangeKind = "role"
+)
+
+// GroupChange is a change to the membership or settings of a room.
+type GroupChange struct {
+	Kind ChangeKind
+	// Text is the new title or announcement.
+	Text string
+	// Users are the names of the users added, removed or whose role is
+	// changed.
+	Users []string
+	// Role is the role given to Users: RoleAdmin promotes them and
+	// RoleMember demotes them.
+	Role Role
+}
+
+// Apply returns the group after actor made change c, along with a
+// description of the change for the notice recording it in the room. It
+// fails if actor cannot make the change.
+//
+// Adding members to an open room makes actor its owner. When the owner
+// leaves, its role passes on to the first admin, or else to the first
+// member; the last member cannot leave.
+func (g Group) Apply(actor string, c GroupChange) (Group, string, error) {
+	g.Members = append([]Member(nil), g.Members...)
+	switch c.Kind {
+	case ChangeRename, ChangeAnnounce:
+		if !g.CanManage(actor) {
+			return g, "", errors.New("only the owner and admins can change the group")
+		}
+		text := strings.TrimSpace(c.Text)
+		if c.Kind == ChangeAnnounce {
+			g.Announcement = text
+			if text == "" {
+				return g, actor + " cleared the announcement", nil
+			}
+			return g, actor + " changed the announcement", nil
+		}
+		if text == "" {
+			return g, "", errors.New("empty group name")
+		}
+		g.Title = text
+		return g, fmt.Sprintf("%s renamed the group to %q", actor, text), nil
+	case ChangeAdd:
+		if !g.CanManage(actor) {
+			return g, "", errors.New("only the owner and admins can add members")
+		}
+		if g.Open() {
+			g.Members = append(g.Members, Member{Name: actor, Role: RoleOwner})
+		}
+		var added []string
+		for _, name := range c.Users {
+			if _, ok := g.Member(name); ok || name == "" {
+				continue
+			}
+			g.Members = append(g.Members, Member{Name: name, Role: RoleMember})
+			added = append(added, name)
+		}
+		if len(added) == 0 {
+			return g, "", errors.New("no users to add")
+		}
+		return g, actor + " added " + strings.Join(added, ", "), nil
+	case ChangeRemove:
+		var removed []string
+		for ii, name := range c.Users {
+			if contains(c.Users[:ii], name) {
+				continue
+			}
+			if !g.CanRemove(actor, name) {
+				return g, "", fmt.Errorf("cannot remove %q", name)
+			}
+			g.Members = g.without(name)
+			removed = append(removed, name)
+		}
+		if len(removed) == 0 {
+			return g, "", errors.New("no members to remove")
+		}
+		return g, actor + " removed " + strings.Join(removed, ", "), nil
+	case ChangeRole:
+		if c.Role != RoleAdmin && c.Role != RoleMember {
+			return g, "", fmt.Errorf("cannot give role %q", c.Role)
+		}
+		var changed []string
+		for _, name := range c.Users {
+			if !g.CanSetRole(actor, name) {
+				return g, "", fmt.Errorf("cannot change the role of %q", name)
+			}
+			for ii := range g.Members {
+				if g.Members[ii].Name == name && g.Members[ii].Role != c.Role {
+					g.Members[ii].Role = c.Role
+					changed = append(changed, name)
+				}
+			}
+		}
+		if len(changed) == 0 {
+			return g, "", errors.New("no roles to change")
+		}
+		if c.Role == RoleAdmin {
+			return g, actor + " made " + strings.Join(changed, ", ") + " admin", nil
+		}
+		return g, actor + " removed " + strings.Join(changed, ", ") + " from the admins", nil
+	case ChangeLeave:
+		m, ok := g.Member(actor)
+		switch {
+		case !ok:
+			return g, "", errors.New("not a member")
+		case len(g.Members) == 1:
+			return g, "", errors.New("the last member cannot leave")
+		}
+		g.Members = g.without(actor)
+		if m.Role == RoleOwner {
+			g.Members[g.successor()].Role = RoleOwner
+		}
+		return g, actor + " left the group", nil
+	}
+	return g, "", fmt.Errorf("unknown change %q", c.Kind)
+}
+
+// contains reports whether names holds name.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns the members but the named one.
+func (g Group) without(name string) []Member {
+	members := make([]Member, 0, len(g.Members))
+	for _, m := range g.Members {
+		if m.Name != name {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// successor returns the index of the member the role of a leaving owner
+// passes on to.
+func (g Group) successor() int {
+	for ii, m := range g.Members {
+		if m.Role == RoleAdmin {
+			return ii
+		}
+	}
+	return 0
+}
+
+// Notice returns the system message recording a change made by actor at
+// the provided time, described by text.
+func Notice(serial list.Serial, actor, text string, at time.Time) Message {
+	return Message{
+		SerialID: string(serial),
+		Sender:   actor,
+		Content:  text,
+		SentAt:   at,
+		System:   true,
+	}
+}
+
+// DisplayName returns the name the room is presented under.
+func (r *Room) DisplayName() string {
+	if r.Title != "" {
+		return r.Title
+	}
+	return r.Name
+}
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupApply(t *testing.T) {
+	g := Group{Members: []Member{
+		{Name: "alice", Role: RoleOwner},
+		{Name: "bob", Role: RoleAdmin},
+		{Name: "carol", Role: RoleMember},
+	}}
+	type testcase struct {
+		name    string
+		actor   string
+		change  GroupChange
+		members []Member
+		notice  string
+		ok      bool
+	}
+	for _, tc := range []testcase{
+		{
+			name:   "rename",
+			actor:  "bob",
+			change: GroupChange{Kind: ChangeRename, Text: " friends "},
+			notice: `bob renamed the group to "friends"`,
+			ok:     true,
+		},
+		{
+			name:   "rename by member",
+			actor:  "carol",
+			change: GroupChange{Kind: ChangeRename, Text: "mine"},
+		},
+		{
+			name:   "add",
+			actor:  "bob",
+			change: GroupChange{Kind: ChangeAdd, Users: []string{"carol", "dave"}},
+			members: []Member{
+				{Name: "alice", Role: RoleOwner},
+				{Name: "bob", Role: RoleAdmin},
+				{Name: "carol", Role: RoleMember},
+				{Name: "dave", Role: RoleMember},
+			},
+			notice: "bob added dave",
+			ok:     true,
+		},
+		{
+			name:   "add members only",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeAdd, Users: []string{"carol"}},
+		},
+		{
+			name:   "admin removes member",
+			actor:  "bob",
+			change: GroupChange{Kind: ChangeRemove, Users: []string{"carol"}},
+			members: []Member{
+				{Name: "alice", Role: RoleOwner},
+				{Name: "bob", Role: RoleAdmin},
+			},
+			notice: "bob removed carol",
+			ok:     true,
+		},
+		{
+			name:   "remove duplicates",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeRemove, Users: []string{"carol", "carol"}},
+			members: []Member{
+				{Name: "alice", Role: RoleOwner},
+				{Name: "bob", Role: RoleAdmin},
+			},
+			notice: "alice removed carol",
+			ok:     true,
+		},
+		{
+			name:   "admin removes owner",
+			actor:  "bob",
+			change: GroupChange{Kind: ChangeRemove, Users: []string{"alice"}},
+		},
+		{
+			name:   "member removes member",
+			actor:  "carol",
+			change: GroupChange{Kind: ChangeRemove, Users: []string{"bob"}},
+		},
+		{
+			name:   "owner promotes member",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeRole, Users: []string{"carol"}, Role: RoleAdmin},
+			members: []Member{
+				{Name: "alice", Role: RoleOwner},
+				{Name: "bob", Role: RoleAdmin},
+				{Name: "carol", Role: RoleAdmin},
+			},
+			notice: "alice made carol admin",
+			ok:     true,
+		},
+		{
+			name:   "owner demotes admin",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeRole, Users: []string{"bob"}, Role: RoleMember},
+			members: []Member{
+				{Name: "alice", Role: RoleOwner},
+				{Name: "bob", Role: RoleMember},
+				{Name: "carol", Role: RoleMember},
+			},
+			notice: "alice removed bob from the admins",
+			ok:     true,
+		},
+		{
+			name:   "admin promotes member",
+			actor:  "bob",
+			change: GroupChange{Kind: ChangeRole, Users: []string{"carol"}, Role: RoleAdmin},
+		},
+		{
+			name:   "owner gives away ownership",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeRole, Users: []string{"bob"}, Role: RoleOwner},
+		},
+		{
+			name:   "promote admin",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeRole, Users: []string{"bob"}, Role: RoleAdmin},
+		},
+		{
+			name:   "owner leaves",
+			actor:  "alice",
+			change: GroupChange{Kind: ChangeLeave},
+			members: []Member{
+				{Name: "bob", Role: RoleOwner},
+				{Name: "carol", Role: RoleMember},
+			},
+			notice: "alice left the group",
+			ok:     true,
+		},
+		{
+			name:   "stranger leaves",
+			actor:  "dave",
+			change: GroupChange{Kind: ChangeLeave},
+		},
+	} {
+		got, notice, err := g.Apply(tc.actor, tc.change)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tc.name, tc.ok, err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		if notice != tc.notice {
+			t.Errorf("%s: expected notice %q, got %q", tc.name, tc.notice, notice)
+		}
+		if tc.members != nil && !reflect.DeepEqual(got.Members, tc.members) {
+			t.Errorf("%s: expected members %v, got %v", tc.name, tc.members, got.Members)
+		}
+	}
+	if len(g.Members) != 3 || g.Members[0].Role != RoleOwner {
+		t.Errorf("expected changes not to modify the group, got %v", g.Members)
+	}
+}
+
+func TestOpenGroup(t *testing.T) {
+	var g Group
+	if !g.Visible("alice") || !g.CanManage("alice") {
+		t.Errorf("expected open room to be visible and manageable by anyone")
+	}
+	g, _, err := g.Apply("alice", GroupChange{Kind: ChangeAdd, Users: []string{"bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := g.Member("alice"); m.Role != RoleOwner {
+		t.Errorf("expected adding members to make alice the owner, got %v", g.Members)
+	}
+	if g.Visible("carol") || g.CanManage("bob") {
+		t.Errorf("expected group to be closed to non members and managed by its owner")
+	}
+	if _, _, err := g.Apply("bob", GroupChange{Kind: ChangeLeave}); err != nil {
+		t.Fatal(err)
+	}
+	last := Group{Members: []Member{{Name: "alice", Role: RoleOwner}}}
+	if _, _, err := last.Apply("alice", GroupChange{Kind: ChangeLeave}); err == nil {
+		t.Errorf("expected the last member not to be able to leave")
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+// MaxHistory is the number of sent messages a History remembers.
+const MaxHistory = 50
+
+// History holds the messages the local user sent in a room, for the editor
+// to recall them, along with the message being recalled. The zero value is
+// an empty history.
+type History struct {
+	// entries are the sent messages, oldest first.
+	entries []string
+	// pos is the index of the entry being recalled, len(entries) if none
+	// is.
+	pos int
+	// draft is the text being composed when recalling started.
+	draft string
+}
+
+// Add appends a sent message to the history and stops recalling. A message
+// repeating the latest one is not added again.
+func (h *History) Add(text string) {
+	if text != "" && (len(h.entries) == 0 || h.entries[len(h.entries)-1] != text) {
+		h.entries = append(h.entries, text)
+		if len(h.entries) > MaxHistory {
+			h.entries = h.entries[len(h.entries)-MaxHistory:]
+		}
+	}
+	h.Reset()
+}
+
+// Recalling reports whether an entry is being recalled.
+func (h *History) Recalling() bool {
+	return h.pos < len(h.entries)
+}
+
+// Reset stops recalling, dropping the saved draft.
+func (h *History) Reset() {
+	h.pos = len(h.entries)
+	h.draft = ""
+}
+
+// Prev returns the entry before the one being recalled, or the latest entry
+// if none is, in which case draft is saved for Next to return. It reports
+// false if there is no earlier entry.
+func (h *History) Prev(draft string) (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	if !h.Recalling() {
+		h.draft = draft
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// Next returns the entry after the one being recalled, or the draft saved
+// by Prev past the latest entry. It reports false if no entry is being
+// recalled.
+func (h *History) Next() (string, bool) {
+	if !h.Recalling() {
+		return "", false
+	}
+	h.pos++
+	if !h.Recalling() {
+		draft := h.draft
+		h.draft = ""
+		return draft, true
+	}
+	return h.entries[h.pos], true
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	var h History
+	if _, ok := h.Prev(""); ok {
+		t.Errorf("expected no entry in an empty history")
+	}
+	h.Add("one")
+	h.Add("two")
+	h.Add("two")
+	h.Add("")
+	for _, step := range []struct {
+		prev bool
+		want string
+		ok   bool
+	}{
+		{prev: true, want: "two", ok: true},
+		{prev: true, want: "one", ok: true},
+		{prev: true, ok: false},
+		{want: "two", ok: true},
+		{want: "draft", ok: true},
+		{ok: false},
+	} {
+		var (
+			got string
+			ok  bool
+		)
+		if step.prev {
+			got, ok = h.Prev("draft")
+		} else {
+			got, ok = h.Next()
+		}
+		if ok != step.ok || ok && got != step.want {
+			t.Fatalf("prev=%v: expected %q, %v, got %q, %v", step.prev, step.want, step.ok, got, ok)
+		}
+	}
+	if h.Recalling() {
+		t.Errorf("expected recalling to stop past the latest entry")
+	}
+	h.Prev("")
+	h.Add("three")
+	if h.Recalling() {
+		t.Errorf("expected adding to stop recalling")
+	}
+	if got, _ := h.Prev(""); got != "three" {
+		t.Errorf("expected latest entry, got %q", got)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	var h History
+	for ii := 0; ii < MaxHistory+5; ii++ {
+		h.Add(fmt.Sprint(ii))
+	}
+	n := 0
+	oldest := ""
+	for text, ok := h.Prev(""); ok; text, ok = h.Prev("") {
+		n++
+		oldest = text
+	}
+	if n != MaxHistory || oldest != "5" {
+		t.Errorf("expected %d entries from 5, got %d from %q", MaxHistory, n, oldest)
+	}
+}
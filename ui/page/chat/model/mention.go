@@ -0,0 +1,102 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"wechat_ui/ui/pkg/list"
+)
+
+// Mentioned reports whether the message mentions user.
+func (m Message) Mentioned(user string) bool {
+	for _, name := range m.Mentions {
+		if name == user {
+			return true
+		}
+	}
+	return false
+}
+
+// MentionBefore reports whether the runes of text ending at the rune offset
+// caret are a mention being typed: an "@" at the start of text or after a
+// space, followed by a query free of spaces. It returns the rune offset of
+// the "@" and the query, which may be empty.
+func MentionBefore(text string, caret int) (start int, query string, ok bool) {
+	runes := []rune(text)
+	if caret > len(runes) {
+		return 0, "", false
+	}
+	for ii := caret - 1; ii >= 0; ii-- {
+		r := runes[ii]
+		if r == '@' {
+			if ii > 0 && !unicode.IsSpace(runes[ii-1]) {
+				break
+			}
+			return ii, string(runes[ii+1 : caret]), true
+		}
+		if unicode.IsSpace(r) {
+			break
+		}
+	}
+	return 0, "", false
+}
+
+// MatchNames returns the names matching query, ignoring case: those it
+// prefixes first, then those containing it, each in the order given.
+func MatchNames(names []string, query string) []string {
+	query = strings.ToLower(query)
+	type match struct {
+		name string
+		rank int
+	}
+	var matches []match
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, query):
+			matches = append(matches, match{name: name, rank: 0})
+		case strings.Contains(lower, query):
+			matches = append(matches, match{name: name, rank: 1})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].rank < matches[j].rank
+	})
+	results := make([]string, len(matches))
+	for ii, m := range matches {
+		results[ii] = m.name
+	}
+	return results
+}
+
+// MentionsIn returns the names, without duplicates, that content still
+// mentions as "@" followed by the name. It drops the mentions picked while
+// composing that were since edited out.
+func MentionsIn(content string, names []string) []string {
+	var mentions []string
+	for _, name := range names {
+		if name == "" || !strings.Contains(content, "@"+name) {
+			continue
+		}
+		dup := false
+		for _, m := range mentions {
+			dup = dup || m == name
+		}
+		if !dup {
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// ReadMentions drops the mentions of the room up to and including the
+// message with the provided serial, which has been read.
+func (r *Room) ReadMentions(serial list.Serial) {
+	unread := r.Mentions[:0]
+	for _, m := range r.Mentions {
+		if SerialLessThan(serial, m) {
+			unread = append(unread, m)
+		}
+	}
+	r.Mentions = unread
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"wechat_ui/ui/pkg/list"
+)
+
+func TestMentionBefore(t *testing.T) {
+	type testcase struct {
+		name  string
+		text  string
+		caret int
+		start int
+		query string
+		ok    bool
+	}
+	for _, tc := range []testcase{
+		{name: "bare", text: "@", caret: 1, start: 0, query: "", ok: true},
+		{name: "query", text: "hi @bo", caret: 6, start: 3, query: "bo", ok: true},
+		{name: "caret inside", text: "hi @bob there", caret: 5, start: 3, query: "b", ok: true},
+		{name: "after space", text: "@bob there", caret: 10},
+		{name: "email", text: "mail a@b", caret: 8},
+		{name: "no at", text: "hello", caret: 5},
+		{name: "runes", text: "你好 @鲍勃", caret: 6, start: 3, query: "鲍勃", ok: true},
+		{name: "caret past end", text: "@a", caret: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, query, ok := MentionBefore(tc.text, tc.caret)
+			if ok != tc.ok || start != tc.start || query != tc.query {
+				t.Errorf("expected (%d, %q, %v), got (%d, %q, %v)", tc.start, tc.query, tc.ok, start, query, ok)
+			}
+		})
+	}
+}
+
+func TestMatchNames(t *testing.T) {
+	names := []string{"carol", "Bob", "alice", "bobby", "abbot"}
+	if got, want := MatchNames(names, "bo"), []string{"Bob", "bobby", "abbot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := MatchNames(names, ""); !reflect.DeepEqual(got, names) {
+		t.Errorf("expected every name for an empty query, got %v", got)
+	}
+	if got := MatchNames(names, "zz"); len(got) != 0 {
+		t.Errorf("expected no matches, got %v", got)
+	}
+}
+
+func TestMentionsIn(t *testing.T) {
+	got := MentionsIn("@bob and @carol, @bob again", []string{"bob", "alice", "carol", "bob"})
+	if want := []string{"bob", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestReadMentions(t *testing.T) {
+	r := Room{Mentions: []list.Serial{"12", "9", "4"}}
+	r.ReadMentions("9")
+	if want := []list.Serial{"12"}; !reflect.DeepEqual(r.Mentions, want) {
+		t.Errorf("expected %v, got %v", want, r.Mentions)
+	}
+}
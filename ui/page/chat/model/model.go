@@ -7,6 +7,7 @@ import (
 	"image"
 	"image/color"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 	"wechat_ui/ui/pkg/list"
@@ -14,12 +15,37 @@ import (
 
 // Message represents a chat message.
 type Message struct {
-	SerialID                string
-	Sender, Content, Status string
-	SentAt                  time.Time
-	Image                   string
-	Avatar                  string
-	Read                    bool
+	SerialID        string
+	Sender, Content string
+	// Status is the delivery state of the message.
+	Status Status
+	SentAt time.Time
+	Image  string
+	Avatar string
+	Read   bool
+	// ReplyTo quotes the message this message replies to, if any.
+	ReplyTo *Quote
+	// Attachment is the file sent with the message, if any. Image
+	// attachments are also referenced by Image.
+	Attachment *Attachment
+	// Voice describes the recording of a voice message, whose audio is
+	// the attachment.
+	Voice *Voice
+	// Reactions to the message.
+	Reactions Reactions
+	// EditedAt is when the sender last edited the content, zero if it
+	// never did.
+	EditedAt time.Time
+	// Recalled reports that the sender recalled the message, which then
+	// holds no contents.
+	Recalled bool
+	// System reports that the message is a notice from the room itself,
+	// such as a change of its members. Content describes it, and Sender is
+	// the user that caused it.
+	System bool
+	// Mentions are the names of the users mentioned in the content, each
+	// as "@" followed by the name.
+	Mentions []string
 }
 
 // Serial returns the unique identifier for this message.
@@ -27,6 +53,41 @@ func (m Message) Serial() list.Serial {
 	return list.Serial(m.SerialID)
 }
 
+// Quote returns a snapshot of the message, for replies to refer to it.
+func (m Message) Quote() *Quote {
+	return &Quote{
+		SerialID: m.SerialID,
+		Sender:   m.Sender,
+		Content:  m.Content,
+	}
+}
+
+// Quote is a snapshot of a message that another message replies to. It
+// lets replies present the original even if it is not loaded, or has since
+// been deleted.
+type Quote struct {
+	SerialID        string
+	Sender, Content string
+}
+
+// Serial returns the unique identifier of the quoted message.
+func (q Quote) Serial() list.Serial {
+	return list.Serial(q.SerialID)
+}
+
+// RowLessThan acts as a list.Comparator, returning whether a sorts before b.
+func RowLessThan(a, b list.Element) bool {
+	return SerialLessThan(a.Serial(), b.Serial())
+}
+
+// SerialLessThan reports whether serial a sorts before serial b.
+// Serials are decimal integers, so they are compared numerically.
+func SerialLessThan(a, b list.Serial) bool {
+	aAsInt, _ := strconv.Atoi(string(a))
+	bAsInt, _ := strconv.Atoi(string(b))
+	return aAsInt < bAsInt
+}
+
 // DateBoundary represents a change in the date during a chat.
 type DateBoundary struct {
 	Date time.Time
@@ -57,18 +118,17 @@ type Room struct {
 	Name string
 	// Latest message in the room, if any.
 	Latest *Message
-	// Composing is a set of users in this room currently composing a message.
+	// Unread is the number of messages from other users the local user has
+	// not read.
+	Unread int
+	// Mentions are the serials of the unread messages that mention the
+	// local user, latest first.
+	Mentions []list.Serial
+	// Composing is a set of users in this room currently composing a message,
+	// mapped to when they last signalled it.
 	Composing sync.Map
-}
-
-// SetComposing sets the composing status of a user for this room.
-func (r *Room) SetComposing(user string, isComposing bool) {
-	if isComposing {
-		r.Composing.Store(user, struct{}{})
-	}
-	if !isComposing {
-		r.Composing.Delete(user)
-	}
+	// Group holds the members and settings of the room.
+	Group
 }
 
 // User is a unique identity that can send messages and participate in rooms.
@@ -83,6 +143,8 @@ type User struct {
 	Theme Theme
 	// Color to use for message bubbles of messages from this user.
 	Color color.NRGBA
+	// Notes the local user keeps about this user.
+	Notes string
 }
 
 // Users structure manages a collection of user data.
@@ -135,32 +197,35 @@ const (
 )
 
 // Rooms structure manages a collection of rooms.
+// It is safe for concurrent use, and rooms may be added at any time.
 type Rooms struct {
-	list  []Room
+	mu    sync.RWMutex
+	list  []*Room
 	index map[string]*Room
-	once  sync.Once
 }
 
 // Add room to collection.
 func (r *Rooms) Add(room Room) {
-	r.once.Do(func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index == nil {
 		r.index = map[string]*Room{}
-	})
-	r.list = append(r.list, room)
-	r.index[room.Name] = &r.list[len(r.list)-1]
+	}
+	r.list = append(r.list, &room)
+	r.index[room.Name] = &room
 }
 
 // List returns an ordered list of room data.
 func (r *Rooms) List() (list []*Room) {
-	list = make([]*Room, len(r.list))
-	for ii := range r.list {
-		list[ii] = &r.list[ii]
-	}
-	return list
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(list, r.list...)
 }
 
 // Lookup room by name.
 func (r *Rooms) Lookup(name string) (*Room, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	v, ok := r.index[name]
 	return v, ok
 }
@@ -168,8 +233,10 @@ func (r *Rooms) Lookup(name string) (*Room, bool) {
 // Random returns a randomly selected room from the collection.
 // If there are no rooms, nil is returned.
 func (r *Rooms) Random() *Room {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	if len(r.list) == 0 {
 		return nil
 	}
-	return &r.list[rand.Intn(len(r.list)-1)]
+	return r.list[rand.Intn(len(r.list))]
 }
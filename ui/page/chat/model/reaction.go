@@ -0,0 +1,68 @@
+package model
+
+// Reaction is an emoji users reacted to a message with.
+type Reaction struct {
+	Emoji string
+	// Users who reacted with Emoji, in the order they reacted.
+	Users []string
+}
+
+// Has reports whether user reacted with the emoji.
+func (r Reaction) Has(user string) bool {
+	for _, u := range r.Users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Reactions are the reactions to a message, in the order their emoji were
+// first used.
+type Reactions []Reaction
+
+// With returns the reactions with the reaction of user with emoji added,
+// or removed if add is false. Emoji no user reacted with anymore are
+// dropped. The receiver is left untouched, as messages are shared by value.
+func (rs Reactions) With(emoji, user string, add bool) Reactions {
+	var (
+		result = make(Reactions, 0, len(rs)+1)
+		found  bool
+	)
+	for _, r := range rs {
+		if r.Emoji != emoji {
+			result = append(result, r)
+			continue
+		}
+		found = true
+		users := make([]string, 0, len(r.Users)+1)
+		for _, u := range r.Users {
+			if u != user {
+				users = append(users, u)
+			}
+		}
+		if add {
+			users = append(users, user)
+		}
+		if len(users) > 0 {
+			result = append(result, Reaction{Emoji: emoji, Users: users})
+		}
+	}
+	if !found && add {
+		result = append(result, Reaction{Emoji: emoji, Users: []string{user}})
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// Has reports whether user reacted with emoji.
+func (rs Reactions) Has(emoji, user string) bool {
+	for _, r := range rs {
+		if r.Emoji == emoji {
+			return r.Has(user)
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReactionsWith(t *testing.T) {
+	var rs Reactions
+	rs = rs.With("👍", "alice", true)
+	rs = rs.With("😄", "bob", true)
+	rs = rs.With("👍", "bob", true)
+	rs = rs.With("👍", "bob", true)
+	want := Reactions{
+		{Emoji: "👍", Users: []string{"alice", "bob"}},
+		{Emoji: "😄", Users: []string{"bob"}},
+	}
+	if !reflect.DeepEqual(rs, want) {
+		t.Fatalf("expected %v, got %v", want, rs)
+	}
+	if !rs.Has("👍", "alice") || rs.Has("😄", "alice") {
+		t.Errorf("unexpected Has results for %v", rs)
+	}
+
+	removed := rs.With("😄", "bob", false).With("👍", "carol", false)
+	if want := (Reactions{{Emoji: "👍", Users: []string{"alice", "bob"}}}); !reflect.DeepEqual(removed, want) {
+		t.Errorf("expected %v, got %v", want, removed)
+	}
+	if len(rs) != 2 || len(rs[0].Users) != 2 {
+		t.Errorf("With modified its receiver: %v", rs)
+	}
+	if rs := removed.With("👍", "alice", false).With("👍", "bob", false); rs != nil {
+		t.Errorf("expected no reactions, got %v", rs)
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRecallWindow is how long after sending a message its sender can
+// edit or recall it, unless configured otherwise.
+const DefaultRecallWindow = 2 * time.Minute
+
+// Edited reports whether the sender edited the content of the message.
+func (m Message) Edited() bool {
+	return !m.EditedAt.IsZero()
+}
+
+// Recall returns the message as recalled by its sender. Its contents are
+// dropped, along with the reactions to them.
+func (m Message) Recall() Message {
+	m.Recalled = true
+	m.Content = ""
+	m.Image = ""
+	m.Attachment = nil
+	m.ReplyTo = nil
+	m.Reactions = nil
+	m.EditedAt = time.Time{}
+	return m
+}
+
+// CheckChange returns why user cannot edit or recall the message at now,
+// or nil if it can. Only the sender can change a message it sent, and only
+// within window of sending it.
+func (m Message) CheckChange(user string, now time.Time, window time.Duration) error {
+	switch {
+	case m.System:
+		return errors.New("notices cannot be changed")
+	case m.Sender != user:
+		return errors.New("only the sender can change a message")
+	case m.Recalled:
+		return errors.New("message was recalled")
+	case m.Status == StatusPending || m.Status == StatusFailed:
+		return errors.New("message was not sent")
+	case now.Sub(m.SentAt) > window:
+		return fmt.Errorf("message can only be changed within %v of sending it", window)
+	}
+	return nil
+}
+
+// CheckEdit returns why user cannot replace the content of the message with
+// content at now, or nil if it can. Only the text of messages can be
+// edited, as set by CheckChange.
+func (m Message) CheckEdit(user, content string, now time.Time, window time.Duration) error {
+	if err := m.CheckChange(user, now, window); err != nil {
+		return err
+	}
+	switch {
+	case m.Attachment != nil:
+		return errors.New("messages with attachments cannot be edited")
+	case content == "":
+		return errors.New("edited content is empty")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckChange(t *testing.T) {
+	sent := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := Message{Sender: "alice", Content: "hi", SentAt: sent, Status: StatusDelivered}
+	type testcase struct {
+		name string
+		msg  Message
+		user string
+		now  time.Time
+		ok   bool
+	}
+	for _, tc := range []testcase{
+		{name: "within window", msg: msg, user: "alice", now: sent.Add(time.Minute), ok: true},
+		{name: "at window end", msg: msg, user: "alice", now: sent.Add(DefaultRecallWindow), ok: true},
+		{name: "after window", msg: msg, user: "alice", now: sent.Add(DefaultRecallWindow + time.Second)},
+		{name: "other user", msg: msg, user: "bob", now: sent},
+		{name: "recalled", msg: msg.Recall(), user: "alice", now: sent},
+		{name: "failed", msg: Message{Sender: "alice", SentAt: sent, Status: StatusFailed}, user: "alice", now: sent},
+		{name: "notice", msg: Notice("1", "alice", "alice left the group", sent), user: "alice", now: sent},
+	} {
+		if err := tc.msg.CheckChange(tc.user, tc.now, DefaultRecallWindow); (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tc.name, tc.ok, err)
+		}
+	}
+	if err := msg.CheckEdit("alice", "", sent, DefaultRecallWindow); err == nil {
+		t.Errorf("expected error editing to empty content")
+	}
+	withFile := msg
+	withFile.Attachment = &Attachment{Name: "a.txt"}
+	if err := withFile.CheckEdit("alice", "caption", sent, DefaultRecallWindow); err == nil {
+		t.Errorf("expected error editing a message with an attachment")
+	}
+}
+
+func TestRecall(t *testing.T) {
+	msg := Message{
+		SerialID:   "1",
+		Sender:     "alice",
+		Content:    "oops",
+		Attachment: &Attachment{Name: "a.png", MIME: "image/png"},
+		Reactions:  Reactions{{Emoji: "👍", Users: []string{"bob"}}},
+	}
+	recalled := msg.Recall()
+	if !recalled.Recalled || recalled.Content != "" || recalled.Attachment != nil || recalled.Reactions != nil {
+		t.Errorf("unexpected recalled message %+v", recalled)
+	}
+	if recalled.Serial() != "1" || recalled.Sender != "alice" {
+		t.Errorf("expected recalled message to keep its identity, got %+v", recalled)
+	}
+	if got := recalled.Preview(); got != "alice recalled a message" {
+		t.Errorf("unexpected preview %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package model
+
+// Status is the delivery state of a message sent by the local user.
+//
+// A message starts out pending, and is either sent or failed once the
+// backend acknowledges it. Sent messages progress to delivered once they
+// reach another participant, and to read once one of them has seen it.
+// A failed message can only go back to pending, by being retried.
+type Status string
+
+const (
+	// StatusNone is the status of messages with no tracked delivery state,
+	// such as those received from other users.
+	StatusNone      Status = ""
+	StatusPending   Status = "pending"
+	StatusSent      Status = "sent"
+	StatusDelivered Status = "delivered"
+	StatusRead      Status = "read"
+	StatusFailed    Status = "failed"
+)
+
+// rank orders the states of a successful delivery.
+func (s Status) rank() int {
+	switch s {
+	case StatusPending:
+		return 1
+	case StatusSent:
+		return 2
+	case StatusDelivered:
+		return 3
+	case StatusRead:
+		return 4
+	}
+	return 0
+}
+
+// CanAdvance reports whether a message may move from status s to status
+// to. Delivery only ever moves forward, so that late or reordered acks
+// cannot regress a message.
+func (s Status) CanAdvance(to Status) bool {
+	switch {
+	case s == to:
+		return false
+	case s == StatusNone:
+		return true
+	case s == StatusFailed:
+		return to == StatusPending
+	case to == StatusFailed:
+		return s == StatusPending
+	}
+	return to.rank() > s.rank()
+}
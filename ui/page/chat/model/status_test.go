@@ -0,0 +1,30 @@
+package model
+
+import "testing"
+
+func TestStatusCanAdvance(t *testing.T) {
+	type testcase struct {
+		from, to Status
+		want     bool
+	}
+	for _, tc := range []testcase{
+		{from: StatusNone, to: StatusPending, want: true},
+		{from: StatusPending, to: StatusSent, want: true},
+		{from: StatusPending, to: StatusFailed, want: true},
+		{from: StatusPending, to: StatusRead, want: true},
+		{from: StatusSent, to: StatusDelivered, want: true},
+		{from: StatusSent, to: StatusRead, want: true},
+		{from: StatusDelivered, to: StatusRead, want: true},
+		{from: StatusFailed, to: StatusPending, want: true},
+		{from: StatusPending, to: StatusPending, want: false},
+		{from: StatusDelivered, to: StatusSent, want: false},
+		{from: StatusRead, to: StatusDelivered, want: false},
+		{from: StatusSent, to: StatusFailed, want: false},
+		{from: StatusFailed, to: StatusSent, want: false},
+		{from: StatusRead, to: StatusPending, want: false},
+	} {
+		if got := tc.from.CanAdvance(tc.to); got != tc.want {
+			t.Errorf("%q -> %q: expected %v, got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+}
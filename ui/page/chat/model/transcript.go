@@ -0,0 +1,32 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Transcript returns the text of msgs in the order they were sent, each
+// under a header naming its sender and when it was sent, in local time.
+// Messages without content are presented by their preview.
+func Transcript(msgs []Message) string {
+	sorted := append([]Message(nil), msgs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return SerialLessThan(sorted[i].Serial(), sorted[j].Serial())
+	})
+	var b strings.Builder
+	for i, m := range sorted {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(m.Sender)
+		b.WriteByte(' ')
+		b.WriteString(m.SentAt.Local().Format("2006-01-02 15:04"))
+		b.WriteByte('\n')
+		if m.Content != "" && !m.Recalled {
+			b.WriteString(m.Content)
+		} else {
+			b.WriteString(m.Preview())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTranscript(t *testing.T) {
+	sent := time.Date(2023, 5, 1, 12, 0, 0, 0, time.Local)
+	msgs := []Message{
+		{SerialID: "10", Sender: "bob", SentAt: sent.Add(time.Minute), Attachment: &Attachment{Name: "a.png", MIME: "image/png"}},
+		{SerialID: "9", Sender: "alice", SentAt: sent, Content: "**hi**\nthere"},
+		{SerialID: "11", Sender: "alice", SentAt: sent.Add(2 * time.Minute), Recalled: true},
+	}
+	want := "alice 2023-05-01 12:00\n**hi**\nthere\n\n" +
+		"bob 2023-05-01 12:01\n[图片]\n\n" +
+		"alice 2023-05-01 12:02\nalice recalled a message"
+	if got := Transcript(msgs); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if msgs[0].SerialID != "10" {
+		t.Errorf("expected msgs to be left unsorted")
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	// MaxVoiceDuration is how long voice messages can last. Recording
+	// stops when it is reached.
+	MaxVoiceDuration = time.Minute
+	// MinVoiceDuration is how long voice messages must last. Shorter
+	// recordings are discarded.
+	MinVoiceDuration = time.Second
+)
+
+// Voice describes the recording of a voice message.
+type Voice struct {
+	// Duration of the recording.
+	Duration time.Duration
+	// Waveform holds the levels of the recording over spans of even
+	// length, from 0 for silence to 255 for the loudest.
+	Waveform []uint8
+}
+
+// Seconds returns the duration of the recording in whole seconds, rounded
+// up, as voice messages present it.
+func (v Voice) Seconds() int {
+	return int((v.Duration + time.Second - 1) / time.Second)
+}
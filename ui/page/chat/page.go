@@ -1,9 +1,17 @@
 package chat
 
 import (
+	"log"
+	"path/filepath"
 	"wechat_ui/app"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/components"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/prefs"
 	"wechat_ui/ui/page/chat/ui"
+
+	giouiApp "gioui.org/app"
+	"gioui.org/io/key"
 )
 
 const PageID = "chat"
@@ -14,21 +22,31 @@ type Page struct {
 }
 
 func (p *Page) OnNavigatedTo() {
+	p.ui.SetShown(true)
 }
 
 func (p *Page) OnNavigatedFrom() {
+	p.ui.SetShown(false)
 }
 
-func NewPage() *Page {
+// NewPage creates the chat page presenting the rooms of the provided
+// backend, following the provided settings of the local user. Incoming
+// messages are presented as toasts.
+func NewPage(b backend.Backend, settings *prefs.Settings, toasts *components.Toasts) *Page {
 	pm := app.NewGenericPageModal(PageID)
+	conf := ui.Config{
+		BufferSize: 30,
+		Settings:   settings,
+		Toasts:     toasts,
+	}
+	if dir, err := giouiApp.DataDir(); err != nil {
+		log.Printf("finding application data dir: %v", err)
+	} else {
+		conf.DataDir = filepath.Join(dir, "wechat_ui")
+	}
 	page := &Page{
 		GenericPageModal: pm,
-		ui: ui.NewUI(assets.Window.Invalidate, ui.Config{
-			Theme:      "light",
-			Latency:    1000,
-			LoadSize:   30,
-			BufferSize: 30,
-		}),
+		ui:               ui.NewUI(assets.Window.Invalidate, conf, b),
 	}
 
 	return page
@@ -36,3 +54,26 @@ func NewPage() *Page {
 
 func (p *Page) HandleUserInteractions() {
 }
+
+// KeysToHandle returns the shortcuts of the chat ui.
+// Part of the app.KeyEventHandler interface.
+func (p *Page) KeysToHandle() key.Set {
+	return ui.Keys
+}
+
+// HandleKeyPress forwards evt to the chat ui.
+// Part of the app.KeyEventHandler interface.
+func (p *Page) HandleKeyPress(evt *key.Event) {
+	p.ui.HandleKeyPress(evt)
+}
+
+// OpenRoom makes the named room active, creating it if needed.
+func (p *Page) OpenRoom(name string) {
+	p.ui.OpenRoom(name)
+}
+
+// Unread returns the number of messages the local user has not read across
+// all rooms.
+func (p *Page) Unread() int {
+	return p.ui.Rooms.Unread()
+}
@@ -0,0 +1,95 @@
+/*
+Package prefs persists the preferences of the local user that only concern
+this client, such as which rooms are pinned to the top of the room list.
+*/
+package prefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Room holds the preferences of the local user for a room.
+type Room struct {
+	// Pinned rooms are listed ahead of the others.
+	Pinned bool `json:"pinned,omitempty"`
+	// Muted rooms present their unread messages without a count.
+	Muted bool `json:"muted,omitempty"`
+}
+
+// Rooms holds the preferences of a user for each room. It is safe for
+// concurrent use.
+type Rooms struct {
+	// path of the file the preferences are persisted in. Empty keeps them
+	// in memory only.
+	path string
+
+	mu    sync.Mutex
+	rooms map[string]Room
+}
+
+// OpenRooms returns the room preferences of user, persisted in a file of
+// dir. An empty dir keeps them in memory only. A missing file yields the
+// default preferences for every room.
+func OpenRooms(dir, user string) (*Rooms, error) {
+	r := &Rooms{rooms: make(map[string]Room)}
+	if dir == "" {
+		return r, nil
+	}
+	r.path = filepath.Join(dir, "rooms-"+url.PathEscape(user)+".json")
+	b, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("reading room preferences: %w", err)
+	}
+	if err := json.Unmarshal(b, &r.rooms); err != nil {
+		return r, fmt.Errorf("decoding room preferences: %w", err)
+	}
+	return r, nil
+}
+
+// Get returns the preferences for the named room.
+func (r *Rooms) Get(name string) Room {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rooms[name]
+}
+
+// Set replaces the preferences for the named room.
+func (r *Rooms) Set(name string, room Room) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if room == (Room{}) {
+		delete(r.rooms, name)
+		return
+	}
+	r.rooms[name] = room
+}
+
+// Save persists the preferences, if they have a file.
+func (r *Rooms) Save() error {
+	if r.path == "" {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.Marshal(r.rooms)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding room preferences: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("saving room preferences: %w", err)
+	}
+	if err := os.WriteFile(r.path, b, 0o644); err != nil {
+		return fmt.Errorf("saving room preferences: %w", err)
+	}
+	return nil
+}
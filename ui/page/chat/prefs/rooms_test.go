@@ -0,0 +1,60 @@
+package prefs
+
+import (
+	"testing"
+)
+
+func TestRooms(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRooms(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Get("general"); got != (Room{}) {
+		t.Errorf("expected default preferences, got %+v", got)
+	}
+	r.Set("general", Room{Pinned: true})
+	r.Set("random", Room{Muted: true})
+	r.Set("other", Room{Pinned: true})
+	r.Set("other", Room{})
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenRooms(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]Room{
+		"general": {Pinned: true},
+		"random":  {Muted: true},
+		"other":   {},
+	} {
+		if got := r.Get(name); got != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+	}
+	if len(r.rooms) != 2 {
+		t.Errorf("expected default preferences not to be stored, got %v", r.rooms)
+	}
+	other, err := OpenRooms(dir, "user2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Get("general"); got != (Room{}) {
+		t.Errorf("expected preferences to be kept per user, got %+v", got)
+	}
+}
+
+func TestRoomsInMemory(t *testing.T) {
+	r, err := OpenRooms("", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Set("general", Room{Muted: true})
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Get("general"); !got.Muted {
+		t.Errorf("expected muted room, got %+v", got)
+	}
+}
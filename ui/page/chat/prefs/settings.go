@@ -0,0 +1,124 @@
+package prefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"wechat_ui/ui/pkg/notify"
+)
+
+// SendKey enumerates the keys that send the message being composed. The
+// other of Enter and Short-Enter inserts a line break, as does Shift-Enter.
+type SendKey int
+
+const (
+	// SendEnter sends messages with Enter.
+	SendEnter SendKey = iota
+	// SendShortEnter sends messages with Short-Enter: Ctrl-Enter, or
+	// Cmd-Enter on macOS.
+	SendShortEnter
+)
+
+// Appearance enumerates the palettes the application is presented with.
+type Appearance int
+
+const (
+	// AppearanceLight presents dark text on light backgrounds.
+	AppearanceLight Appearance = iota
+	// AppearanceDark presents light text on dark backgrounds.
+	AppearanceDark
+	// AppearanceSystem follows the preference of the system.
+	AppearanceSystem
+)
+
+// Values holds the settings of the local user for this client. The zero
+// value holds the defaults.
+type Values struct {
+	// SendKey is the key that sends the message being composed.
+	SendKey SendKey `json:"sendKey,omitempty"`
+	// NotificationsOff stops notifying incoming messages, and HidePreview
+	// leaves their content out of the notifications.
+	NotificationsOff bool `json:"notificationsOff,omitempty"`
+	HidePreview      bool `json:"hidePreview,omitempty"`
+	// DoNotDisturb is when incoming messages are not notified.
+	DoNotDisturb notify.Schedule `json:"doNotDisturb"`
+	// Appearance is the palette the application is presented with.
+	Appearance Appearance `json:"appearance,omitempty"`
+}
+
+// Settings holds the settings of a user. It is safe for concurrent use.
+type Settings struct {
+	// path of the file the settings are persisted in. Empty keeps them in
+	// memory only.
+	path string
+
+	mu     sync.Mutex
+	values Values
+	// saveMu serializes saves, so that an older snapshot of the values
+	// cannot overwrite a newer one.
+	saveMu sync.Mutex
+}
+
+// OpenSettings returns the settings of user, persisted in a file of dir.
+// An empty dir keeps them in memory only. A missing file yields the
+// default settings.
+func OpenSettings(dir, user string) (*Settings, error) {
+	s := &Settings{}
+	if dir == "" {
+		return s, nil
+	}
+	s.path = filepath.Join(dir, "settings-"+url.PathEscape(user)+".json")
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("reading settings: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.values); err != nil {
+		return s, fmt.Errorf("decoding settings: %w", err)
+	}
+	return s, nil
+}
+
+// Get returns the settings.
+func (s *Settings) Get() Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values
+}
+
+// Set replaces the settings.
+func (s *Settings) Set(v Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = v
+}
+
+// Save persists the settings, if they have a file. Concurrent saves are
+// written one at a time, each with the settings current when it starts.
+func (s *Settings) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	b, err := json.Marshal(s.values)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding settings: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("saving settings: %w", err)
+	}
+	if err := os.WriteFile(s.path, b, 0o644); err != nil {
+		return fmt.Errorf("saving settings: %w", err)
+	}
+	return nil
+}
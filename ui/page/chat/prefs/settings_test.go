@@ -0,0 +1,43 @@
+package prefs
+
+import (
+	"testing"
+	"time"
+	"wechat_ui/ui/pkg/notify"
+)
+
+func TestSettings(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSettings(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(); got != (Values{}) || got.SendKey != SendEnter {
+		t.Errorf("expected default settings, got %+v", got)
+	}
+	quiet := notify.Schedule{Enabled: true, Start: 22 * time.Hour, End: 8 * time.Hour}
+	want := Values{
+		SendKey:      SendShortEnter,
+		HidePreview:  true,
+		DoNotDisturb: quiet,
+		Appearance:   AppearanceDark,
+	}
+	s.Set(want)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = OpenSettings(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(); got != want {
+		t.Errorf("expected saved settings, got %+v", got)
+	}
+	other, err := OpenSettings(dir, "user2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Get(); got != (Values{}) {
+		t.Errorf("expected settings to be kept per user, got %+v", got)
+	}
+}
@@ -0,0 +1,681 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// DefaultTimeout bounds how long a request waits for its ack when no
+// timeout is configured.
+const DefaultTimeout = 10 * time.Second
+
+// Config configures a Client.
+type Config struct {
+	// URL of the server, using the ws or wss scheme.
+	URL string
+	// User to authenticate as.
+	User string
+	// Token authenticating the user.
+	Token string
+	// LoadSize specifies maximum number of messages to request at a time.
+	// Zero lets the server decide.
+	LoadSize int
+	// Timeout bounds how long a request waits for its ack. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+	// FetchImage fetches the room avatar at the given url. Nil leaves
+	// rooms without an image.
+	FetchImage func(url string) image.Image
+}
+
+// Client speaks the protocol to a server, and implements backend.Backend
+// on top of it.
+type Client struct {
+	conf  Config
+	conn  *wsConn
+	users *model.Users
+	local *model.User
+	rooms *model.Rooms
+	// createMu serializes adding rooms created after connecting.
+	createMu sync.Mutex
+
+	events chan backend.Event
+	done   chan struct{}
+	// closing is closed by Close to unblock the delivery of pushes.
+	closing   chan struct{}
+	closeOnce sync.Once
+	// err is the reason the connection ended, valid once done is closed.
+	err error
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[string]chan Envelope
+	// lastSerial is the last serial handed out by newSerial.
+	lastSerial int64
+	// outbox holds the messages composed by the local user that the
+	// server has not acknowledged.
+	outbox map[messageKey]model.Message
+	// sent holds the messages sent in this session whose status may still
+	// advance. Messages are tracked from before their ack arrives, as a
+	// status push may overtake it.
+	sent map[messageKey]model.Message
+}
+
+// messageKey identifies a message across rooms.
+type messageKey struct {
+	room   string
+	serial list.Serial
+}
+
+var _ backend.Backend = (*Client)(nil)
+
+// Dial connects to the server, authenticates and fetches the room list.
+func Dial(ctx context.Context, conf Config) (*Client, error) {
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultTimeout
+	}
+	conn, err := dialWS(ctx, conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", conf.URL, err)
+	}
+	c := &Client{
+		conf:    conf,
+		conn:    conn,
+		events:  make(chan backend.Event, 256),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+		pending: make(map[string]chan Envelope),
+		outbox:  make(map[messageKey]model.Message),
+		sent:    make(map[messageKey]model.Message),
+	}
+	go c.readLoop()
+	if err := c.init(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// init authenticates and populates the directory of users and rooms.
+func (c *Client) init(ctx context.Context) error {
+	var auth AuthReply
+	if err := c.request(ctx, TypeAuth, AuthRequest{User: c.conf.User, Token: c.conf.Token}, &auth); err != nil {
+		return fmt.Errorf("authenticating: %w", err)
+	}
+	c.users = &model.Users{}
+	for _, u := range auth.Users {
+		c.users.Add(u)
+	}
+	if local, ok := c.users.Lookup(auth.User.Name); ok {
+		c.local = local
+	} else {
+		c.users.Add(auth.User)
+		c.local, _ = c.users.Lookup(auth.User.Name)
+	}
+	var rooms RoomsReply
+	if err := c.request(ctx, TypeRooms, nil, &rooms); err != nil {
+		return fmt.Errorf("listing rooms: %w", err)
+	}
+	c.rooms = &model.Rooms{}
+	for _, info := range rooms.Rooms {
+		c.addRoom(info)
+	}
+	return nil
+}
+
+// addRoom adds a room received from the server.
+func (c *Client) addRoom(info RoomInfo) {
+	var img image.Image
+	if c.conf.FetchImage != nil && info.Avatar != "" {
+		img = c.conf.FetchImage(info.Avatar)
+	}
+	c.rooms.Add(model.Room{
+		Name:     info.Name,
+		Image:    img,
+		Latest:   info.Latest,
+		Unread:   info.Unread,
+		Mentions: info.Mentions,
+		Group:    info.Group,
+	})
+}
+
+// readLoop dispatches incoming envelopes until the connection ends.
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+		close(c.done)
+		close(c.events)
+	}()
+	for {
+		data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			log.Printf("protocol client: decoding envelope: %v", err)
+			continue
+		}
+		if env.Type == TypeAck {
+			c.mu.Lock()
+			ch, ok := c.pending[env.ID]
+			delete(c.pending, env.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- env
+			}
+			continue
+		}
+		if e, err := c.toEvent(env); err != nil {
+			log.Printf("protocol client: %v", err)
+		} else if e != nil {
+			select {
+			case c.events <- e:
+			case <-c.closing:
+				c.err = ErrClosed
+				return
+			}
+		}
+	}
+}
+
+// toEvent converts a push into the backend event it represents. Unknown
+// pushes are ignored so that servers can extend the protocol.
+func (c *Client) toEvent(env Envelope) (backend.Event, error) {
+	switch env.Type {
+	case TypeStatus:
+		var body StatusBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return c.advance(body), nil
+	case TypeMessage:
+		var body MessageBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return backend.MessageEvent{Room: body.Room, Message: body.Message}, nil
+	case TypeUpdated:
+		var body MessageBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		c.track(body.Room, body.Message)
+		return backend.UpdateEvent{Room: body.Room, Message: body.Message}, nil
+	case TypeReacted:
+		var body MessageBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		c.track(body.Room, body.Message)
+		return backend.ReactionEvent{Room: body.Room, Message: body.Message}, nil
+	case TypeDeleted:
+		var body DeleteBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return backend.DeleteEvent{Room: body.Room, Serial: body.Serial}, nil
+	case TypeTyping:
+		var body TypingBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return backend.ComposingEvent{Room: body.Room, User: body.User, Composing: body.Composing}, nil
+	case TypeRead:
+		var body ReadBody
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return backend.ReadEvent{Room: body.Room, User: body.User, Serial: body.Serial}, nil
+	case TypeGroup:
+		var body GroupReply
+		if err := env.decode(&body); err != nil {
+			return nil, err
+		}
+		return backend.GroupEvent{Room: body.Room, Group: body.Group}, nil
+	}
+	return nil, nil
+}
+
+// request sends a request and waits for its ack, decoding the ack body
+// into reply if it is not nil.
+func (c *Client) request(ctx context.Context, t Type, body, reply interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	ch := make(chan Envelope, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	cancel := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+	env, err := newEnvelope(t, id, body)
+	if err != nil {
+		cancel()
+		return err
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("encoding envelope: %w", err)
+	}
+	if err := c.conn.WriteMessage(data); err != nil {
+		cancel()
+		return err
+	}
+	ctx, stop := context.WithTimeout(ctx, c.conf.Timeout)
+	defer stop()
+	select {
+	case ack, ok := <-ch:
+		if !ok {
+			return c.closedErr()
+		}
+		if ack.Error != "" {
+			return errors.New(ack.Error)
+		}
+		if reply != nil {
+			return ack.decode(reply)
+		}
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return fmt.Errorf("waiting for %s ack: %w", t, ctx.Err())
+	}
+}
+
+// advance applies a status push to a message sent in this session,
+// returning the resulting event or nil if the push is stale.
+func (c *Client) advance(body StatusBody) backend.Event {
+	key := messageKey{room: body.Room, serial: body.Serial}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg, ok := c.sent[key]
+	if !ok || !msg.Status.CanAdvance(body.Status) {
+		return nil
+	}
+	msg.Status = body.Status
+	if body.Status == model.StatusRead {
+		delete(c.sent, key)
+	} else {
+		c.sent[key] = msg
+	}
+	return backend.StatusEvent{Room: body.Room, Message: msg}
+}
+
+// closedErr reports why the connection ended.
+func (c *Client) closedErr() error {
+	<-c.done
+	if c.err == nil || errors.Is(c.err, ErrClosed) {
+		return ErrClosed
+	}
+	return fmt.Errorf("connection lost: %w", c.err)
+}
+
+// newSerial returns a serial for a message composed now. Serials are
+// strictly increasing even if the clock is not.
+func (c *Client) newSerial() list.Serial {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := time.Now().UnixNano()
+	if n <= c.lastSerial {
+		n = c.lastSerial + 1
+	}
+	c.lastSerial = n
+	return list.Serial(strconv.FormatInt(n, 10))
+}
+
+// Users returns the directory received on authentication.
+func (c *Client) Users() *model.Users {
+	return c.users
+}
+
+// Local returns the authenticated user.
+func (c *Client) Local() *model.User {
+	return c.local
+}
+
+// Rooms returns the rooms received on connection.
+func (c *Client) Rooms() []*model.Room {
+	return c.rooms.List()
+}
+
+// CreateRoom asks the server for the named room, creating it if needed.
+func (c *Client) CreateRoom(name string) (*model.Room, error) {
+	if room, ok := c.rooms.Lookup(name); ok {
+		return room, nil
+	}
+	var info RoomInfo
+	if err := c.request(context.Background(), TypeCreate, CreateRequest{Name: name}, &info); err != nil {
+		return nil, fmt.Errorf("creating room: %w", err)
+	}
+	c.createMu.Lock()
+	defer c.createMu.Unlock()
+	if room, ok := c.rooms.Lookup(info.Name); ok {
+		return room, nil
+	}
+	c.addRoom(info)
+	room, _ := c.rooms.Lookup(info.Name)
+	return room, nil
+}
+
+// Load requests a page of history from the server.
+// Failures are logged and reported as the end of history, as the
+// list.Loader contract has no error channel.
+func (c *Client) Load(room string, dir list.Direction, relativeTo list.Serial) ([]list.Element, bool) {
+	var reply HistoryReply
+	err := c.request(context.Background(), TypeHistory, HistoryRequest{
+		Room:       room,
+		Direction:  directionString(dir),
+		RelativeTo: relativeTo,
+		Limit:      c.conf.LoadSize,
+	}, &reply)
+	if err != nil {
+		// Carry on with an empty page, so that the outbox is still
+		// presented while offline.
+		log.Printf("loading history of %q: %v", room, err)
+		reply = HistoryReply{}
+	}
+	elems := make([]list.Element, len(reply.Messages))
+	for ii := range reply.Messages {
+		elems[ii] = reply.Messages[ii]
+	}
+	if relativeTo == list.NoSerial || (dir == list.After && !reply.More) {
+		// The page reaches the present, which is where the unacknowledged
+		// messages belong.
+		after := relativeTo
+		if relativeTo == list.NoSerial && reply.More && len(elems) > 0 {
+			after = elems[0].Serial()
+		}
+		elems = c.withOutbox(room, elems, after)
+	}
+	return elems, reply.More
+}
+
+// LoadAround requests the page of history surrounding a message from the
+// server. Failures are logged and reported as an empty history.
+func (c *Client) LoadAround(room string, serial list.Serial) ([]list.Element, bool, bool) {
+	var reply HistoryReply
+	err := c.request(context.Background(), TypeHistory, HistoryRequest{
+		Room:       room,
+		Direction:  DirectionAround,
+		RelativeTo: serial,
+		Limit:      c.conf.LoadSize,
+	}, &reply)
+	if err != nil {
+		log.Printf("loading history of %q around %v: %v", room, serial, err)
+		reply = HistoryReply{}
+	}
+	elems := make([]list.Element, len(reply.Messages))
+	for ii := range reply.Messages {
+		elems[ii] = reply.Messages[ii]
+	}
+	if !reply.MoreAfter {
+		after := list.NoSerial
+		if reply.More && len(elems) > 0 {
+			after = elems[0].Serial()
+		}
+		elems = c.withOutbox(room, elems, after)
+	}
+	return elems, reply.More, reply.MoreAfter
+}
+
+// withOutbox merges the unacknowledged messages of the room that sort after
+// the provided serial into elems, which reach the present. All of them are
+// merged if after is NoSerial.
+func (c *Client) withOutbox(room string, elems []list.Element, after list.Serial) []list.Element {
+	c.mu.Lock()
+	for key, msg := range c.outbox {
+		if key.room == room && (after == list.NoSerial || model.SerialLessThan(after, key.serial)) {
+			elems = append(elems, msg)
+		}
+	}
+	c.mu.Unlock()
+	sort.SliceStable(elems, func(i, j int) bool {
+		return model.RowLessThan(elems[i], elems[j])
+	})
+	return elems
+}
+
+// Compose prepares a pending message from the local user, serialized with
+// the current time.
+func (c *Client) Compose(room, content string) model.Message {
+	return model.Message{
+		SerialID: string(c.newSerial()),
+		Sender:   c.local.Name,
+		Avatar:   c.local.Avatar,
+		Content:  content,
+		SentAt:   time.Now(),
+		Status:   model.StatusPending,
+		Read:     true,
+	}
+}
+
+// Send sends a message from the local user and waits for the server to
+// acknowledge it. Until then the message is kept in the outbox, where it
+// remains as failed if the server rejects it or the ack never arrives.
+func (c *Client) Send(room string, msg model.Message) (model.Message, error) {
+	key := messageKey{room: room, serial: msg.Serial()}
+	msg.Status = model.StatusPending
+	c.mu.Lock()
+	c.outbox[key] = msg
+	c.sent[key] = msg
+	c.mu.Unlock()
+	var reply MessageBody
+	if err := c.request(context.Background(), TypeSend, MessageBody{Room: room, Message: msg}, &reply); err != nil {
+		msg.Status = model.StatusFailed
+		c.mu.Lock()
+		if _, ok := c.outbox[key]; ok {
+			c.outbox[key] = msg
+		}
+		delete(c.sent, key)
+		c.mu.Unlock()
+		return msg, fmt.Errorf("sending message: %w", err)
+	}
+	reply.Message.Read = true
+	c.mu.Lock()
+	delete(c.outbox, key)
+	if current, ok := c.sent[key]; ok && !current.Status.CanAdvance(reply.Message.Status) {
+		reply.Message.Status = current.Status
+	}
+	if reply.Message.Status == model.StatusRead {
+		delete(c.sent, key)
+	} else {
+		c.sent[key] = reply.Message
+	}
+	c.mu.Unlock()
+	return reply.Message, nil
+}
+
+// Attach uploads the local file described by a to the server. The
+// returned attachment refers to the file as served by the server.
+// Attachments that are not local are returned as is.
+func (c *Client) Attach(room string, a model.Attachment) (model.Attachment, error) {
+	path, ok := backend.LocalPath(a.URL)
+	if !ok {
+		return a, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return a, fmt.Errorf("attaching file: %w", err)
+	}
+	if len(data) > backend.MaxAttachmentSize {
+		return a, fmt.Errorf("attaching file: %s exceeds %d bytes", a.Name, backend.MaxAttachmentSize)
+	}
+	var reply UploadReply
+	err = c.request(context.Background(), TypeUpload, UploadRequest{
+		Room: room,
+		Name: a.Name,
+		MIME: a.MIME,
+		Data: data,
+	}, &reply)
+	if err != nil {
+		return a, fmt.Errorf("attaching file: %w", err)
+	}
+	u, err := c.fileURL(reply.ID)
+	if err != nil {
+		return a, fmt.Errorf("attaching file: %w", err)
+	}
+	a.URL = u
+	a.Size = int64(len(data))
+	return a, nil
+}
+
+// fileURL returns the URL the server serves the uploaded file with the
+// given ID at.
+func (c *Client) fileURL(id string) (string, error) {
+	u, err := url.Parse(c.conf.URL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	q := u.Query()
+	q.Set("file", id)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Delete asks the server to remove a message. Messages that never made it
+// to the server are only dropped from the outbox.
+func (c *Client) Delete(room string, serial list.Serial) error {
+	key := messageKey{room: room, serial: serial}
+	c.mu.Lock()
+	msg, queued := c.outbox[key]
+	delete(c.outbox, key)
+	delete(c.sent, key)
+	c.mu.Unlock()
+	if queued && msg.Status == model.StatusFailed {
+		return nil
+	}
+	if err := c.request(context.Background(), TypeDelete, DeleteBody{Room: room, Serial: serial}, nil); err != nil {
+		return fmt.Errorf("deleting message: %w", err)
+	}
+	return nil
+}
+
+// Edit replaces the content of a message sent by the local user.
+func (c *Client) Edit(room string, serial list.Serial, content string) (model.Message, error) {
+	var reply MessageBody
+	req := EditBody{Room: room, Serial: serial, Content: content}
+	if err := c.request(context.Background(), TypeEdit, req, &reply); err != nil {
+		return model.Message{}, fmt.Errorf("editing message: %w", err)
+	}
+	c.track(room, reply.Message)
+	return reply.Message, nil
+}
+
+// Recall recalls a message sent by the local user.
+func (c *Client) Recall(room string, serial list.Serial) (model.Message, error) {
+	var reply MessageBody
+	if err := c.request(context.Background(), TypeRecall, RecallBody{Room: room, Serial: serial}, &reply); err != nil {
+		return model.Message{}, fmt.Errorf("recalling message: %w", err)
+	}
+	c.track(room, reply.Message)
+	return reply.Message, nil
+}
+
+// React adds the reaction of the local user with emoji to a message, or
+// removes it if add is false.
+func (c *Client) React(room string, serial list.Serial, emoji string, add bool) (model.Message, error) {
+	var reply MessageBody
+	req := ReactBody{Room: room, Serial: serial, Emoji: emoji, Add: add}
+	if err := c.request(context.Background(), TypeReact, req, &reply); err != nil {
+		return model.Message{}, fmt.Errorf("reacting to message: %w", err)
+	}
+	c.track(room, reply.Message)
+	return reply.Message, nil
+}
+
+// track keeps a message sent in this session up to date with the changes
+// made to it after it was sent, such as reactions and edits, so that its
+// status events carry them.
+func (c *Client) track(room string, msg model.Message) {
+	key := messageKey{room: room, serial: msg.Serial()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sent, ok := c.sent[key]; ok {
+		msg.Status = sent.Status
+		c.sent[key] = msg
+	}
+}
+
+// ChangeGroup asks the server to change the members or settings of a room
+// on behalf of the local user.
+func (c *Client) ChangeGroup(room string, change model.GroupChange) (model.Group, model.Message, error) {
+	var reply GroupReply
+	if err := c.request(context.Background(), TypeGroup, GroupBody{Room: room, Change: change}, &reply); err != nil {
+		return model.Group{}, model.Message{}, fmt.Errorf("changing group: %w", err)
+	}
+	if reply.Notice == nil {
+		return model.Group{}, model.Message{}, errors.New("changing group: ack without notice")
+	}
+	return reply.Group, *reply.Notice, nil
+}
+
+// Typing tells the other participants of the room whether the local user
+// is composing a message.
+func (c *Client) Typing(room string, composing bool) error {
+	if err := c.request(context.Background(), TypeTyping, TypingBody{Room: room, Composing: composing}, nil); err != nil {
+		return fmt.Errorf("sending typing state: %w", err)
+	}
+	return nil
+}
+
+// MarkRead tells the other participants of the room that the local user
+// has read it up to and including serial. It returns the number of
+// messages of the room that remain unread.
+func (c *Client) MarkRead(room string, serial list.Serial) (int, error) {
+	var reply ReadReply
+	if err := c.request(context.Background(), TypeRead, ReadBody{Room: room, Serial: serial}, &reply); err != nil {
+		return 0, fmt.Errorf("sending read receipt: %w", err)
+	}
+	return reply.Unread, nil
+}
+
+// Subscribe returns the channel pushes from the server are delivered on.
+// The channel is closed when the connection ends. It must be drained, or
+// acks queued behind pushes will time out.
+func (c *Client) Subscribe() <-chan backend.Event {
+	return c.events
+}
+
+// Done is closed when the connection ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		err = c.conn.Close()
+	})
+	<-c.done
+	return err
+}
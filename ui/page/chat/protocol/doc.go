@@ -0,0 +1,130 @@
+/*
+Package protocol implements the chat wire protocol: a client that fulfills
+the backend.Backend contract over the network, and an in-process reference
+server.
+
+# Transport
+
+The protocol runs over a single WebSocket (RFC 6455) connection. Every
+websocket message is a UTF-8 text frame holding exactly one JSON envelope:
+
+	{"type": "...", "id": "...", "error": "...", "body": {...}}
+
+The envelope fields are:
+
+  - type names the kind of envelope, see below.
+  - id correlates a request with its acknowledgement. Clients choose ids;
+    they must be unique among the requests in flight on a connection.
+    Pushes carry no id.
+  - error is only set on acknowledgements of failed requests.
+  - body holds the type specific payload.
+
+# Requests
+
+Requests are sent by the client. The server answers each one with an "ack"
+envelope carrying the same id, and either the reply body or an error.
+Requests may be pipelined; acks are not guaranteed to arrive in order.
+
+	type     request body                                ack body
+	auth     {user, token}                               {user, users}
+	rooms    -                                           {rooms: [{name, avatar, latest, unread, mentions, group}]}
+	create   {name, avatar}                              {name, avatar, latest, group}
+	history  {room, direction, relativeTo, limit}        {messages, more, moreAfter}
+	send     {room, message}                             {room, message}
+	delete   {room, serial}                              -
+	typing   {room, composing}                           -
+	read     {room, serial}                              {unread}
+	upload   {room, name, mime, data}                    {id}
+	react    {room, serial, emoji, add}                  {room, message}
+	edit     {room, serial, content}                     {room, message}
+	recall   {room, serial}                              {room, message}
+	group    {room, change}                              {room, group, notice}
+
+auth must be the first request on a connection; the server rejects anything
+else until it succeeds and closes the connection if it fails. The ack
+carries the authenticated user and the directory of all users.
+
+create registers a room open to every user, and is acknowledged with the
+room's description. Creating a room that already exists is not an error;
+the ack then describes the existing room, unless it is a group the user is
+not a member of.
+
+A room is either open, or a group: it then has members, and only they can
+see it. Requests about a room the user cannot see fail as if it did not
+exist. Members are an owner, admins and plain members, encoded as
+model.Group. group applies a model.GroupChange on behalf of the
+authenticated user: renaming the room, changing its announcement, adding
+or removing members, changing their roles, or leaving. The owner and admins
+can rename, announce and add; the owner can remove anyone and admins can
+remove plain members. Only the owner can promote members to admin and
+demote admins.
+Adding members to an open room makes it a group owned by the user. The ack
+carries the updated group and a notice, a message with system set that
+records the change in the room; the notice is also pushed as a message.
+
+history pages through a room's messages. direction is "before" or "after"
+and relativeTo is the serial to page from; an empty relativeTo requests the
+most recent page. Messages are returned oldest first, and more reports
+whether further messages exist in the requested direction. direction may
+also be "around", which requests the page surrounding relativeTo so that
+clients can jump deep into history; more then reports whether messages exist
+before the page, and moreAfter whether messages exist after it.
+
+Message serials are assigned by the sender and must be unique within a
+room. They are decimal integers; messages are ordered by serial. The client
+in this package uses the Unix time in nanoseconds at which the message was
+composed. The server stamps the message with the authenticated sender and
+the sent status before storing it. A send whose serial is already taken is
+rejected, unless it repeats the stored message, in which case it is
+acknowledged again; clients may therefore safely retry sends whose ack was
+lost.
+
+read records that the authenticated user has read the room up to and
+including serial; read marks never move backwards. The ack carries the
+number of messages of other users in the room that remain unread, which
+rooms also reports for each room, along with the serials of the unread
+messages that mention the user. A message mentions the users named in its
+mentions, which the content refers to as "@" followed by the name.
+Whether a message was read is specific to each user: messages are returned
+by history and pushed with read set accordingly. The reference server keeps read marks in memory.
+
+upload stores a file to be attached to messages; data holds its contents
+encoded in base64. The server serves the file over HTTP, at the URL it is
+mounted on with the query parameter file set to the id of the ack, and
+messages refer to it by that URL in their attachment. The reference server
+keeps uploaded files in memory.
+
+react adds the reaction of the authenticated user with an emoji to a
+message, or removes it if add is false. Reacting twice with the same emoji
+is not an error. The ack carries the message with its updated reactions.
+
+edit replaces the content of a text message and stamps it with the time of
+the edit; recall drops the contents of a message, which remains in the room
+as a notice that it was recalled. Only the sender of a message can edit or
+recall it, within a window of sending it that defaults to two minutes. The
+acks carry the updated message.
+
+# Pushes
+
+The server pushes changes made by other connections as envelopes without
+an id:
+
+	type     body
+	message  {room, message}           a message was sent to the room
+	deleted  {room, serial}            a message was removed from the room
+	typing   {room, user, composing}   a user started or stopped composing
+	read     {room, user, serial}      a user read the room up to serial
+	status   {room, serial, status}    the delivery status of a message changed
+	reacted  {room, message}           the reactions to a message changed
+	updated  {room, message}           a message was edited or recalled
+	group    {room, group}             the members or settings of a room changed
+
+Pushes are not echoed back to the connection whose request caused them,
+except for status, which is pushed to every connection of the message's
+sender. Pushes about a room only go to the users that can see it, but group
+pushes also go to the members a change removed. A sent message becomes delivered once it is pushed to another
+connection, and read once another user sends a read receipt covering it.
+Statuses only ever advance: pending, sent, delivered, read.
+Messages are encoded as model.Message.
+*/
+package protocol
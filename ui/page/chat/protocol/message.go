@@ -0,0 +1,250 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// Type names the kind of an envelope.
+type Type string
+
+const (
+	TypeAuth    Type = "auth"
+	TypeRooms   Type = "rooms"
+	TypeCreate  Type = "create"
+	TypeHistory Type = "history"
+	TypeSend    Type = "send"
+	TypeDelete  Type = "delete"
+	TypeTyping  Type = "typing"
+	TypeRead    Type = "read"
+	TypeUpload  Type = "upload"
+	TypeReact   Type = "react"
+	TypeEdit    Type = "edit"
+	TypeRecall  Type = "recall"
+	TypeGroup   Type = "group"
+	TypeAck     Type = "ack"
+	TypeMessage Type = "message"
+	TypeDeleted Type = "deleted"
+	TypeStatus  Type = "status"
+	TypeReacted Type = "reacted"
+	TypeUpdated Type = "updated"
+)
+
+// Envelope is the unit of exchange on a connection.
+type Envelope struct {
+	Type  Type            `json:"type"`
+	ID    string          `json:"id,omitempty"`
+	Error string          `json:"error,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+}
+
+// newEnvelope encodes body into an envelope. A nil body is omitted.
+func newEnvelope(t Type, id string, body interface{}) (Envelope, error) {
+	env := Envelope{Type: t, ID: id}
+	if body == nil {
+		return env, nil
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return env, fmt.Errorf("encoding %s body: %w", t, err)
+	}
+	env.Body = raw
+	return env, nil
+}
+
+// decode the envelope body into v.
+func (env Envelope) decode(v interface{}) error {
+	if len(env.Body) == 0 {
+		return fmt.Errorf("decoding %s body: empty", env.Type)
+	}
+	if err := json.Unmarshal(env.Body, v); err != nil {
+		return fmt.Errorf("decoding %s body: %w", env.Type, err)
+	}
+	return nil
+}
+
+// AuthRequest authenticates a connection.
+type AuthRequest struct {
+	User  string `json:"user"`
+	Token string `json:"token,omitempty"`
+}
+
+// AuthReply acknowledges a successful AuthRequest.
+type AuthReply struct {
+	User  model.User   `json:"user"`
+	Users []model.User `json:"users"`
+}
+
+// RoomInfo describes a room visible to the authenticated user.
+type RoomInfo struct {
+	Name   string         `json:"name"`
+	Avatar string         `json:"avatar,omitempty"`
+	Latest *model.Message `json:"latest,omitempty"`
+	// Unread is the number of messages of other users the authenticated
+	// user has not read.
+	Unread int `json:"unread,omitempty"`
+	// Mentions are the serials of the unread messages that mention the
+	// authenticated user, latest first.
+	Mentions []list.Serial `json:"mentions,omitempty"`
+	// Group holds the members and settings of the room.
+	Group model.Group `json:"group"`
+}
+
+// RoomsReply acknowledges a rooms request.
+type RoomsReply struct {
+	Rooms []RoomInfo `json:"rooms"`
+}
+
+// CreateRequest asks for a room to be created. Its ack carries the
+// RoomInfo of the room, which may already have existed.
+type CreateRequest struct {
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// UploadRequest stores the contents of a file to be attached to messages
+// of a room.
+type UploadRequest struct {
+	Room string `json:"room"`
+	Name string `json:"name"`
+	MIME string `json:"mime,omitempty"`
+	Data []byte `json:"data"`
+}
+
+// UploadReply acknowledges an UploadRequest. The file is served over
+// HTTP by the server, at its URL with the query parameter file=ID.
+type UploadReply struct {
+	ID string `json:"id"`
+}
+
+// Direction values of a HistoryRequest. DirectionAround asks for the
+// messages surrounding RelativeTo, including it.
+const (
+	DirectionBefore = "before"
+	DirectionAfter  = "after"
+	DirectionAround = "around"
+)
+
+// HistoryRequest asks for a page of a room's messages.
+type HistoryRequest struct {
+	Room       string      `json:"room"`
+	Direction  string      `json:"direction"`
+	RelativeTo list.Serial `json:"relativeTo,omitempty"`
+	Limit      int         `json:"limit,omitempty"`
+}
+
+// HistoryReply acknowledges a HistoryRequest. For requests in
+// DirectionAround, More reports whether there are messages before the
+// page and MoreAfter whether there are messages after it.
+type HistoryReply struct {
+	Messages  []model.Message `json:"messages"`
+	More      bool            `json:"more"`
+	MoreAfter bool            `json:"moreAfter,omitempty"`
+}
+
+// MessageBody carries a message sent to a room. It is the body of send
+// requests, their acks and message pushes.
+type MessageBody struct {
+	Room    string        `json:"room"`
+	Message model.Message `json:"message"`
+}
+
+// DeleteBody identifies a message removed from a room. It is the body of
+// delete requests and deleted pushes.
+type DeleteBody struct {
+	Room   string      `json:"room"`
+	Serial list.Serial `json:"serial"`
+}
+
+// EditBody replaces the content of a message. Its ack and updated pushes
+// carry a MessageBody holding the updated message.
+type EditBody struct {
+	Room    string      `json:"room"`
+	Serial  list.Serial `json:"serial"`
+	Content string      `json:"content"`
+}
+
+// RecallBody identifies a message recalled by its sender. Its ack and
+// updated pushes carry a MessageBody holding the recalled message.
+type RecallBody struct {
+	Room   string      `json:"room"`
+	Serial list.Serial `json:"serial"`
+}
+
+// ReactBody adds the reaction of a user with an emoji to a message, or
+// removes it if Add is false. The user is filled in by the server. Its
+// ack and reacted pushes carry a MessageBody holding the updated message.
+type ReactBody struct {
+	Room   string      `json:"room"`
+	Serial list.Serial `json:"serial"`
+	Emoji  string      `json:"emoji"`
+	Add    bool        `json:"add"`
+	User   string      `json:"user,omitempty"`
+}
+
+// GroupBody changes the members or settings of a room. Its ack carries a
+// GroupReply.
+type GroupBody struct {
+	Room   string            `json:"room"`
+	Change model.GroupChange `json:"change"`
+}
+
+// GroupReply acknowledges a GroupBody with the updated group, and the
+// notice recording the change in the room. The group is also the body of
+// group pushes, and the notice is pushed as a message.
+type GroupReply struct {
+	Room   string         `json:"room"`
+	Group  model.Group    `json:"group"`
+	Notice *model.Message `json:"notice,omitempty"`
+}
+
+// TypingBody reports the composing state of a user. The user is filled in
+// by the server.
+type TypingBody struct {
+	Room      string `json:"room"`
+	User      string `json:"user,omitempty"`
+	Composing bool   `json:"composing"`
+}
+
+// ReadBody reports how far a user has read a room. The user is filled in
+// by the server.
+type ReadBody struct {
+	Room   string      `json:"room"`
+	User   string      `json:"user,omitempty"`
+	Serial list.Serial `json:"serial"`
+}
+
+// ReadReply acknowledges a read request with the number of messages of
+// the room that remain unread.
+type ReadReply struct {
+	Unread int `json:"unread"`
+}
+
+// StatusBody reports a change in the delivery status of a message. It is
+// pushed to the sender of the message.
+type StatusBody struct {
+	Room   string       `json:"room"`
+	Serial list.Serial  `json:"serial"`
+	Status model.Status `json:"status"`
+}
+
+// directionString encodes a list.Direction for the wire.
+func directionString(dir list.Direction) string {
+	if dir == list.After {
+		return DirectionAfter
+	}
+	return DirectionBefore
+}
+
+// parseDirection decodes a wire direction.
+func parseDirection(s string) (list.Direction, error) {
+	switch s {
+	case DirectionBefore, "":
+		return list.Before, nil
+	case DirectionAfter:
+		return list.After, nil
+	}
+	return list.NoDirection, fmt.Errorf("unknown direction %q", s)
+}
@@ -0,0 +1,594 @@
+package protocol
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/store"
+	"wechat_ui/ui/pkg/list"
+)
+
+// newTestServer starts a server with two users and a room holding
+// history messages with serials [1, history].
+func newTestServer(t *testing.T, history int) string {
+	t.Helper()
+	messages, err := store.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= history; i++ {
+		msg := model.Message{SerialID: strconv.Itoa(i), Sender: "alice", Content: "old"}
+		if err := messages.Put("general", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := NewServer(messages)
+	srv.Logf = t.Logf
+	srv.AddUser(model.User{Name: "alice"})
+	srv.AddUser(model.User{Name: "bob"})
+	srv.AddRoom("general", "")
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+	return "ws" + strings.TrimPrefix(hs.URL, "http")
+}
+
+func dial(t *testing.T, url, user string) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Config{URL: url, User: user, LoadSize: 20, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("dialing as %s: %v", user, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// send composes and sends a message.
+func send(c *Client, room, content string) (model.Message, error) {
+	return c.Send(room, c.Compose(room, content))
+}
+
+// next waits for the next event pushed to c.
+func next(t *testing.T, c *Client) backend.Event {
+	t.Helper()
+	select {
+	case e, ok := <-c.Subscribe():
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return nil
+}
+
+func TestEndToEnd(t *testing.T) {
+	url := newTestServer(t, 50)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	if alice.Local().Name != "alice" {
+		t.Errorf("expected local user alice, got %q", alice.Local().Name)
+	}
+	if len(alice.Users().List()) != 2 {
+		t.Errorf("expected 2 users, got %d", len(alice.Users().List()))
+	}
+	rooms := alice.Rooms()
+	if len(rooms) != 1 || rooms[0].Name != "general" {
+		t.Fatalf("unexpected rooms %v", rooms)
+	}
+	if rooms[0].Latest == nil || rooms[0].Latest.SerialID != "50" {
+		t.Errorf("expected latest message 50, got %v", rooms[0].Latest)
+	}
+
+	// Page backwards through history.
+	elems, more := alice.Load("general", list.Before, list.NoSerial)
+	if len(elems) != 20 || !more || elems[0].Serial() != "31" {
+		t.Fatalf("unexpected latest page: %d elements starting at %v, more=%v", len(elems), elems[0].Serial(), more)
+	}
+	elems, more = alice.Load("general", list.Before, "11")
+	if len(elems) != 10 || more {
+		t.Fatalf("unexpected first page: %d elements, more=%v", len(elems), more)
+	}
+	elems, more = alice.Load("general", list.After, "40")
+	if len(elems) != 10 || more || elems[9].Serial() != "50" {
+		t.Fatalf("unexpected after page: %d elements, more=%v", len(elems), more)
+	}
+
+	// Sends are acked to the sender and pushed to everyone else.
+	sent, err := send(alice, "general", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Sender != "alice" || !model.SerialLessThan("50", sent.Serial()) {
+		t.Errorf("unexpected sent message %+v", sent)
+	}
+	if sent.Status != model.StatusSent && sent.Status != model.StatusDelivered {
+		t.Errorf("expected sent message, got status %q", sent.Status)
+	}
+	switch e := next(t, bob).(type) {
+	case backend.MessageEvent:
+		if e.Room != "general" || e.Message.Serial() != sent.Serial() || e.Message.Content != "hello" {
+			t.Errorf("unexpected message push %+v", e)
+		}
+	default:
+		t.Fatalf("expected message push, got %T", e)
+	}
+	expectStatus(t, next(t, alice), sent.Serial(), model.StatusDelivered)
+
+	if err := bob.Typing("general", true); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, alice).(backend.ComposingEvent); !ok || e.User != "bob" || !e.Composing {
+		t.Errorf("unexpected typing push %+v", e)
+	}
+	if _, err := bob.MarkRead("general", sent.Serial()); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, alice).(backend.ReadEvent); !ok || e.User != "bob" || e.Serial != sent.Serial() {
+		t.Errorf("unexpected read push %+v", e)
+	}
+	expectStatus(t, next(t, alice), sent.Serial(), model.StatusRead)
+	if err := alice.Delete("general", "50"); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, bob).(backend.DeleteEvent); !ok || e.Serial != "50" {
+		t.Errorf("unexpected delete push %+v", e)
+	}
+
+	// The server persisted all changes.
+	elems, _ = bob.Load("general", list.Before, list.NoSerial)
+	last := elems[len(elems)-1].(model.Message)
+	if last.Serial() != sent.Serial() || elems[len(elems)-2].Serial() != "49" {
+		t.Errorf("unexpected history tail %v, %v", elems[len(elems)-2].Serial(), last.Serial())
+	}
+	if last.Status != model.StatusRead {
+		t.Errorf("expected stored message to be read, got status %q", last.Status)
+	}
+
+	// Nothing is echoed back to the sender.
+	select {
+	case e := <-alice.Subscribe():
+		if _, ok := e.(backend.MessageEvent); ok {
+			t.Errorf("unexpected echo %+v", e)
+		}
+	default:
+	}
+}
+
+func TestLoadAround(t *testing.T) {
+	url := newTestServer(t, 100)
+	alice := dial(t, url, "alice")
+
+	elems, moreBefore, moreAfter := alice.LoadAround("general", "50")
+	if len(elems) != 20 || elems[0].Serial() != "40" || !moreBefore || !moreAfter {
+		t.Fatalf("unexpected middle page: %d elements starting at %v, more=(%v, %v)",
+			len(elems), elems[0].Serial(), moreBefore, moreAfter)
+	}
+	elems, moreBefore, moreAfter = alice.LoadAround("general", "95")
+	if len(elems) != 20 || elems[19].Serial() != "100" || !moreBefore || moreAfter {
+		t.Fatalf("unexpected latest page: %d elements ending at %v, more=(%v, %v)",
+			len(elems), elems[len(elems)-1].Serial(), moreBefore, moreAfter)
+	}
+	if elems, _, _ := alice.LoadAround("nowhere", "1"); len(elems) != 0 {
+		t.Errorf("expected no elements for unknown room, got %d", len(elems))
+	}
+}
+
+func TestReply(t *testing.T) {
+	url := newTestServer(t, 10)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	elems, _ := bob.Load("general", list.Before, list.NoSerial)
+	original := elems[len(elems)-1].(model.Message)
+	reply := bob.Compose("general", "indeed")
+	reply.ReplyTo = original.Quote()
+	if _, err := bob.Send("general", reply); err != nil {
+		t.Fatal(err)
+	}
+	ev := next(t, alice)
+	e, ok := ev.(backend.MessageEvent)
+	if !ok {
+		t.Fatalf("expected message push, got %T", ev)
+	}
+	if q := e.Message.ReplyTo; q == nil || q.Serial() != original.Serial() || q.Content != original.Content {
+		t.Errorf("expected reply to %v, got %+v", original.Serial(), q)
+	}
+}
+
+func TestReact(t *testing.T) {
+	url := newTestServer(t, 10)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	msg, err := bob.React("general", "10", "👍", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Reactions.Has("👍", "bob") || msg.Content != "old" {
+		t.Errorf("unexpected reacted message %+v", msg)
+	}
+	ev := next(t, alice)
+	e, ok := ev.(backend.ReactionEvent)
+	if !ok {
+		t.Fatalf("expected reaction push, got %T", ev)
+	}
+	if e.Message.Serial() != "10" || !e.Message.Reactions.Has("👍", "bob") {
+		t.Errorf("unexpected reaction push %+v", e)
+	}
+	if _, err := alice.React("general", "10", "👍", true); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, bob).(backend.ReactionEvent); !ok || len(e.Message.Reactions) != 1 || len(e.Message.Reactions[0].Users) != 2 {
+		t.Errorf("unexpected reaction push %+v", e)
+	}
+	msg, err = bob.React("general", "10", "👍", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Reactions.Has("👍", "bob") || !msg.Reactions.Has("👍", "alice") {
+		t.Errorf("expected only alice's reaction to remain, got %+v", msg.Reactions)
+	}
+	if _, err := bob.React("general", "404", "👍", true); err == nil {
+		t.Errorf("expected error reacting to unknown message")
+	}
+}
+
+func TestEditRecall(t *testing.T) {
+	url := newTestServer(t, 1)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	sent, err := send(alice, "general", "helo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := next(t, bob).(backend.MessageEvent); !ok {
+		t.Fatal("expected message push")
+	}
+	edited, err := alice.Edit("general", sent.Serial(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Content != "hello" || !edited.Edited() {
+		t.Errorf("unexpected edited message %+v", edited)
+	}
+	ev := next(t, bob)
+	e, ok := ev.(backend.UpdateEvent)
+	if !ok {
+		t.Fatalf("expected update push, got %T", ev)
+	}
+	if e.Message.Serial() != sent.Serial() || e.Message.Content != "hello" || !e.Message.Edited() {
+		t.Errorf("unexpected update push %+v", e)
+	}
+	if _, err := bob.Edit("general", sent.Serial(), "hijacked"); err == nil {
+		t.Errorf("expected error editing the message of another user")
+	}
+	if _, err := alice.Edit("general", "1", "too late"); err == nil {
+		t.Errorf("expected error editing a message sent long ago")
+	}
+
+	recalled, err := alice.Recall("general", sent.Serial())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recalled.Recalled || recalled.Content != "" {
+		t.Errorf("unexpected recalled message %+v", recalled)
+	}
+	if e, ok := next(t, bob).(backend.UpdateEvent); !ok || !e.Message.Recalled {
+		t.Errorf("unexpected recall push %+v", e)
+	}
+	if _, err := alice.Edit("general", sent.Serial(), "again"); err == nil {
+		t.Errorf("expected error editing a recalled message")
+	}
+	elems, _ := bob.Load("general", list.Before, list.NoSerial)
+	if last := elems[len(elems)-1].(model.Message); !last.Recalled || last.Content != "" {
+		t.Errorf("expected recall to be stored, got %+v", last)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	url := newTestServer(t, 0)
+	alice := dial(t, url, "alice")
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	local, err := backend.DescribeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := alice.Attach("general", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a.URL, "http://") || a.Name != "notes.txt" || a.Size != 5 {
+		t.Fatalf("unexpected attachment %+v", a)
+	}
+	resp, err := http.Get(a.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "hello" {
+		t.Errorf("unexpected download: %s %q", resp.Status, data)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != local.MIME {
+		t.Errorf("expected content type %q, got %q", local.MIME, ct)
+	}
+	if _, err := alice.Attach("nowhere", local); err == nil {
+		t.Errorf("expected error attaching to unknown room")
+	}
+}
+
+func TestRejections(t *testing.T) {
+	url := newTestServer(t, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Dial(ctx, Config{URL: url, User: "mallory"}); err == nil {
+		t.Error("expected unknown user to be rejected")
+	}
+
+	alice := dial(t, url, "alice")
+	if _, err := send(alice, "nowhere", "hello"); err == nil {
+		t.Error("expected send to unknown room to fail")
+	}
+	err := alice.request(ctx, TypeSend, MessageBody{
+		Room:    "general",
+		Message: model.Message{SerialID: "1"},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "already taken") {
+		t.Errorf("expected duplicate serial to be rejected, got %v", err)
+	}
+	err = alice.request(ctx, TypeSend, MessageBody{
+		Room:    "general",
+		Message: model.Message{SerialID: "x"},
+	}, nil)
+	if err == nil {
+		t.Error("expected malformed serial to be rejected")
+	}
+}
+
+func TestClose(t *testing.T) {
+	url := newTestServer(t, 0)
+	c := dial(t, url, "alice")
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c.Subscribe(); ok {
+		t.Error("expected event channel to be closed")
+	}
+	if _, err := send(c, "general", "hello"); err == nil {
+		t.Error("expected send on closed client to fail")
+	}
+}
+
+// expectStatus checks that e reports the message with serial advancing
+// to status.
+func expectStatus(t *testing.T, e backend.Event, serial list.Serial, status model.Status) {
+	t.Helper()
+	se, ok := e.(backend.StatusEvent)
+	if !ok {
+		t.Fatalf("expected status push, got %T", e)
+	}
+	if se.Message.Serial() != serial || se.Message.Status != status {
+		t.Errorf("expected %s to be %q, got %s %q", serial, status, se.Message.Serial(), se.Message.Status)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	url := newTestServer(t, 0)
+	alice := dial(t, url, "alice")
+
+	// Failed messages are kept until deleted.
+	failed, err := send(alice, "nowhere", "hello")
+	if err == nil {
+		t.Fatal("expected send to unknown room to fail")
+	}
+	if failed.Status != model.StatusFailed {
+		t.Errorf("expected failed status, got %q", failed.Status)
+	}
+	elems, _ := alice.Load("nowhere", list.Before, list.NoSerial)
+	if len(elems) != 1 || elems[0].(model.Message).Status != model.StatusFailed {
+		t.Fatalf("expected failed message in history, got %v", elems)
+	}
+	if err := alice.Delete("nowhere", failed.Serial()); err != nil {
+		t.Fatal(err)
+	}
+	if elems, _ := alice.Load("nowhere", list.Before, list.NoSerial); len(elems) != 0 {
+		t.Errorf("expected deleted message to be gone, got %v", elems)
+	}
+
+	// Resending a message whose ack was lost is harmless.
+	msg := alice.Compose("general", "hello")
+	first, err := alice.Send("general", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := alice.Send("general", msg)
+	if err != nil {
+		t.Fatalf("expected resend to succeed, got %v", err)
+	}
+	if first.Serial() != second.Serial() {
+		t.Errorf("expected resend to keep serial %s, got %s", first.Serial(), second.Serial())
+	}
+	if elems, _ := alice.Load("general", list.Before, list.NoSerial); len(elems) != 1 {
+		t.Errorf("expected a single stored message, got %d", len(elems))
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	url := newTestServer(t, 3)
+	alice := dial(t, url, "alice")
+
+	room, err := alice.CreateRoom("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Name != "bob" || room.Latest != nil {
+		t.Errorf("unexpected created room %+v", room)
+	}
+	if _, err := alice.CreateRoom(""); err == nil {
+		t.Error("expected room without name to be rejected")
+	}
+	if _, err := send(alice, "bob", "hi"); err != nil {
+		t.Fatalf("sending to created room: %v", err)
+	}
+
+	// Creating an existing room returns it.
+	bob := dial(t, url, "bob")
+	if len(bob.Rooms()) != 2 {
+		t.Fatalf("expected created room to be listed, got %d rooms", len(bob.Rooms()))
+	}
+	room, err = bob.CreateRoom("general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Latest == nil || room.Latest.SerialID != "3" {
+		t.Errorf("expected existing room with latest message 3, got %+v", room.Latest)
+	}
+}
+
+func TestUnread(t *testing.T) {
+	url := newTestServer(t, 5)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	if n := alice.Rooms()[0].Unread; n != 0 {
+		t.Errorf("expected no unread messages of alice's own, got %d", n)
+	}
+	if n := bob.Rooms()[0].Unread; n != 5 {
+		t.Errorf("expected 5 unread messages, got %d", n)
+	}
+	unread, err := bob.MarkRead("general", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread != 2 {
+		t.Errorf("expected 2 unread messages after reading 3, got %d", unread)
+	}
+	elems, _ := bob.Load("general", list.Before, list.NoSerial)
+	for _, e := range elems {
+		msg := e.(model.Message)
+		if want := !model.SerialLessThan("3", msg.Serial()); msg.Read != want {
+			t.Errorf("expected message %v read=%v", msg.Serial(), want)
+		}
+	}
+	// Marks never move backwards.
+	if unread, err := bob.MarkRead("general", "1"); err != nil || unread != 2 {
+		t.Errorf("expected 2 unread messages after reading 1 again, got %d, %v", unread, err)
+	}
+
+	msg := alice.Compose("general", "hello @bob")
+	msg.Mentions = []string{"bob"}
+	sent, err := alice.Send("general", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, bob).(backend.MessageEvent); !ok || e.Message.Read || !e.Message.Mentioned("bob") {
+		t.Errorf("expected unread message push mentioning bob, got %+v", e)
+	}
+	room := dial(t, url, "bob").Rooms()[0]
+	if room.Unread != 3 {
+		t.Errorf("expected 3 unread messages on reconnecting, got %d", room.Unread)
+	}
+	if want := []list.Serial{sent.Serial()}; !reflect.DeepEqual(room.Mentions, want) {
+		t.Errorf("expected unread mentions %v, got %v", want, room.Mentions)
+	}
+	if unread, err := bob.MarkRead("general", sent.Serial()); err != nil || unread != 0 {
+		t.Errorf("expected no unread messages after reading all, got %d, %v", unread, err)
+	}
+	if room := dial(t, url, "bob").Rooms()[0]; len(room.Mentions) != 0 {
+		t.Errorf("expected no unread mentions after reading all, got %v", room.Mentions)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	url := newTestServer(t, 3)
+	alice := dial(t, url, "alice")
+	bob := dial(t, url, "bob")
+
+	// Adding members to the open room makes alice its owner.
+	g, notice, err := alice.ChangeGroup("general", model.GroupChange{Kind: model.ChangeAdd, Users: []string{"bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := g.Member("alice"); m.Role != model.RoleOwner || len(g.Members) != 2 {
+		t.Errorf("unexpected group %+v", g)
+	}
+	if !notice.System || notice.Content != "alice added bob" || !notice.Read {
+		t.Errorf("unexpected notice %+v", notice)
+	}
+	if e, ok := next(t, bob).(backend.GroupEvent); !ok || len(e.Group.Members) != 2 {
+		t.Errorf("unexpected group push %+v", e)
+	}
+	if e, ok := next(t, bob).(backend.MessageEvent); !ok || e.Message.Serial() != notice.Serial() {
+		t.Errorf("unexpected notice push %+v", e)
+	}
+	if _, _, err := alice.ChangeGroup("general", model.GroupChange{Kind: model.ChangeAdd, Users: []string{"carol"}}); err == nil {
+		t.Error("expected unknown user to be rejected")
+	}
+	if _, _, err := bob.ChangeGroup("general", model.GroupChange{Kind: model.ChangeRename, Text: "bob's"}); err == nil {
+		t.Error("expected member not to be able to rename the group")
+	}
+	g, _, err = alice.ChangeGroup("general", model.GroupChange{Kind: model.ChangeRename, Text: "friends"})
+	if err != nil || g.Title != "friends" {
+		t.Fatalf("renaming group: %+v, %v", g, err)
+	}
+	next(t, bob)
+	next(t, bob)
+	if title := dial(t, url, "bob").Rooms()[0].Title; title != "friends" {
+		t.Errorf("expected renamed room to be listed as friends, got %q", title)
+	}
+
+	// Only the owner can change roles.
+	g, notice, err = alice.ChangeGroup("general", model.GroupChange{Kind: model.ChangeRole, Users: []string{"bob"}, Role: model.RoleAdmin})
+	if err != nil || notice.Content != "alice made bob admin" {
+		t.Fatalf("promoting bob: %+v, %v", notice, err)
+	}
+	if m, _ := g.Member("bob"); m.Role != model.RoleAdmin {
+		t.Errorf("expected bob to be an admin, got %+v", g)
+	}
+	if e, ok := next(t, bob).(backend.GroupEvent); !ok {
+		t.Errorf("unexpected group push %+v", e)
+	} else if m, _ := e.Group.Member("bob"); m.Role != model.RoleAdmin {
+		t.Errorf("expected group push promoting bob, got %+v", e)
+	}
+	next(t, bob)
+	if _, _, err := bob.ChangeGroup("general", model.GroupChange{Kind: model.ChangeRole, Users: []string{"alice"}, Role: model.RoleMember}); err == nil {
+		t.Error("expected admin not to be able to change roles")
+	}
+
+	// Removed members are told, and can no longer see the room.
+	if _, _, err := alice.ChangeGroup("general", model.GroupChange{Kind: model.ChangeRemove, Users: []string{"bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := next(t, bob).(backend.GroupEvent); !ok || e.Group.Visible("bob") {
+		t.Errorf("expected group push removing bob, got %+v", e)
+	}
+	if _, err := send(bob, "general", "hi"); err == nil {
+		t.Error("expected removed member not to be able to send")
+	}
+	bob = dial(t, url, "bob")
+	if n := len(bob.Rooms()); n != 0 {
+		t.Errorf("expected removed member to see no rooms, got %d", n)
+	}
+	if _, err := bob.CreateRoom("general"); err == nil {
+		t.Error("expected the group not to be disclosed to non members")
+	}
+}
@@ -0,0 +1,753 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/store"
+	"wechat_ui/ui/pkg/list"
+)
+
+// writeTimeout bounds how long the server waits on a slow connection.
+const writeTimeout = 10 * time.Second
+
+// Server is a reference implementation of the protocol, suitable for
+// running in process. Every registered user can see the open rooms, and
+// the members of a group can see it.
+//
+// Server implements http.Handler; mount it on any path and point clients
+// at it with a ws:// URL.
+type Server struct {
+	// Authenticate validates the credentials of a user. Nil accepts any
+	// token for a registered user.
+	Authenticate func(user, token string) bool
+	// Logf logs connection errors. Defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+	// RecallWindow is how long after sending a message its sender can edit
+	// or recall it. NewServer sets it to model.DefaultRecallWindow.
+	RecallWindow time.Duration
+
+	messages *store.Store
+	mu       sync.Mutex
+	users    []model.User
+	rooms    []RoomInfo
+	sessions map[*session]struct{}
+	// files holds the uploaded files by ID. They are kept in memory only.
+	files  map[string]file
+	nextID int
+	// reads holds the serial each user has read each room up to. They are
+	// kept in memory only.
+	reads map[readKey]list.Serial
+}
+
+// readKey identifies how far a user has read a room.
+type readKey struct {
+	user, room string
+}
+
+// file is an uploaded file.
+type file struct {
+	name string
+	mime string
+	data []byte
+}
+
+// NewServer returns a server persisting messages to the provided store.
+func NewServer(messages *store.Store) *Server {
+	return &Server{
+		RecallWindow: model.DefaultRecallWindow,
+		messages:     messages,
+		sessions:     make(map[*session]struct{}),
+		files:        make(map[string]file),
+		reads:        make(map[readKey]list.Serial),
+	}
+}
+
+// AddUser registers a user that may authenticate.
+func (s *Server) AddUser(u model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, u)
+}
+
+// AddRoom registers a room. Without members the room is open to every
+// user.
+func (s *Server) AddRoom(name, avatar string, members ...model.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms = append(s.rooms, RoomInfo{Name: name, Avatar: avatar, Group: model.Group{Members: members}})
+}
+
+// createRoom registers an open room on behalf of user unless it exists,
+// and returns its info. Existing groups the user is not a member of are
+// not disclosed.
+func (s *Server) createRoom(user string, req CreateRequest) (RoomInfo, error) {
+	if req.Name == "" {
+		return RoomInfo{}, errors.New("empty room name")
+	}
+	s.mu.Lock()
+	info := RoomInfo{Name: req.Name, Avatar: req.Avatar}
+	found := false
+	for _, r := range s.rooms {
+		if r.Name == req.Name {
+			info, found = r, true
+			break
+		}
+	}
+	if !found {
+		s.rooms = append(s.rooms, info)
+	}
+	s.mu.Unlock()
+	if !info.Group.Visible(user) {
+		return RoomInfo{}, fmt.Errorf("room %q is taken", req.Name)
+	}
+	if latest, ok := s.messages.Latest(info.Name); ok {
+		info.Latest = &latest
+	}
+	return info, nil
+}
+
+// user looks up a registered user by name.
+func (s *Server) user(name string) (model.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
+
+// hasRoom reports whether the named room is registered and visible to
+// user.
+func (s *Server) hasRoom(user, name string) bool {
+	g, ok := s.group(name)
+	return ok && g.Visible(user)
+}
+
+// group returns the members and settings of the named room, and whether
+// it is registered.
+func (s *Server) group(name string) (model.Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ii := s.roomIndex(name); ii >= 0 {
+		return s.rooms[ii].Group, true
+	}
+	return model.Group{}, false
+}
+
+// roomIndex returns the index of the named room, or -1 if it is not
+// registered. It must be called with mu held.
+func (s *Server) roomIndex(name string) int {
+	for ii, r := range s.rooms {
+		if r.Name == name {
+			return ii
+		}
+	}
+	return -1
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// upload stores the file sent by user, returning its ID.
+func (s *Server) upload(user string, req UploadRequest) (UploadReply, error) {
+	if !s.hasRoom(user, req.Room) {
+		return UploadReply{}, fmt.Errorf("unknown room %q", req.Room)
+	}
+	if req.Name == "" {
+		return UploadReply{}, errors.New("empty file name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.files[id] = file{name: req.Name, mime: req.MIME, data: req.Data}
+	return UploadReply{ID: id}, nil
+}
+
+// serveFile writes the uploaded file with the given ID.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	f, ok := s.files[id]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if f.mime != "" {
+		w.Header().Set("Content-Type", f.mime)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.name}))
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(f.data))
+}
+
+// ServeHTTP upgrades the request to a websocket and serves the protocol
+// on it until the connection closes. Requests with a file query parameter
+// download an uploaded file instead.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("file"); id != "" {
+		s.serveFile(w, r, id)
+		return
+	}
+	conn, err := upgradeWS(w, r)
+	if err != nil {
+		s.logf("protocol server: %v", err)
+		return
+	}
+	sess := &session{server: s, conn: conn}
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, ErrClosed) {
+				s.logf("protocol server: reading: %v", err)
+			}
+			return
+		}
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			s.logf("protocol server: decoding envelope: %v", err)
+			return
+		}
+		body, err := sess.handle(env)
+		if err := sess.ack(env.ID, body, err); err != nil {
+			s.logf("protocol server: writing ack: %v", err)
+			return
+		}
+		if sess.after != nil {
+			sess.after()
+			sess.after = nil
+		}
+		if env.Type == TypeAuth && sess.user == "" {
+			// Failed authentication ends the connection.
+			return
+		}
+	}
+}
+
+// broadcast pushes an envelope to every authenticated session that can see
+// the room but the origin, returning how many sessions received it.
+func (s *Server) broadcast(origin *session, room string, t Type, body interface{}) int {
+	g, _ := s.group(room)
+	return s.push(func(sess *session) bool { return sess != origin && g.Visible(sess.user) }, t, body)
+}
+
+// push sends an envelope to every authenticated session matching the
+// filter, returning how many sessions received it.
+func (s *Server) push(filter func(*session) bool, t Type, body interface{}) int {
+	env, err := newEnvelope(t, "", body)
+	if err != nil {
+		s.logf("protocol server: %v", err)
+		return 0
+	}
+	s.mu.Lock()
+	targets := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		if filter(sess) {
+			targets = append(targets, sess)
+		}
+	}
+	s.mu.Unlock()
+	var n int
+	for _, sess := range targets {
+		if err := sess.write(env); err != nil {
+			s.logf("protocol server: pushing %s to %s: %v", t, sess.user, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// setStatus advances the status of a stored message and notifies its
+// sender.
+func (s *Server) setStatus(room string, msg model.Message, status model.Status) {
+	updated, ok, err := s.messages.Modify(room, msg.Serial(), func(msg *model.Message) bool {
+		if !msg.Status.CanAdvance(status) {
+			return false
+		}
+		msg.Status = status
+		return true
+	})
+	if err != nil || !ok {
+		return
+	}
+	s.push(func(sess *session) bool { return sess.user == updated.Sender }, TypeStatus, StatusBody{
+		Room:   room,
+		Serial: updated.Serial(),
+		Status: status,
+	})
+}
+
+// session is the server side of a connection.
+type session struct {
+	server *Server
+	conn   *wsConn
+	// user is the authenticated user, empty until auth succeeds.
+	user string
+	// after runs once the current request has been acknowledged.
+	after func()
+}
+
+// write sends an envelope to the client.
+func (sess *session) write(env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("encoding envelope: %w", err)
+	}
+	sess.conn.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return sess.conn.WriteMessage(data)
+}
+
+// ack acknowledges the request with the provided id.
+func (sess *session) ack(id string, body interface{}, reqErr error) error {
+	env, err := newEnvelope(TypeAck, id, body)
+	if err != nil {
+		reqErr = err
+	}
+	if reqErr != nil {
+		env = Envelope{Type: TypeAck, ID: id, Error: reqErr.Error()}
+	}
+	return sess.write(env)
+}
+
+// handle serves a single request, returning the ack body.
+func (sess *session) handle(env Envelope) (interface{}, error) {
+	s := sess.server
+	if sess.user == "" && env.Type != TypeAuth {
+		return nil, errors.New("not authenticated")
+	}
+	switch env.Type {
+	case TypeAuth:
+		var req AuthRequest
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.auth(req)
+	case TypeRooms:
+		s.mu.Lock()
+		rooms := make([]RoomInfo, 0, len(s.rooms))
+		for _, r := range s.rooms {
+			if r.Group.Visible(sess.user) {
+				rooms = append(rooms, r)
+			}
+		}
+		s.mu.Unlock()
+		for ii := range rooms {
+			if latest, ok := s.messages.Latest(rooms[ii].Name); ok {
+				latest.Read = s.read(sess.user, rooms[ii].Name, latest)
+				rooms[ii].Latest = &latest
+			}
+			rooms[ii].Unread, rooms[ii].Mentions = s.unread(sess.user, rooms[ii].Name)
+		}
+		return RoomsReply{Rooms: rooms}, nil
+	case TypeCreate:
+		var req CreateRequest
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return s.createRoom(sess.user, req)
+	case TypeHistory:
+		var req HistoryRequest
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.history(req)
+	case TypeSend:
+		var req MessageBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.send(req)
+	case TypeDelete:
+		var req DeleteBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return nil, sess.delete(req)
+	case TypeEdit:
+		var req EditBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.change(req.Room, req.Serial, func(msg *model.Message, now time.Time) error {
+			if err := msg.CheckEdit(sess.user, req.Content, now, s.RecallWindow); err != nil {
+				return err
+			}
+			msg.Content = req.Content
+			msg.EditedAt = now
+			return nil
+		})
+	case TypeRecall:
+		var req RecallBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.change(req.Room, req.Serial, func(msg *model.Message, now time.Time) error {
+			if err := msg.CheckChange(sess.user, now, s.RecallWindow); err != nil {
+				return err
+			}
+			*msg = msg.Recall()
+			return nil
+		})
+	case TypeReact:
+		var req ReactBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.react(req)
+	case TypeGroup:
+		var req GroupBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return sess.changeGroup(req)
+	case TypeTyping:
+		var req TypingBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		if !s.hasRoom(sess.user, req.Room) {
+			return nil, fmt.Errorf("unknown room %q", req.Room)
+		}
+		req.User = sess.user
+		s.broadcast(sess, req.Room, TypeTyping, req)
+		return nil, nil
+	case TypeRead:
+		var req ReadBody
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		if !s.hasRoom(sess.user, req.Room) {
+			return nil, fmt.Errorf("unknown room %q", req.Room)
+		}
+		req.User = sess.user
+		s.setRead(req)
+		s.broadcast(sess, req.Room, TypeRead, req)
+		sess.after = func() { s.markRead(req) }
+		unread, _ := s.unread(sess.user, req.Room)
+		return ReadReply{Unread: unread}, nil
+	case TypeUpload:
+		var req UploadRequest
+		if err := env.decode(&req); err != nil {
+			return nil, err
+		}
+		return s.upload(sess.user, req)
+	}
+	return nil, fmt.Errorf("unknown request type %q", env.Type)
+}
+
+func (sess *session) auth(req AuthRequest) (interface{}, error) {
+	s := sess.server
+	if sess.user != "" {
+		return nil, errors.New("already authenticated")
+	}
+	u, ok := s.user(req.User)
+	if !ok || (s.Authenticate != nil && !s.Authenticate(req.User, req.Token)) {
+		return nil, errors.New("invalid credentials")
+	}
+	s.mu.Lock()
+	sess.user = u.Name
+	s.sessions[sess] = struct{}{}
+	users := make([]model.User, len(s.users))
+	copy(users, s.users)
+	s.mu.Unlock()
+	return AuthReply{User: u, Users: users}, nil
+}
+
+func (sess *session) history(req HistoryRequest) (interface{}, error) {
+	if !sess.server.hasRoom(sess.user, req.Room) {
+		return nil, fmt.Errorf("unknown room %q", req.Room)
+	}
+	var (
+		elems           []list.Element
+		more, moreAfter bool
+	)
+	if req.Direction == DirectionAround {
+		elems, more, moreAfter = sess.server.messages.LoadAroundLimit(req.Room, req.RelativeTo, req.Limit)
+	} else {
+		dir, err := parseDirection(req.Direction)
+		if err != nil {
+			return nil, err
+		}
+		elems, more = sess.server.messages.LoadLimit(req.Room, dir, req.RelativeTo, req.Limit)
+	}
+	reply := HistoryReply{
+		Messages:  make([]model.Message, 0, len(elems)),
+		More:      more,
+		MoreAfter: moreAfter,
+	}
+	for _, e := range elems {
+		msg := e.(model.Message)
+		msg.Read = sess.server.read(sess.user, req.Room, msg)
+		reply.Messages = append(reply.Messages, msg)
+	}
+	return reply, nil
+}
+
+func (sess *session) send(req MessageBody) (interface{}, error) {
+	s := sess.server
+	if !s.hasRoom(sess.user, req.Room) {
+		return nil, fmt.Errorf("unknown room %q", req.Room)
+	}
+	if _, err := parseSerial(req.Message.Serial()); err != nil {
+		return nil, err
+	}
+	u, _ := s.user(sess.user)
+	req.Message.Sender = u.Name
+	req.Message.Avatar = u.Avatar
+	if req.Message.SentAt.IsZero() {
+		req.Message.SentAt = time.Now()
+	}
+	req.Message.Status = model.StatusSent
+	// Whether a message was read depends on the user it is sent to, the
+	// other users are yet to read it.
+	req.Message.Read = false
+	// Hold the server lock so that concurrent sends cannot claim the same
+	// serial.
+	s.mu.Lock()
+	if existing, ok := s.messages.Get(req.Room, req.Message.Serial()); ok {
+		s.mu.Unlock()
+		if existing.Sender == req.Message.Sender && existing.Content == req.Message.Content {
+			// A retry of a send whose ack was lost.
+			return MessageBody{Room: req.Room, Message: existing}, nil
+		}
+		return nil, fmt.Errorf("serial %q already taken", req.Message.SerialID)
+	}
+	err := s.messages.Put(req.Room, req.Message)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sess.after = func() {
+		if s.broadcast(sess, req.Room, TypeMessage, req) > 0 {
+			s.setStatus(req.Room, req.Message, model.StatusDelivered)
+		}
+	}
+	return req, nil
+}
+
+// setRead records how far the user has read the room. Marks never move
+// backwards.
+func (s *Server) setRead(req ReadBody) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := readKey{user: req.User, room: req.Room}
+	if mark, ok := s.reads[key]; !ok || model.SerialLessThan(mark, req.Serial) {
+		s.reads[key] = req.Serial
+	}
+}
+
+// read reports whether the user has read msg, sent to the room.
+func (s *Server) read(user, room string, msg model.Message) bool {
+	if msg.Sender == user {
+		return true
+	}
+	s.mu.Lock()
+	mark, ok := s.reads[readKey{user: user, room: room}]
+	s.mu.Unlock()
+	return ok && !model.SerialLessThan(mark, msg.Serial())
+}
+
+// unread counts the messages of other users in the room that the user has
+// not read, and returns the serials of those mentioning the user, latest
+// first. Pages are walked backwards from the latest message until one that
+// was read is found.
+func (s *Server) unread(user, room string) (count int, mentions []list.Serial) {
+	relativeTo := list.NoSerial
+	for {
+		elems, more := s.messages.LoadLimit(room, list.Before, relativeTo, 0)
+		for ii := len(elems) - 1; ii >= 0; ii-- {
+			msg := elems[ii].(model.Message)
+			if msg.Sender == user {
+				continue
+			}
+			if s.read(user, room, msg) {
+				return count, mentions
+			}
+			count++
+			if msg.Mentioned(user) {
+				mentions = append(mentions, msg.Serial())
+			}
+		}
+		if !more || len(elems) == 0 {
+			return count, mentions
+		}
+		relativeTo = elems[0].Serial()
+	}
+}
+
+// markRead advances the messages of other users up to the serial read by
+// the user to the read status. Pages are walked backwards from the latest
+// message until one that was already read is found.
+func (s *Server) markRead(req ReadBody) {
+	relativeTo := list.NoSerial
+	for {
+		elems, more := s.messages.LoadLimit(req.Room, list.Before, relativeTo, 0)
+		for ii := len(elems) - 1; ii >= 0; ii-- {
+			msg := elems[ii].(model.Message)
+			if msg.Sender == req.User || model.SerialLessThan(req.Serial, msg.Serial()) {
+				continue
+			}
+			if msg.Status == model.StatusRead {
+				return
+			}
+			s.setStatus(req.Room, msg, model.StatusRead)
+		}
+		if !more || len(elems) == 0 {
+			return
+		}
+		relativeTo = elems[0].Serial()
+	}
+}
+
+func (sess *session) delete(req DeleteBody) error {
+	s := sess.server
+	if !s.hasRoom(sess.user, req.Room) {
+		return fmt.Errorf("unknown room %q", req.Room)
+	}
+	if err := s.messages.Delete(req.Room, req.Serial); err != nil {
+		return err
+	}
+	s.broadcast(sess, req.Room, TypeDeleted, req)
+	return nil
+}
+
+// change applies fn to a stored message on behalf of its sender, failing
+// with the error returned by fn, and pushes the updated message to the
+// other connections.
+func (sess *session) change(room string, serial list.Serial, fn func(msg *model.Message, now time.Time) error) (interface{}, error) {
+	s := sess.server
+	if !s.hasRoom(sess.user, room) {
+		return nil, fmt.Errorf("unknown room %q", room)
+	}
+	var err error
+	msg, ok, storeErr := s.messages.Modify(room, serial, func(msg *model.Message) bool {
+		err = fn(msg, time.Now())
+		return err == nil
+	})
+	switch {
+	case storeErr != nil:
+		return nil, storeErr
+	case err != nil:
+		return nil, err
+	case !ok:
+		return nil, fmt.Errorf("unknown message %q", serial)
+	}
+	reply := MessageBody{Room: room, Message: msg}
+	sess.after = func() { s.broadcast(sess, room, TypeUpdated, reply) }
+	return reply, nil
+}
+
+func (sess *session) react(req ReactBody) (interface{}, error) {
+	s := sess.server
+	if !s.hasRoom(sess.user, req.Room) {
+		return nil, fmt.Errorf("unknown room %q", req.Room)
+	}
+	if req.Emoji == "" {
+		return nil, errors.New("missing emoji")
+	}
+	req.User = sess.user
+	msg, changed, err := s.messages.Modify(req.Room, req.Serial, func(msg *model.Message) bool {
+		if msg.Reactions.Has(req.Emoji, req.User) == req.Add {
+			return false
+		}
+		msg.Reactions = msg.Reactions.With(req.Emoji, req.User, req.Add)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg.SerialID == "" {
+		return nil, fmt.Errorf("unknown message %q", req.Serial)
+	}
+	reply := MessageBody{Room: req.Room, Message: msg}
+	if changed {
+		sess.after = func() { s.broadcast(sess, req.Room, TypeReacted, reply) }
+	}
+	return reply, nil
+}
+
+// changeGroup applies a change to the members or settings of a room on
+// behalf of the user, and records it in the room with a notice. The
+// members before and after the change are told about it.
+func (sess *session) changeGroup(req GroupBody) (interface{}, error) {
+	s := sess.server
+	if req.Change.Kind == model.ChangeAdd {
+		for _, name := range req.Change.Users {
+			if _, ok := s.user(name); !ok {
+				return nil, fmt.Errorf("unknown user %q", name)
+			}
+		}
+	}
+	s.mu.Lock()
+	ii := s.roomIndex(req.Room)
+	if ii < 0 || !s.rooms[ii].Group.Visible(sess.user) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("unknown room %q", req.Room)
+	}
+	before := s.rooms[ii].Group
+	after, text, err := before.Apply(sess.user, req.Change)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.rooms[ii].Group = after
+	notice := model.Notice(s.noticeSerial(req.Room), sess.user, text, time.Now())
+	err = s.messages.Put(req.Room, notice)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sess.after = func() {
+		s.push(func(other *session) bool {
+			return other != sess && (before.Visible(other.user) || after.Visible(other.user))
+		}, TypeGroup, GroupReply{Room: req.Room, Group: after})
+		s.broadcast(sess, req.Room, TypeMessage, MessageBody{Room: req.Room, Message: notice})
+	}
+	notice.Read = true
+	return GroupReply{Room: req.Room, Group: after, Notice: &notice}, nil
+}
+
+// noticeSerial returns an unused serial for a notice in the room, derived
+// from the current time like the serials of the client in this package.
+// It must be called with mu held.
+func (s *Server) noticeSerial(room string) list.Serial {
+	n := time.Now().UnixNano()
+	for {
+		serial := list.Serial(strconv.FormatInt(n, 10))
+		if _, taken := s.messages.Get(room, serial); !taken {
+			return serial
+		}
+		n++
+	}
+}
+
+// parseSerial validates that serial is a decimal integer.
+func parseSerial(serial list.Serial) (int64, error) {
+	n, err := strconv.ParseInt(string(serial), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid serial %q", serial)
+	}
+	return n, nil
+}
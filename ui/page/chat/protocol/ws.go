@@ -0,0 +1,367 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements the subset of RFC 6455 the protocol needs: text
+// messages, fragmentation, ping/pong and the closing handshake.
+
+// websocketGUID is appended to the handshake key to derive the accept key.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize is the largest message, in bytes, either side accepts.
+const MaxMessageSize = 16 << 20
+
+// opcode identifies the kind of a websocket frame.
+type opcode byte
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+// ErrClosed is returned when reading from or writing to a connection the
+// peer or the local side has closed.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// wsConn is a websocket connection.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// client connections mask the frames they send, as the spec requires.
+	client bool
+	wmu    sync.Mutex
+	closed bool
+}
+
+// acceptKey derives the Sec-WebSocket-Accept value for key.
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// dialWS opens a client websocket connection to the ws:// or wss:// URL.
+func dialWS(ctx context.Context, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	var (
+		d       net.Dialer
+		conn    net.Conn
+		host    = u.Host
+		secured bool
+	)
+	switch u.Scheme {
+	case "ws":
+	case "wss":
+		secured = true
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		if secured {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	if secured {
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = td.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dialing: %w", err)
+	}
+	ws, err := clientHandshake(ctx, conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// clientHandshake performs the opening handshake over conn.
+func clientHandshake(ctx context.Context, conn net.Conn, u *url.URL) (*wsConn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating handshake key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+		Host: u.Host,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("writing handshake: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("reading handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("handshake: unexpected status %s", resp.Status)
+	}
+	if !headerContains(resp.Header, "Upgrade", "websocket") {
+		return nil, errors.New("handshake: missing upgrade header")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, errors.New("handshake: invalid accept key")
+	}
+	return &wsConn{conn: conn, r: r, client: true}, nil
+}
+
+// upgradeWS upgrades the HTTP request to a server websocket connection.
+func upgradeWS(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("upgrade: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("upgrade: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, errors.New("upgrade: missing key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("upgrade: response cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// headerContains reports whether the comma separated header contains the
+// token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage reads the next text or binary message, transparently
+// answering pings. It returns ErrClosed once the closing handshake is done.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var (
+		msg     []byte
+		reading bool
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			// Echo the close frame to complete the closing handshake.
+			c.writeFrame(opClose, nil)
+			c.conn.Close()
+			return nil, ErrClosed
+		case opText, opBinary:
+			if reading {
+				return nil, errors.New("websocket: expected continuation frame")
+			}
+			msg, reading = payload, true
+		case opContinuation:
+			if !reading {
+				return nil, errors.New("websocket: unexpected continuation frame")
+			}
+			if len(msg)+len(payload) > MaxMessageSize {
+				return nil, errors.New("websocket: message too large")
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %#x", op)
+		}
+		// Control frames may be interleaved with the fragments of a
+		// message, so only data frames can complete it.
+		if reading && fin && op != opPing && op != opPong {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame, unmasking its payload.
+func (c *wsConn) readFrame() (fin bool, op opcode, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, c.readErr(err)
+	}
+	fin = header[0]&0x80 != 0
+	op = opcode(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, c.readErr(err)
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// readErr maps errors caused by a vanished connection to ErrClosed.
+func (c *wsConn) readErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+// WriteMessage writes p as a single text frame.
+func (c *wsConn) WriteMessage(p []byte) error {
+	return c.writeFrame(opText, p)
+}
+
+// writeFrame writes a single, final frame. It is safe to call
+// concurrently.
+func (c *wsConn) writeFrame(op opcode, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if op == opClose {
+		c.closed = true
+	}
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|byte(op))
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("websocket: generating mask: %w", err)
+		}
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		for i := range buf[start:] {
+			buf[start+i] ^= mask[i%4]
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return c.readErr(err)
+	}
+	return nil
+}
+
+// Close starts the closing handshake and releases the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestReadFragmented(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	server := &wsConn{conn: local, r: bufio.NewReader(local)}
+	client := &wsConn{conn: remote, r: bufio.NewReader(remote), client: true}
+
+	go func() {
+		// A message split over two fragments with a ping in between.
+		remote.Write([]byte{byte(opText), 0x80 | 3, 1, 2, 3, 4, 'h' ^ 1, 'e' ^ 2, 'l' ^ 3})
+		client.writeFrame(opPing, []byte("hi"))
+		remote.Write([]byte{0x80 | byte(opContinuation), 0x80 | 2, 0, 0, 0, 0, 'l', 'o'})
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		_, op, payload, err := client.readFrame()
+		if err == nil && op == opPong {
+			pong <- payload
+		}
+		close(pong)
+	}()
+
+	msg, err := server.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", msg)
+	}
+	if p := <-pong; !bytes.Equal(p, []byte("hi")) {
+		t.Errorf("expected pong echoing %q, got %q", "hi", p)
+	}
+}
+
+func TestFrameLengths(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		local, remote := net.Pipe()
+		server := &wsConn{conn: local, r: bufio.NewReader(local)}
+		client := &wsConn{conn: remote, r: bufio.NewReader(remote), client: true}
+		payload := bytes.Repeat([]byte{'x'}, n)
+		go client.WriteMessage(payload)
+		msg, err := server.ReadMessage()
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if !bytes.Equal(msg, payload) {
+			t.Errorf("length %d: payload mismatch", n)
+		}
+		local.Close()
+		remote.Close()
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", got)
+	}
+}
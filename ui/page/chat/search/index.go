@@ -0,0 +1,283 @@
+/*
+Package search implements an in-memory full-text index over chat messages.
+
+Messages are indexed by the words of their content and sender. Latin text
+is split into lower cased words; Chinese text, which has no spaces, is
+indexed character by character. A query matches the messages containing
+every one of its terms, where latin terms also match words they are a
+prefix of, so that results can be shown while typing.
+*/
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// Hit is a message matching a query.
+type Hit struct {
+	// Room the message belongs to.
+	Room string
+	// Message that matched.
+	Message model.Message
+	// Matches are the byte ranges of the message content that match the
+	// query, in order and without overlap.
+	Matches []Range
+}
+
+// Range is a byte range [Start, End) of a string.
+type Range struct {
+	Start, End int
+}
+
+// docKey identifies a message across rooms.
+type docKey struct {
+	room   string
+	serial list.Serial
+}
+
+// Index is an inverted index of messages. It is safe for concurrent use.
+type Index struct {
+	mu       sync.Mutex
+	docs     map[docKey]model.Message
+	postings map[string]map[docKey]struct{}
+	// terms holds the keys of postings in order, for prefix lookups. It
+	// is rebuilt lazily when stale.
+	terms []string
+	stale bool
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{
+		docs:     make(map[docKey]model.Message),
+		postings: make(map[string]map[docKey]struct{}),
+	}
+}
+
+// Add indexes a message of the room, replacing any message with the same
+// serial.
+func (x *Index) Add(room string, msg model.Message) {
+	key := docKey{room: room, serial: msg.Serial()}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old, ok := x.docs[key]; ok {
+		if old.Content == msg.Content && old.Sender == msg.Sender {
+			x.docs[key] = msg
+			return
+		}
+		x.unindex(key, old)
+	}
+	x.docs[key] = msg
+	for _, t := range terms(msg) {
+		docs, ok := x.postings[t]
+		if !ok {
+			docs = make(map[docKey]struct{})
+			x.postings[t] = docs
+			x.stale = true
+		}
+		docs[key] = struct{}{}
+	}
+}
+
+// Remove drops a message of the room from the index.
+func (x *Index) Remove(room string, serial list.Serial) {
+	key := docKey{room: room, serial: serial}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old, ok := x.docs[key]; ok {
+		x.unindex(key, old)
+		delete(x.docs, key)
+	}
+}
+
+// unindex removes the postings of a message.
+func (x *Index) unindex(key docKey, msg model.Message) {
+	for _, t := range terms(msg) {
+		docs := x.postings[t]
+		delete(docs, key)
+		if len(docs) == 0 {
+			delete(x.postings, t)
+			x.stale = true
+		}
+	}
+}
+
+// Len returns the number of indexed messages.
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.docs)
+}
+
+// Search returns up to limit messages matching query, most recent first.
+// A limit of zero or less returns all of them.
+func (x *Index) Search(query string, limit int) []Hit {
+	q := tokenize(query)
+	if len(q) == 0 {
+		return nil
+	}
+	x.mu.Lock()
+	var matched map[docKey]struct{}
+	for ii, t := range q {
+		docs := x.lookup(t.term)
+		if ii == 0 {
+			matched = docs
+			continue
+		}
+		for key := range matched {
+			if _, ok := docs[key]; !ok {
+				delete(matched, key)
+			}
+		}
+	}
+	hits := make([]Hit, 0, len(matched))
+	for key := range matched {
+		msg := x.docs[key]
+		hits = append(hits, Hit{
+			Room:    key.room,
+			Message: msg,
+			Matches: highlight(msg.Content, q),
+		})
+	}
+	x.mu.Unlock()
+
+	sort.Slice(hits, func(ii, jj int) bool {
+		a, b := hits[ii].Message, hits[jj].Message
+		if !a.SentAt.Equal(b.SentAt) {
+			return a.SentAt.After(b.SentAt)
+		}
+		return model.SerialLessThan(b.Serial(), a.Serial())
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// lookup returns a new set of the documents containing term, or a term
+// it is a prefix of.
+func (x *Index) lookup(term string) map[docKey]struct{} {
+	found := make(map[docKey]struct{})
+	if !isPrefixTerm(term) {
+		for key := range x.postings[term] {
+			found[key] = struct{}{}
+		}
+		return found
+	}
+	if x.stale {
+		x.terms = x.terms[:0]
+		for t := range x.postings {
+			x.terms = append(x.terms, t)
+		}
+		sort.Strings(x.terms)
+		x.stale = false
+	}
+	for ii := sort.SearchStrings(x.terms, term); ii < len(x.terms) && strings.HasPrefix(x.terms[ii], term); ii++ {
+		for key := range x.postings[x.terms[ii]] {
+			found[key] = struct{}{}
+		}
+	}
+	return found
+}
+
+// token is a term found in a text, along with its byte range.
+type token struct {
+	term string
+	Range
+}
+
+// tokenize splits s into terms: lower cased runs of letters and digits,
+// and single Chinese characters.
+func tokenize(s string) []token {
+	var (
+		tokens []token
+		start  = -1
+	)
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(s[start:end]), Range: Range{start, end}})
+			start = -1
+		}
+	}
+	for ii, r := range s {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush(ii)
+			end := ii + len(string(r))
+			tokens = append(tokens, token{term: s[ii:end], Range: Range{ii, end}})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = ii
+			}
+		default:
+			flush(ii)
+		}
+	}
+	flush(len(s))
+	return tokens
+}
+
+// terms returns the distinct terms a message is indexed under.
+func terms(msg model.Message) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range append(tokenize(msg.Content), tokenize(msg.Sender)...) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			out = append(out, t.term)
+		}
+	}
+	return out
+}
+
+// isPrefixTerm reports whether term also matches the terms it is a prefix
+// of. Single Chinese characters only match themselves.
+func isPrefixTerm(term string) bool {
+	for _, r := range term {
+		return !unicode.Is(unicode.Han, r)
+	}
+	return false
+}
+
+// highlight returns the ranges of content matching any of the query
+// tokens.
+func highlight(content string, query []token) []Range {
+	var matches []Range
+	for _, t := range tokenize(content) {
+		for _, q := range query {
+			if t.term == q.term || (isPrefixTerm(q.term) && strings.HasPrefix(t.term, q.term)) {
+				r := t.Range
+				if isPrefixTerm(q.term) {
+					// Only highlight the typed part of the word.
+					r.End = r.Start + runePrefixLen(content[r.Start:r.End], utf8.RuneCountInString(q.term))
+				}
+				if n := len(matches); n > 0 && matches[n-1].End >= r.Start {
+					if r.End > matches[n-1].End {
+						matches[n-1].End = r.End
+					}
+				} else {
+					matches = append(matches, r)
+				}
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// runePrefixLen returns the byte length of the first n runes of s.
+func runePrefixLen(s string, n int) int {
+	for ii := range s {
+		if n == 0 {
+			return ii
+		}
+		n--
+	}
+	return len(s)
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"strconv"
+	"testing"
+	"time"
+	"wechat_ui/ui/page/chat/model"
+)
+
+func message(serial int, sender, content string) model.Message {
+	return model.Message{
+		SerialID: strconv.Itoa(serial),
+		Sender:   sender,
+		Content:  content,
+		SentAt:   time.Unix(int64(serial), 0),
+	}
+}
+
+func TestSearch(t *testing.T) {
+	x := New()
+	x.Add("a", message(1, "alice", "Hello world"))
+	x.Add("a", message(2, "bob", "hello there, World traveller"))
+	x.Add("b", message(3, "carol", "我们明天见"))
+	x.Add("b", message(4, "alice", "see you tomorrow"))
+
+	type testcase struct {
+		name    string
+		query   string
+		serials []string
+	}
+	for _, tc := range []testcase{
+		{name: "word", query: "hello", serials: []string{"2", "1"}},
+		{name: "case insensitive", query: "WORLD", serials: []string{"2", "1"}},
+		{name: "all terms", query: "hello travel", serials: []string{"2"}},
+		{name: "prefix", query: "tom", serials: []string{"4"}},
+		{name: "sender", query: "alice", serials: []string{"4", "1"}},
+		{name: "chinese", query: "明天", serials: []string{"3"}},
+		{name: "no match", query: "goodbye", serials: nil},
+		{name: "empty", query: "  ,", serials: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hits := x.Search(tc.query, 0)
+			if len(hits) != len(tc.serials) {
+				t.Fatalf("expected %d hits, got %+v", len(tc.serials), hits)
+			}
+			for ii, hit := range hits {
+				if hit.Message.SerialID != tc.serials[ii] {
+					t.Errorf("expected hit %d to be %s, got %s", ii, tc.serials[ii], hit.Message.SerialID)
+				}
+			}
+		})
+	}
+	if hits := x.Search("hello", 1); len(hits) != 1 {
+		t.Errorf("expected limit to apply, got %d hits", len(hits))
+	}
+}
+
+func TestUpdateAndRemove(t *testing.T) {
+	x := New()
+	x.Add("a", message(1, "alice", "first draft"))
+	x.Add("a", message(1, "alice", "final version"))
+	if hits := x.Search("draft", 0); len(hits) != 0 {
+		t.Errorf("expected replaced content to be unindexed, got %+v", hits)
+	}
+	if hits := x.Search("final", 0); len(hits) != 1 || hits[0].Room != "a" {
+		t.Errorf("expected replaced content to be indexed, got %+v", hits)
+	}
+	x.Remove("a", "1")
+	if hits := x.Search("final", 0); len(hits) != 0 || x.Len() != 0 {
+		t.Errorf("expected removed message to be gone, got %+v", hits)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	x := New()
+	x.Add("a", message(1, "alice", "Hello, helicopter! 我们明天见"))
+	hits := x.Search("hel 明天", 0)
+	if len(hits) != 1 {
+		t.Fatalf("expected a hit, got %+v", hits)
+	}
+	content := hits[0].Message.Content
+	var got []string
+	for _, m := range hits[0].Matches {
+		got = append(got, content[m.Start:m.End])
+	}
+	want := []string{"Hel", "hel", "明天"}
+	if len(got) != len(want) {
+		t.Fatalf("expected matches %q, got %q", want, got)
+	}
+	for ii := range want {
+		if got[ii] != want[ii] {
+			t.Fatalf("expected matches %q, got %q", want, got)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	content := "the quick brown fox jumps over the lazy dog"
+	start := len("the quick brown fox jumps over the ")
+	snippet, matches := Snippet(content, []Range{{start, start + 4}}, 16)
+	if snippet != Ellipsis+"ver the lazy dog" {
+		t.Errorf("unexpected snippet %q", snippet)
+	}
+	if len(matches) != 1 || snippet[matches[0].Start:matches[0].End] != "lazy" {
+		t.Errorf("expected match to be translated, got %q in %q", matches, snippet)
+	}
+	if short, _ := Snippet("short\ntext", nil, 16); short != "short text" {
+		t.Errorf("expected short content to be kept, got %q", short)
+	}
+}
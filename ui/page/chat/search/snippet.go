@@ -0,0 +1,64 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Ellipsis marks text elided from a snippet.
+const Ellipsis = "…"
+
+// Snippet shortens content to about width runes around its first match,
+// so that the match is visible in a single line. It returns the snippet
+// along with the matches translated to it. Line breaks are replaced by
+// spaces.
+func Snippet(content string, matches []Range, width int) (string, []Range) {
+	content = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, content)
+	if width <= 0 || utf8.RuneCountInString(content) <= width {
+		return content, matches
+	}
+	// Start a few runes before the first match to give it some context.
+	const context = 8
+	start := 0
+	if len(matches) > 0 {
+		start = matches[0].Start
+		for ii := 0; ii < context && start > 0; ii++ {
+			_, size := utf8.DecodeLastRuneInString(content[:start])
+			start -= size
+		}
+	}
+	end := start
+	for n := 0; n < width && end < len(content); n++ {
+		_, size := utf8.DecodeRuneInString(content[end:])
+		end += size
+	}
+	var b strings.Builder
+	offset := -start
+	if start > 0 {
+		b.WriteString(Ellipsis)
+		offset += len(Ellipsis)
+	}
+	b.WriteString(content[start:end])
+	if end < len(content) {
+		b.WriteString(Ellipsis)
+	}
+	var out []Range
+	for _, m := range matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		if m.Start < start {
+			m.Start = start
+		}
+		if m.End > end {
+			m.End = end
+		}
+		out = append(out, Range{Start: m.Start + offset, End: m.End + offset})
+	}
+	return b.String(), out
+}
@@ -0,0 +1,626 @@
+/*
+Package store implements a durable, embedded message store.
+
+Messages are persisted to an append-only log of JSON records, one per line.
+On open the log is replayed into an in-memory index that maps each room to
+its message serials in sorted order, and each serial to the offset of its
+latest record within the log. Loads are range scans over that index; the
+message bodies themselves are read back from the log on demand.
+
+Records that were replaced or deleted stay in the log until it is
+compacted, which rewrites it with only the live records. Open compacts
+the log once enough of it is dead.
+*/
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// DefaultPageSize is the number of messages returned by a single load
+// when no page size is configured.
+const DefaultPageSize = 30
+
+// compactMinGarbage is the number of dead bytes the log must hold before
+// Open compacts it. Below that replaying the log is cheap regardless.
+const compactMinGarbage = 1 << 20
+
+// ErrClosed is returned by writes to a closed store.
+var ErrClosed = errors.New("store: closed")
+
+// op enumerates the kinds of records in the log.
+type op string
+
+const (
+	opPut    op = "put"
+	opDelete op = "del"
+	opMeta   op = "meta"
+)
+
+// record is a single line of the log.
+type record struct {
+	Op      op              `json:"op"`
+	Room    string          `json:"room,omitempty"`
+	Serial  list.Serial     `json:"serial,omitempty"`
+	Message *model.Message  `json:"message,omitempty"`
+	Key     string          `json:"key,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+// location of a record in the log.
+type location struct {
+	offset int64
+	length int
+	// message holds the record in memory for stores that are not backed
+	// by a file.
+	message *model.Message
+}
+
+// roomIndex indexes the messages of a single room.
+type roomIndex struct {
+	// serials is sorted with model.SerialLessThan.
+	serials   []list.Serial
+	locations map[list.Serial]location
+}
+
+// search returns the index of the first serial that does not sort before s.
+func (ri *roomIndex) search(s list.Serial) int {
+	return sort.Search(len(ri.serials), func(i int) bool {
+		return !model.SerialLessThan(ri.serials[i], s)
+	})
+}
+
+// insert adds serial to the index, or updates its location if it is
+// already present. Appending the newest message is the common case and
+// costs O(1).
+func (ri *roomIndex) insert(s list.Serial, loc location) {
+	if _, ok := ri.locations[s]; !ok {
+		n := len(ri.serials)
+		if n == 0 || model.SerialLessThan(ri.serials[n-1], s) {
+			ri.serials = append(ri.serials, s)
+		} else {
+			i := ri.search(s)
+			ri.serials = append(ri.serials, "")
+			copy(ri.serials[i+1:], ri.serials[i:])
+			ri.serials[i] = s
+		}
+	}
+	ri.locations[s] = loc
+}
+
+// remove deletes serial from the index, if present.
+func (ri *roomIndex) remove(s list.Serial) {
+	if _, ok := ri.locations[s]; !ok {
+		return
+	}
+	delete(ri.locations, s)
+	i := ri.search(s)
+	ri.serials = append(ri.serials[:i], ri.serials[i+1:]...)
+}
+
+// Store is a durable message store keyed by room and list.Serial.
+// It is safe for concurrent use.
+type Store struct {
+	// PageSize specifies the number of messages a single load in either
+	// direction can return. Defaults to DefaultPageSize.
+	PageSize int
+
+	mu     sync.RWMutex
+	path   string
+	file   *os.File
+	closed bool
+	end    int64
+	// garbage counts the bytes of the log taken by records that were
+	// replaced or deleted.
+	garbage int64
+	rooms   map[string]*roomIndex
+	meta    map[string]json.RawMessage
+	// metaLengths holds the length of the latest record of each meta key.
+	metaLengths map[string]int
+}
+
+// Open opens the store persisted at path, creating it if necessary.
+// An empty path opens a store that is held only in memory.
+//
+// A partially written record at the end of the log, such as one left by
+// a crash, is discarded. The log is compacted if at least half of it is
+// dead.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:        path,
+		rooms:       make(map[string]*roomIndex),
+		meta:        make(map[string]json.RawMessage),
+		metaLengths: make(map[string]int),
+	}
+	if path == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("preparing store directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+	s.file = f
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("replaying store: %w", err)
+	}
+	if s.garbage >= compactMinGarbage && s.garbage*2 >= s.end {
+		if err := s.compactLocked(); err != nil {
+			s.file.Close()
+			return nil, fmt.Errorf("compacting store: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// replay rebuilds the index from the log.
+func (s *Store) replay() error {
+	r := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Anything without a trailing newline is an incomplete write.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("decoding record at offset %d: %w", offset, err)
+		}
+		s.apply(rec, location{offset: offset, length: len(line)})
+		offset += int64(len(line))
+	}
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	s.end = offset
+	return nil
+}
+
+// apply updates the index with a record found at loc.
+func (s *Store) apply(rec record, loc location) {
+	switch rec.Op {
+	case opPut:
+		if rec.Message == nil {
+			s.garbage += int64(loc.length)
+			return
+		}
+		ri := s.room(rec.Room)
+		if old, ok := ri.locations[rec.Message.Serial()]; ok {
+			s.garbage += int64(old.length)
+		}
+		ri.insert(rec.Message.Serial(), loc)
+	case opDelete:
+		ri := s.room(rec.Room)
+		if old, ok := ri.locations[rec.Serial]; ok {
+			s.garbage += int64(old.length)
+		}
+		// The delete record itself is only needed until the log is
+		// compacted.
+		s.garbage += int64(loc.length)
+		ri.remove(rec.Serial)
+	case opMeta:
+		s.garbage += int64(s.metaLengths[rec.Key])
+		s.meta[rec.Key] = rec.Value
+		s.metaLengths[rec.Key] = loc.length
+	default:
+		s.garbage += int64(loc.length)
+	}
+}
+
+// room returns the index for the named room, allocating it if needed.
+func (s *Store) room(name string) *roomIndex {
+	ri, ok := s.rooms[name]
+	if !ok {
+		ri = &roomIndex{locations: make(map[list.Serial]location)}
+		s.rooms[name] = ri
+	}
+	return ri
+}
+
+// append writes the record to the end of the log and applies it.
+func (s *Store) append(rec record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendLocked(rec)
+}
+
+// appendLocked is like append, but requires the caller to hold the write
+// lock.
+func (s *Store) appendLocked(rec record) error {
+	if s.closed {
+		return ErrClosed
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+	line = append(line, '\n')
+	loc := location{offset: s.end, length: len(line), message: rec.Message}
+	if s.file != nil {
+		if _, err := s.file.WriteAt(line, s.end); err != nil {
+			return fmt.Errorf("writing record: %w", err)
+		}
+		loc.message = nil
+	}
+	s.end += int64(len(line))
+	s.apply(rec, loc)
+	return nil
+}
+
+// Put stores the message in the named room, replacing any message with
+// the same serial.
+func (s *Store) Put(room string, msg model.Message) error {
+	return s.append(record{Op: opPut, Room: room, Message: &msg})
+}
+
+// Update replaces the message with the same serial in the named room,
+// reporting false without storing anything if there is none.
+func (s *Store) Update(room string, msg model.Message) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ri, ok := s.rooms[room]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := ri.locations[msg.Serial()]; !ok {
+		return false, nil
+	}
+	return true, s.appendLocked(record{Op: opPut, Room: room, Message: &msg})
+}
+
+// Modify applies fn to the message with the provided serial in the named
+// room and stores the result, atomically with respect to other writes. It
+// returns the stored message, or false without storing anything if there
+// is no such message or fn returns false.
+func (s *Store) Modify(room string, serial list.Serial, fn func(msg *model.Message) bool) (model.Message, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ri, ok := s.rooms[room]
+	if !ok {
+		return model.Message{}, false, nil
+	}
+	loc, ok := ri.locations[serial]
+	if !ok {
+		return model.Message{}, false, nil
+	}
+	msg, err := s.read(loc)
+	if err != nil {
+		return model.Message{}, false, err
+	}
+	if !fn(&msg) {
+		return msg, false, nil
+	}
+	if err := s.appendLocked(record{Op: opPut, Room: room, Message: &msg}); err != nil {
+		return model.Message{}, false, err
+	}
+	return msg, true, nil
+}
+
+// Delete removes the message with the provided serial from the named room.
+func (s *Store) Delete(room string, serial list.Serial) error {
+	return s.append(record{Op: opDelete, Room: room, Serial: serial})
+}
+
+// SetMeta stores an arbitrary JSON-encodable value under key.
+func (s *Store) SetMeta(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding meta %q: %w", key, err)
+	}
+	return s.append(record{Op: opMeta, Key: key, Value: value})
+}
+
+// Meta decodes the value stored under key into v, reporting whether the
+// key was present.
+func (s *Store) Meta(key string, v interface{}) (bool, error) {
+	s.mu.RLock()
+	value, ok := s.meta[key]
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return true, fmt.Errorf("decoding meta %q: %w", key, err)
+	}
+	return true, nil
+}
+
+// Len returns the number of messages stored in the named room.
+func (s *Store) Len(room string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ri, ok := s.rooms[room]; ok {
+		return len(ri.serials)
+	}
+	return 0
+}
+
+// Has reports whether the named room holds a message with the provided
+// serial.
+func (s *Store) Has(room string, serial list.Serial) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ri, ok := s.rooms[room]; ok {
+		_, ok := ri.locations[serial]
+		return ok
+	}
+	return false
+}
+
+// Get returns the message with the provided serial in the named room.
+func (s *Store) Get(room string, serial list.Serial) (model.Message, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ri, ok := s.rooms[room]
+	if !ok {
+		return model.Message{}, false
+	}
+	loc, ok := ri.locations[serial]
+	if !ok {
+		return model.Message{}, false
+	}
+	msg, err := s.read(loc)
+	if err != nil {
+		return model.Message{}, false
+	}
+	return msg, true
+}
+
+// Latest returns the newest message in the named room, if any.
+func (s *Store) Latest(room string) (model.Message, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ri, ok := s.rooms[room]
+	if !ok || len(ri.serials) == 0 {
+		return model.Message{}, false
+	}
+	msg, err := s.read(ri.locations[ri.serials[len(ri.serials)-1]])
+	if err != nil {
+		return model.Message{}, false
+	}
+	return msg, true
+}
+
+// Load fulfills the list.Loader contract for the named room. Loading
+// relative to NoSerial returns the most recent page of messages.
+func (s *Store) Load(room string, dir list.Direction, relativeTo list.Serial) ([]list.Element, bool) {
+	return s.LoadLimit(room, dir, relativeTo, s.PageSize)
+}
+
+// LoadLimit is like Load, but returns at most limit messages. A limit that
+// is not positive falls back to DefaultPageSize.
+func (s *Store) LoadLimit(room string, dir list.Direction, relativeTo list.Serial, limit int) ([]list.Element, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ri, ok := s.rooms[room]
+	if !ok {
+		return nil, false
+	}
+	size := limit
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	var start, end int
+	switch {
+	case relativeTo == list.NoSerial:
+		end = len(ri.serials)
+		start = max(0, end-size)
+	case dir == list.After:
+		start = ri.search(relativeTo)
+		if start < len(ri.serials) && ri.serials[start] == relativeTo {
+			start++
+		}
+		end = min(len(ri.serials), start+size)
+	default:
+		end = ri.search(relativeTo)
+		start = max(0, end-size)
+	}
+	elems, err := s.readRange(ri, start, end)
+	if err != nil {
+		return nil, false
+	}
+	if relativeTo != list.NoSerial && dir == list.After {
+		return elems, end < len(ri.serials)
+	}
+	return elems, start > 0
+}
+
+// LoadAround fulfills the list.AroundLoader contract for the named room,
+// returning a page of messages centered on the one with the provided
+// serial. If there is no such message the page is centered where it
+// would sort.
+func (s *Store) LoadAround(room string, serial list.Serial) ([]list.Element, bool, bool) {
+	return s.LoadAroundLimit(room, serial, s.PageSize)
+}
+
+// LoadAroundLimit is like LoadAround, but returns at most limit messages.
+// A limit that is not positive falls back to DefaultPageSize.
+func (s *Store) LoadAroundLimit(room string, serial list.Serial, limit int) ([]list.Element, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ri, ok := s.rooms[room]
+	if !ok {
+		return nil, false, false
+	}
+	size := limit
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	start := max(0, ri.search(serial)-size/2)
+	end := min(len(ri.serials), start+size)
+	start = max(0, end-size)
+	elems, err := s.readRange(ri, start, end)
+	if err != nil {
+		return nil, false, false
+	}
+	return elems, start > 0, end < len(ri.serials)
+}
+
+// Loader returns a list.Loader for the named room.
+func (s *Store) Loader(room string) list.Loader {
+	return func(dir list.Direction, relativeTo list.Serial) ([]list.Element, bool) {
+		return s.Load(room, dir, relativeTo)
+	}
+}
+
+// readRange reads the messages at index [start, end) of the room.
+func (s *Store) readRange(ri *roomIndex, start, end int) ([]list.Element, error) {
+	elems := make([]list.Element, 0, end-start)
+	for _, serial := range ri.serials[start:end] {
+		msg, err := s.read(ri.locations[serial])
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, msg)
+	}
+	return elems, nil
+}
+
+// read the message stored at loc.
+func (s *Store) read(loc location) (model.Message, error) {
+	if loc.message != nil {
+		return *loc.message, nil
+	}
+	buf := make([]byte, loc.length)
+	if _, err := s.file.ReadAt(buf, loc.offset); err != nil {
+		return model.Message{}, fmt.Errorf("reading record: %w", err)
+	}
+	var rec record
+	if err := json.Unmarshal(buf, &rec); err != nil {
+		return model.Message{}, fmt.Errorf("decoding record: %w", err)
+	}
+	if rec.Message == nil {
+		return model.Message{}, fmt.Errorf("record at offset %d holds no message", loc.offset)
+	}
+	return *rec.Message, nil
+}
+
+// Compact rewrites the log so that it holds only the latest record of
+// each stored message and meta key. Stores held only in memory have
+// nothing to reclaim.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.compactLocked()
+}
+
+// compactLocked is like Compact, but requires the caller to hold the write
+// lock. The live records are written to a temporary file next to the log,
+// which then replaces it, so a crash midway leaves the old log intact.
+func (s *Store) compactLocked() (err error) {
+	if s.file == nil {
+		s.garbage = 0
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	var (
+		w           = bufio.NewWriter(tmp)
+		end         int64
+		rooms       = make(map[string]*roomIndex, len(s.rooms))
+		metaLengths = make(map[string]int, len(s.meta))
+	)
+	write := func(rec record) (location, error) {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return location{}, fmt.Errorf("encoding record: %w", err)
+		}
+		line = append(line, '\n')
+		if _, err := w.Write(line); err != nil {
+			return location{}, fmt.Errorf("writing record: %w", err)
+		}
+		loc := location{offset: end, length: len(line)}
+		end += int64(len(line))
+		return loc, nil
+	}
+	for name, ri := range s.rooms {
+		compacted := &roomIndex{
+			serials:   ri.serials,
+			locations: make(map[list.Serial]location, len(ri.locations)),
+		}
+		for _, serial := range ri.serials {
+			msg, err := s.read(ri.locations[serial])
+			if err != nil {
+				return err
+			}
+			loc, err := write(record{Op: opPut, Room: name, Message: &msg})
+			if err != nil {
+				return err
+			}
+			compacted.locations[serial] = loc
+		}
+		rooms[name] = compacted
+	}
+	for key, value := range s.meta {
+		loc, err := write(record{Op: opMeta, Key: key, Value: value})
+		if err != nil {
+			return err
+		}
+		metaLengths[key] = loc.length
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = tmp
+	s.end = end
+	s.garbage = 0
+	s.rooms = rooms
+	s.metaLengths = metaLengths
+	return nil
+}
+
+// Close flushes the log to disk and closes it. Calling it again has no
+// effect.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	f := s.file
+	s.file = nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing store: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,376 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/list"
+)
+
+// fill puts messages with serials [from, to) into room, out of order.
+func fill(t *testing.T, s *Store, room string, from, to int) {
+	t.Helper()
+	for i := to - 1; i >= from; i-- {
+		msg := model.Message{SerialID: strconv.Itoa(i), Content: "msg " + strconv.Itoa(i)}
+		if err := s.Put(room, msg); err != nil {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+}
+
+// serials extracts the serials of elems.
+func serials(elems []list.Element) []list.Serial {
+	out := make([]list.Serial, len(elems))
+	for i, e := range elems {
+		out[i] = e.Serial()
+	}
+	return out
+}
+
+func expectSerials(t *testing.T, got []list.Element, want ...int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d elements, got %v", len(want), serials(got))
+	}
+	for i := range want {
+		if got[i].Serial() != list.Serial(strconv.Itoa(want[i])) {
+			t.Fatalf("expected %v, got %v", want, serials(got))
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PageSize = 3
+	fill(t, s, "room", 0, 10)
+
+	type testcase struct {
+		name       string
+		dir        list.Direction
+		relativeTo list.Serial
+		want       []int
+		more       bool
+	}
+	for _, tc := range []testcase{
+		{
+			name:       "latest page",
+			dir:        list.Before,
+			relativeTo: list.NoSerial,
+			want:       []int{7, 8, 9},
+			more:       true,
+		},
+		{
+			name:       "before middle",
+			dir:        list.Before,
+			relativeTo: "5",
+			want:       []int{2, 3, 4},
+			more:       true,
+		},
+		{
+			name:       "before start",
+			dir:        list.Before,
+			relativeTo: "2",
+			want:       []int{0, 1},
+			more:       false,
+		},
+		{
+			name:       "after middle",
+			dir:        list.After,
+			relativeTo: "3",
+			want:       []int{4, 5, 6},
+			more:       true,
+		},
+		{
+			name:       "after end",
+			dir:        list.After,
+			relativeTo: "7",
+			want:       []int{8, 9},
+			more:       false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, more := s.Load("room", tc.dir, tc.relativeTo)
+			expectSerials(t, got, tc.want...)
+			if more != tc.more {
+				t.Errorf("expected more=%v, got %v", tc.more, more)
+			}
+		})
+	}
+	if got, _ := s.Load("missing", list.Before, list.NoSerial); len(got) != 0 {
+		t.Errorf("expected no elements for unknown room, got %v", serials(got))
+	}
+}
+
+func TestLoadAround(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PageSize = 4
+	fill(t, s, "room", 0, 10)
+
+	type testcase struct {
+		name                  string
+		serial                list.Serial
+		want                  []int
+		moreBefore, moreAfter bool
+	}
+	for _, tc := range []testcase{
+		{
+			name:       "middle",
+			serial:     "5",
+			want:       []int{3, 4, 5, 6},
+			moreBefore: true,
+			moreAfter:  true,
+		},
+		{
+			name:      "start",
+			serial:    "1",
+			want:      []int{0, 1, 2, 3},
+			moreAfter: true,
+		},
+		{
+			name:       "end",
+			serial:     "9",
+			want:       []int{6, 7, 8, 9},
+			moreBefore: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, moreBefore, moreAfter := s.LoadAround("room", tc.serial)
+			expectSerials(t, got, tc.want...)
+			if moreBefore != tc.moreBefore || moreAfter != tc.moreAfter {
+				t.Errorf("expected more=(%v, %v), got (%v, %v)",
+					tc.moreBefore, tc.moreAfter, moreBefore, moreAfter)
+			}
+		})
+	}
+	if got, _, _ := s.LoadAround("missing", "1"); len(got) != 0 {
+		t.Errorf("expected no elements for unknown room, got %v", serials(got))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "room", 0, 5)
+	if err := s.Delete("room", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("room", "42"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Load("room", list.Before, list.NoSerial)
+	expectSerials(t, got, 0, 1, 3, 4)
+	if s.Len("room") != 4 {
+		t.Errorf("expected 4 messages, got %d", s.Len("room"))
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "a", 0, 4)
+	fill(t, s, "b", 10, 12)
+	if err := s.Delete("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("a", model.Message{SerialID: "3", Content: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMeta("key", []string{"value"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, _ := s.Load("a", list.Before, list.NoSerial)
+	expectSerials(t, got, 0, 2, 3)
+	if content := got[2].(model.Message).Content; content != "edited" {
+		t.Errorf("expected replaced message, got content %q", content)
+	}
+	got, _ = s.Load("b", list.Before, list.NoSerial)
+	expectSerials(t, got, 10, 11)
+	latest, ok := s.Latest("b")
+	if !ok || latest.SerialID != "11" {
+		t.Errorf("expected latest serial 11, got %q (%v)", latest.SerialID, ok)
+	}
+	var meta []string
+	if found, err := s.Meta("key", &meta); err != nil || !found {
+		t.Fatalf("expected meta to be found, got found=%v err=%v", found, err)
+	}
+	if len(meta) != 1 || meta[0] != "value" {
+		t.Errorf("unexpected meta %v", meta)
+	}
+}
+
+func TestTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "room", 0, 3)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"put","room":"room","mess`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("expected partial record to be discarded, got %v", err)
+	}
+	defer s.Close()
+	fill(t, s, "room", 3, 4)
+	got, _ := s.Load("room", list.Before, list.NoSerial)
+	expectSerials(t, got, 0, 1, 2, 3)
+}
+
+func TestUpdate(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "room", 0, 2)
+	ok, err := s.Update("room", model.Message{SerialID: "1", Content: "updated"})
+	if err != nil || !ok {
+		t.Fatalf("expected update of existing message, got ok=%v err=%v", ok, err)
+	}
+	ok, err = s.Update("room", model.Message{SerialID: "5"})
+	if err != nil || ok {
+		t.Fatalf("expected update of missing message to be skipped, got ok=%v err=%v", ok, err)
+	}
+	got, _ := s.Load("room", list.Before, list.NoSerial)
+	expectSerials(t, got, 0, 1)
+	if content := got[1].(model.Message).Content; content != "updated" {
+		t.Errorf("expected updated content, got %q", content)
+	}
+}
+
+func TestModify(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fill(t, s, "room", 0, 2)
+	msg, ok, err := s.Modify("room", "1", func(msg *model.Message) bool {
+		msg.Reactions = msg.Reactions.With("👍", "alice", true)
+		return true
+	})
+	if err != nil || !ok {
+		t.Fatalf("expected modification of existing message, got ok=%v err=%v", ok, err)
+	}
+	if msg.Content != "msg 1" || !msg.Reactions.Has("👍", "alice") {
+		t.Errorf("unexpected modified message %+v", msg)
+	}
+	if got, _ := s.Get("room", "1"); !got.Reactions.Has("👍", "alice") {
+		t.Errorf("expected modification to be stored, got %+v", got)
+	}
+	_, ok, err = s.Modify("room", "0", func(*model.Message) bool { return false })
+	if err != nil || ok {
+		t.Fatalf("expected declined modification to be skipped, got ok=%v err=%v", ok, err)
+	}
+	_, ok, err = s.Modify("room", "5", func(*model.Message) bool {
+		t.Error("expected fn not to be called for missing message")
+		return true
+	})
+	if err != nil || ok {
+		t.Fatalf("expected modification of missing message to be skipped, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.log")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "room", 0, 4)
+	for i := 0; i < 10; i++ {
+		if err := s.Put("room", model.Message{SerialID: "2", Content: "edit " + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetMeta("key", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("room", "0"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("expected compaction to shrink the log, got %d bytes from %d", after.Size(), before.Size())
+	}
+	// The compacted log must remain writable.
+	fill(t, s, "room", 4, 5)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, _ := s.Load("room", list.Before, list.NoSerial)
+	expectSerials(t, got, 1, 2, 3, 4)
+	if content := got[1].(model.Message).Content; content != "edit 9" {
+		t.Errorf("expected latest edit, got content %q", content)
+	}
+	var meta int
+	if found, err := s.Meta("key", &meta); err != nil || !found || meta != 9 {
+		t.Errorf("expected meta 9, got %v (found=%v err=%v)", meta, found, err)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "messages.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, s, "room", 0, 1)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("expected second close to be a no-op, got %v", err)
+	}
+	if err := s.Put("room", model.Message{SerialID: "1"}); err != ErrClosed {
+		t.Errorf("expected put after close to fail with ErrClosed, got %v", err)
+	}
+}
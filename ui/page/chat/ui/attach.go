@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
+	chatlayout "wechat_ui/ui/pkg/layout"
+	matchat "wechat_ui/ui/pkg/widget/material"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+)
+
+// AttachDialogWidth is the width of the dialog asking for the file to
+// attach.
+var AttachDialogWidth = unit.Dp(420)
+
+// AttachDialog holds the state of the dialog asking for the path of a file
+// to attach to the next message. A path is entered by hand as no native
+// file picker is guaranteed to be available.
+type AttachDialog struct {
+	// Path is the editor the path of the file is entered in.
+	Path widget.Editor
+	// Confirm and Cancel track clicks on the dialog buttons.
+	Confirm, Cancel widget.Clickable
+	// card absorbs the clicks on the dialog, which would otherwise dismiss
+	// the modal.
+	card widget.Clickable
+	// images restricts the dialog to image files.
+	images bool
+	// err describes why the last path was rejected.
+	err string
+}
+
+// Staged is an attachment waiting to be sent with the next message.
+type Staged struct {
+	model.Attachment
+	// Remove tracks clicks on the button removing the attachment.
+	Remove widget.Clickable
+}
+
+// showAttachDialog shows the dialog asking for a file to attach, or an
+// image if images is set.
+func (ui *UI) showAttachDialog(gtx C, images bool) {
+	d := &ui.AttachDialog
+	d.images = images
+	d.err = ""
+	d.Path.SingleLine = true
+	d.Path.Submit = true
+	d.Path.Focus()
+	ui.Modal.Show(gtx.Now, ui.layoutAttachDialog)
+}
+
+// stage describes the file at the path entered in the dialog and stages
+// it in the active room, reporting whether it succeeded.
+func (ui *UI) stage() bool {
+	d := &ui.AttachDialog
+	path := strings.TrimSpace(d.Path.Text())
+	if path == "" {
+		return false
+	}
+	a, err := backend.DescribeFile(path)
+	if err != nil {
+		d.err = err.Error()
+		return false
+	}
+	if d.images && !a.IsImage() {
+		d.err = fmt.Sprintf("%s is not an image", a.Name)
+		return false
+	}
+	active := ui.Rooms.Active()
+	active.Staged = append(active.Staged, &Staged{Attachment: a})
+	d.Path.SetText("")
+	d.err = ""
+	return true
+}
+
+// layoutAttachDialog lays out the dialog asking for a file to attach.
+func (ui *UI) layoutAttachDialog(gtx C) D {
+	d := &ui.AttachDialog
+	confirmed := d.Confirm.Clicked()
+	for _, e := range d.Path.Events() {
+		if _, ok := e.(widget.SubmitEvent); ok {
+			confirmed = true
+		}
+	}
+	if d.Cancel.Clicked() || confirmed && ui.stage() {
+		ui.Modal.Disappear(gtx.Now)
+	}
+	title, hint := "Attach a file", "Path of the file"
+	if d.images {
+		title, hint = "Attach an image", "Path of the image"
+	}
+	return layout.Center.Layout(gtx, func(gtx C) D {
+		width := gtx.Dp(AttachDialogWidth)
+		if width > gtx.Constraints.Max.X {
+			width = gtx.Constraints.Max.X
+		}
+		gtx.Constraints.Min.X = width
+		gtx.Constraints.Max.X = width
+		return d.card.Layout(gtx, func(gtx C) D {
+			return chatlayout.Rounded(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+				return chatlayout.Background(th.Palette.Surface).Layout(gtx, func(gtx C) D {
+					return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+						return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+							layout.Rigid(material.H6(th.Theme, title).Layout),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(12)}.Layout),
+							layout.Rigid(func(gtx C) D {
+								return component.Surface(th.Theme).Layout(gtx, func(gtx C) D {
+									return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Editor(th.Theme, &d.Path, hint).Layout)
+								})
+							}),
+							layout.Rigid(func(gtx C) D {
+								if d.err == "" {
+									return D{}
+								}
+								lbl := material.Body2(th.Theme, d.err)
+								lbl.Color = matchat.DefaultDangerColor
+								return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, lbl.Layout)
+							}),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(12)}.Layout),
+							layout.Rigid(func(gtx C) D {
+								return layout.E.Layout(gtx, func(gtx C) D {
+									return layout.Flex{}.Layout(gtx,
+										layout.Rigid(func(gtx C) D {
+											btn := material.Button(th.Theme, &d.Cancel, "Cancel")
+											btn.Background = th.Palette.Surface
+											btn.Color = th.Fg
+											return btn.Layout(gtx)
+										}),
+										layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+										layout.Rigid(material.Button(th.Theme, &d.Confirm, "Attach").Layout),
+									)
+								})
+							}),
+						)
+					})
+				})
+			})
+		})
+	})
+}
+
+// layoutStaged lays out the attachments staged in the room as chips that
+// can be removed.
+func (ui *UI) layoutStaged(gtx C, room *Room) D {
+	for ii := 0; ii < len(room.Staged); ii++ {
+		if room.Staged[ii].Remove.Clicked() {
+			room.Staged = append(room.Staged[:ii], room.Staged[ii+1:]...)
+			ii--
+		}
+	}
+	if len(room.Staged) == 0 {
+		return D{}
+	}
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+		room.StagedList.Axis = layout.Horizontal
+		return room.StagedList.Layout(gtx, len(room.Staged), func(gtx C, ii int) D {
+			return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+				return layoutChip(gtx, room.Staged[ii])
+			})
+		})
+	})
+}
+
+// layoutChip lays out a staged attachment.
+func layoutChip(gtx C, s *Staged) D {
+	gtx.Constraints.Min = image.Point{}
+	return chatlayout.Rounded(unit.Dp(12)).Layout(gtx, func(gtx C) D {
+		return chatlayout.Background(component.WithAlpha(th.Fg, 20)).Layout(gtx, func(gtx C) D {
+			return layout.Inset{Left: unit.Dp(10), Right: unit.Dp(2), Top: unit.Dp(2), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Max.X = gtx.Dp(unit.Dp(200))
+						lbl := material.Body2(th.Theme, s.Name)
+						lbl.MaxLines = 1
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(4)}.Layout),
+					layout.Rigid(func(gtx C) D {
+						lbl := material.Caption(th.Theme, matchat.FormatSize(s.Size))
+						lbl.Color = component.WithAlpha(th.Fg, 150)
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						btn := material.IconButton(th.Theme, &s.Remove, Close, "Remove attachment")
+						btn.Background = color.NRGBA{}
+						btn.Color = th.Fg
+						btn.Size = unit.Dp(14)
+						btn.Inset = layout.UniformInset(unit.Dp(4))
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		})
+	})
+}
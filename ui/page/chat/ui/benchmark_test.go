@@ -9,6 +9,7 @@ import (
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/unit"
+	"wechat_ui/ui/page/chat/backend"
 )
 
 func BenchmarkKitchen(b *testing.B) {
@@ -18,11 +19,16 @@ func BenchmarkKitchen(b *testing.B) {
 	if err != nil {
 		b.Error(err)
 	}
+	demo, err := backend.NewDemo(backend.DemoConfig{
+		LoadSize: 10,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer demo.Close()
 	ui := NewUI(func() {}, Config{
-		Theme:      "light",
-		LoadSize:   10,
 		BufferSize: 100,
-	})
+	}, demo)
 	gtx := layout.Context{
 		Ops: new(op.Ops),
 		Metric: unit.Metric{
@@ -0,0 +1,234 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/clipimage"
+	"wechat_ui/ui/pkg/list"
+	"wechat_ui/ui/v"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+
+	chatwidget "wechat_ui/ui/pkg/widget"
+)
+
+// pastedImage is an image pasted in a room, saved to a file to be staged.
+type pastedImage struct {
+	room *Room
+	model.Attachment
+}
+
+// copyMessage copies msg to the clipboard: the pixels of image messages,
+// and the content of the others, or their preview if they have none.
+func (ui *UI) copyMessage(gtx C, msg model.Message) {
+	if msg.Image == "" {
+		text := msg.Content
+		if text == "" {
+			text = msg.Preview()
+		}
+		clipboard.WriteOp{Text: text}.Add(gtx.Ops)
+		return
+	}
+	id, url := string(msg.Serial())+"-body", msg.Image
+	go func() {
+		if err := copyImage(id, url); err != nil {
+			log.Printf("copying image: %v", err)
+		}
+	}()
+}
+
+// copyImage places the image at url, cached under id, on the clipboard.
+func copyImage(id, url string) error {
+	img, err := fetch(id, url)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("encoding image: %w", err)
+	}
+	return clipimage.Write(buf.Bytes())
+}
+
+// pasteWatcher is the event queue of the editor of room. It stages the
+// image on the clipboard, if any, on the paste shortcut, which the editor
+// still handles to paste text.
+type pasteWatcher struct {
+	event.Queue
+	ui   *UI
+	room *Room
+}
+
+func (q pasteWatcher) Events(t event.Tag) []event.Event {
+	evs := q.Queue.Events(t)
+	for _, e := range evs {
+		if e, ok := e.(key.Event); ok && e.State == key.Press && e.Name == "V" && e.Modifiers == key.ModShortcut {
+			go q.ui.readPasted(q.room, true)
+		}
+	}
+	return evs
+}
+
+// pasteImage stages the image on the clipboard, if any, as an attachment of
+// room. The image is read asynchronously and staged by stagePasted.
+func (ui *UI) pasteImage(room *Room) {
+	go ui.readPasted(room, false)
+}
+
+// readPasted reads the image on the clipboard for stagePasted. Errors are
+// not logged if quiet is set, as the clipboard read along with the text
+// pasted by the editor seldom holds an image.
+func (ui *UI) readPasted(room *Room, quiet bool) {
+	a, err := savePasted()
+	if err != nil {
+		if !quiet && !errors.Is(err, clipimage.ErrNoImage) {
+			log.Printf("pasting image: %v", err)
+		}
+		return
+	}
+	ui.pasted <- pastedImage{room: room, Attachment: a}
+	ui.invalidate()
+}
+
+// savePasted saves the image on the clipboard to a file and describes it.
+func savePasted() (model.Attachment, error) {
+	data, err := clipimage.Read()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	dir := filepath.Join(os.TempDir(), "chat", "pasted")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return model.Attachment{}, fmt.Errorf("preparing paste directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("paste-%d.png", time.Now().UnixNano()))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return model.Attachment{}, fmt.Errorf("saving pasted image: %w", err)
+	}
+	return backend.DescribeFile(path)
+}
+
+// stagePasted stages the image pasted by pasteImage, if any, in the room it
+// was pasted in.
+func (ui *UI) stagePasted() {
+	select {
+	case p := <-ui.pasted:
+		p.room.Staged = append(p.room.Staged, &Staged{Attachment: p.Attachment})
+	default:
+	}
+}
+
+// pasteText inserts the text on the clipboard in the editor of the active
+// room, once it is read on behalf of the editor menu.
+func (ui *UI) pasteText(gtx C) {
+	for _, e := range gtx.Events(&ui.EditorMenu) {
+		if e, ok := e.(clipboard.Event); ok {
+			editor := &ui.Rooms.Active().Editor
+			editor.Insert(e.Text)
+			editor.Focus()
+		}
+	}
+	if ui.PasteBtn.Clicked() {
+		clipboard.ReadOp{Tag: &ui.EditorMenu}.Add(gtx.Ops)
+	}
+	if ui.PasteImageBtn.Clicked() {
+		ui.pasteImage(ui.Rooms.Active())
+	}
+}
+
+// editorMenu returns the options of the context menu of the editor.
+func (ui *UI) editorMenu() []func(gtx C) D {
+	return []func(gtx C) D{
+		component.MenuItem(th.Theme, &ui.PasteBtn, "Paste").Layout,
+		component.MenuItem(th.Theme, &ui.PasteImageBtn, "Paste image").Layout,
+	}
+}
+
+// startSelecting starts selecting the messages of room to copy, with msg
+// selected.
+func startSelecting(room *Room, msg model.Message) {
+	room.Selected = map[list.Serial]model.Message{msg.Serial(): msg}
+}
+
+// layoutSelectable lays out the row w of msg so that clicking it toggles
+// whether msg is selected in room. Selected rows are highlighted.
+func (ui *UI) layoutSelectable(gtx C, room *Room, msg model.Message, state *chatwidget.Row, w layout.Widget) D {
+	if state.Select.Clicked() {
+		if _, ok := room.Selected[msg.Serial()]; ok {
+			delete(room.Selected, msg.Serial())
+		} else {
+			room.Selected[msg.Serial()] = msg
+		}
+	}
+	_, selected := room.Selected[msg.Serial()]
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx C) D {
+			if selected {
+				paint.FillShape(gtx.Ops, component.WithAlpha(th.ContrastBg, 40), clip.Rect{Max: gtx.Constraints.Min}.Op())
+			}
+			return D{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(w),
+		layout.Expanded(func(gtx C) D {
+			return state.Select.Layout(gtx, func(gtx C) D {
+				return D{Size: gtx.Constraints.Min}
+			})
+		}),
+	)
+}
+
+// layoutSelection lays out the bar replacing the editor while selecting
+// messages, which copies the transcript of the selected messages.
+func (ui *UI) layoutSelection(gtx C, room *Room) D {
+	if ui.CopySelectedBtn.Clicked() && len(room.Selected) > 0 {
+		msgs := make([]model.Message, 0, len(room.Selected))
+		for _, m := range room.Selected {
+			msgs = append(msgs, m)
+		}
+		clipboard.WriteOp{Text: model.Transcript(msgs)}.Add(gtx.Ops)
+		room.Selected = nil
+	}
+	if ui.CancelSelectBtn.Clicked() {
+		room.Selected = nil
+	}
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(v.NewSeparator(component.WithAlpha(th.Fg, 50)).Layout),
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, material.Body1(th.Theme, fmt.Sprintf("%d selected", len(room.Selected))).Layout),
+					layout.Rigid(func(gtx C) D {
+						btn := material.Button(th.Theme, &ui.CancelSelectBtn, "Cancel")
+						btn.Background = th.Palette.Surface
+						btn.Color = th.Fg
+						return btn.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(func(gtx C) D {
+						btn := material.Button(th.Theme, &ui.CopySelectedBtn, "Copy")
+						if len(room.Selected) == 0 {
+							gtx = gtx.Disabled()
+						}
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		}),
+	)
+}
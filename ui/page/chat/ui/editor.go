@@ -1,12 +1,21 @@
 package ui
 
 import (
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"gioui.org/x/component"
+	"image"
+	"image/color"
+	"log"
+	"runtime"
 	"strings"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/prefs"
+	"wechat_ui/ui/pkg/emoji"
+	matchat "wechat_ui/ui/pkg/widget/material"
 	"wechat_ui/ui/v"
 )
 
@@ -23,33 +32,103 @@ var (
 	}
 )
 
+var (
+	// EditorMinHeight 是输入框的最低高度。
+	EditorMinHeight = unit.Dp(50)
+	// EditorMaxHeight 是输入框随内容增高的最大高度, 超过后输入框滚动。
+	EditorMaxHeight = unit.Dp(150)
+)
+
 // layoutEditor lays out the message editor.
 func (ui *UI) layoutEditor2(gtx C) D {
 	if ui.AddBtn.Clicked() {
-		active := ui.Rooms.Active()
-		text := strings.TrimSpace(active.Editor.Text())
-		if text != "" {
-			active.SendLocal(text)
-			active.Editor.SetText("")
-		}
+		ui.submit(ui.Rooms.Active())
+	}
+	if ui.FileBtn.Clicked() {
+		ui.showAttachDialog(gtx, false)
+	}
+	if ui.ScreenshotBtn.Clicked() {
+		ui.showAttachDialog(gtx, true)
+	}
+	if ui.VoiceBtn.Clicked() {
+		ui.startRecording(ui.Rooms.Active())
+	}
+	if ui.EmojiBtn.Clicked() {
+		ui.showEmojiPicker(gtx, ui.insertEmoji)
 	}
 	if ui.DeleteBtn.Clicked() {
 		serial := ui.ContextMenuTarget.Serial()
 		ui.Rooms.Active().DeleteRow(serial)
 	}
+	if ui.ReplyBtn.Clicked() && ui.ContextMenuTarget != nil {
+		active := ui.Rooms.Active()
+		active.ReplyTo = ui.ContextMenuTarget.Quote()
+		active.Editor.Focus()
+	}
+	if ui.ReactBtn.Clicked() && ui.ContextMenuTarget != nil {
+		active, serial := ui.Rooms.Active(), ui.ContextMenuTarget.Serial()
+		ui.showEmojiPicker(gtx, func(e emoji.Emoji) {
+			active.React(serial, e.Char, true)
+			ui.useEmoji(e)
+		})
+	}
+	if ui.EditBtn.Clicked() && ui.ContextMenuTarget != nil {
+		active, target := ui.Rooms.Active(), *ui.ContextMenuTarget
+		active.Editing = &target
+		active.ReplyTo = nil
+		active.Editor.SetText(target.Content)
+		active.Editor.SetCaret(active.Editor.Len(), active.Editor.Len())
+		active.Editor.Focus()
+	}
+	if ui.RecallBtn.Clicked() && ui.ContextMenuTarget != nil {
+		ui.Rooms.Active().Recall(ui.ContextMenuTarget.Serial())
+	}
+	if ui.CopyBtn.Clicked() && ui.ContextMenuTarget != nil {
+		ui.copyMessage(gtx, *ui.ContextMenuTarget)
+	}
+	if ui.SelectBtn.Clicked() && ui.ContextMenuTarget != nil {
+		startSelecting(ui.Rooms.Active(), *ui.ContextMenuTarget)
+	}
+	ui.pasteText(gtx)
+	if ui.SendEnterBtn.Clicked() {
+		ui.setSendKey(prefs.SendEnter)
+	}
+	if ui.SendShortEnterBtn.Clicked() {
+		ui.setSendKey(prefs.SendShortEnter)
+	}
+	if ui.CancelReplyBtn.Clicked() {
+		ui.Rooms.Active().ReplyTo = nil
+	}
+	if ui.CancelEditBtn.Clicked() {
+		ui.Rooms.Active().CancelEdit()
+	}
 	active := ui.Rooms.Active()
+	// 多选消息时以复制的操作栏替换输入框
+	if active.Selected != nil {
+		return ui.layoutSelection(gtx, active)
+	}
+	// 录制语音消息时以录音栏替换输入框
+	if ui.VoiceBar.room == active {
+		return ui.layoutVoiceBar(gtx)
+	}
 	editor := &active.Editor
 	for _, e := range editor.Events() {
 		switch e.(type) {
 		case widget.SubmitEvent:
-			text := strings.TrimSpace(editor.Text())
-			if text != "" {
-				active.SendLocal(text)
-				editor.SetText("")
+			// 正在输入 @ 时, 回车选择第一个成员
+			if names := ui.mentionSuggestions(active); len(names) > 0 {
+				ui.insertMention(active, names[0])
+				continue
 			}
+			ui.enter(active, false)
+		case widget.ChangeEvent:
+			ui.expandShortcode(editor)
+			ui.updateMention(active)
+			active.Typed(gtx.Now)
 		}
 	}
 	editor.Submit = true
+	mentions := ui.mentionSuggestions(active)
 
 	gtx.Constraints.Min.X = gtx.Constraints.Max.X
 
@@ -65,6 +144,9 @@ func (ui *UI) layoutEditor2(gtx C) D {
 							list := layout.List{Axis: layout.Horizontal, Alignment: layout.Start}
 							return list.Layout(gtx, len(leftIcons), func(gtx C, index int) D {
 								return layout.Inset{Left: unit.Dp(4), Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+									if btn := ui.toolBtn(leftIcons[index]); btn != nil {
+										return btn.Layout(gtx, leftIcons[index].Layout20dp)
+									}
 									return leftIcons[index].Layout20dp(gtx)
 								})
 							})
@@ -74,6 +156,9 @@ func (ui *UI) layoutEditor2(gtx C) D {
 							list := layout.List{Axis: layout.Horizontal, Alignment: layout.Start}
 							return list.Layout(gtx, len(rightIcons), func(gtx C, index int) D {
 								return layout.Inset{Left: unit.Dp(4), Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+									if btn := ui.toolBtn(rightIcons[index]); btn != nil {
+										return btn.Layout(gtx, rightIcons[index].Layout20dp)
+									}
 									return rightIcons[index].Layout20dp(gtx)
 								})
 							})
@@ -81,15 +166,52 @@ func (ui *UI) layoutEditor2(gtx C) D {
 				)
 			})
 		}),
+		// 待发送的附件
+		layout.Rigid(func(gtx C) D {
+			return ui.layoutStaged(gtx, active)
+		}),
+		// 引用或编辑的消息
+		layout.Rigid(func(gtx C) D {
+			switch {
+			case active.Editing != nil:
+				return ui.layoutBanner(gtx, "Edit message", active.Editing.Content, &ui.CancelEditBtn, "Cancel edit")
+			case active.ReplyTo != nil:
+				return ui.layoutBanner(gtx, active.ReplyTo.Sender, active.ReplyTo.Content, &ui.CancelReplyBtn, "Cancel reply")
+			}
+			return D{}
+		}),
+		// @ 成员候选
+		layout.Rigid(func(gtx C) D {
+			return ui.layoutMentions(gtx, active, mentions)
+		}),
 		// 输入框
 		layout.Rigid(func(gtx C) D {
-			// 限定最低宽度
-			height := gtx.Dp(unit.Dp(50))
-			gtx.Constraints.Max.Y = height
-			gtx.Constraints.Min.Y = height
-			// 限定输入框长度
-			gtx.Constraints.Min.X = gtx.Constraints.Max.X
-			return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16)}.Layout(gtx, material.Editor(th.Theme, editor, "Send a message").Layout)
+			if ui.EditorArea.Active() {
+				ui.EditorMenu.Options = ui.editorMenu()
+			}
+			return layout.Stack{}.Layout(gtx,
+				layout.Stacked(func(gtx C) D {
+					// 随内容增高, 超过最大高度后滚动
+					if max := gtx.Dp(EditorMaxHeight); max < gtx.Constraints.Max.Y {
+						gtx.Constraints.Max.Y = max
+					}
+					gtx.Constraints.Min.Y = gtx.Constraints.Constrain(image.Pt(0, gtx.Dp(EditorMinHeight))).Y
+					// 限定输入框长度
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					// 粘贴快捷键同时粘贴剪贴板中的图片
+					if gtx.Queue != nil {
+						gtx.Queue = pasteWatcher{Queue: gtx.Queue, ui: ui, room: active}
+					}
+					return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16)}.Layout(gtx, material.Editor(th.Theme, editor, "Send a message").Layout)
+				}),
+				// 右键菜单
+				layout.Expanded(func(gtx C) D {
+					return ui.EditorArea.Layout(gtx, func(gtx C) D {
+						gtx.Constraints.Min = image.Point{}
+						return component.Menu(th.Theme, &ui.EditorMenu).Layout(gtx)
+					})
+				}),
+			)
 		}),
 		// 发送按钮
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -97,10 +219,177 @@ func (ui *UI) layoutEditor2(gtx C) D {
 			return in.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 				// .E 放到最右边
 				return layout.E.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-					btn := material.Button(th.Theme, &ui.AddBtn, "Send(S)")
-					return btn.Layout(gtx)
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+						layout.Rigid(material.Button(th.Theme, &ui.AddBtn, "Send(S)").Layout),
+						// 选择发送消息的按键
+						layout.Rigid(ui.layoutSendKey),
+					)
 				})
 			})
 		}),
 	)
 }
+
+// toolBtn 返回工具栏图标的点击状态，没有对应操作的图标返回 nil。
+func (ui *UI) toolBtn(icon *v.Image) *widget.Clickable {
+	switch icon {
+	case v.Emoticon:
+		return &ui.EmojiBtn
+	case v.File:
+		return &ui.FileBtn
+	case v.Screenshot:
+		return &ui.ScreenshotBtn
+	case v.Circle:
+		return &ui.VoiceBtn
+	}
+	return nil
+}
+
+// submit 发送待发送的附件以及输入框中的内容。
+// 正在编辑消息时，以输入框中的内容替换该消息的内容。
+func (ui *UI) submit(room *Room) {
+	text := strings.TrimSpace(room.Editor.Text())
+	if room.Editing != nil {
+		if text != "" {
+			room.Edit(room.Editing.Serial(), text)
+			room.CancelEdit()
+		}
+		return
+	}
+	if text == "" && len(room.Staged) == 0 {
+		return
+	}
+	attachments := make([]model.Attachment, len(room.Staged))
+	for ii, s := range room.Staged {
+		attachments[ii] = s.Attachment
+	}
+	room.SendLocal(text, model.MentionsIn(text, room.Mentioning), room.ReplyTo, attachments...)
+	room.History.Add(text)
+	room.ReplyTo = nil
+	room.Staged = nil
+	room.Mentioning = nil
+	room.Editor.SetText("")
+	room.StopComposing()
+}
+
+// layoutBanner 在输入框上方显示正在回复或编辑的消息，以及取消的按钮。
+func (ui *UI) layoutBanner(gtx C, title, content string, cancel *widget.Clickable, description string) D {
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(8), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx C) D {
+				q := matchat.Quote(th.Theme, nil, title, content)
+				q.MaxWidth = 0
+				return q.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				btn := material.IconButton(th.Theme, cancel, Close, description)
+				btn.Background = color.NRGBA{}
+				btn.Color = th.Fg
+				btn.Size = unit.Dp(16)
+				btn.Inset = layout.UniformInset(unit.Dp(4))
+				return btn.Layout(gtx)
+			}),
+		)
+	})
+}
+
+// enter 处理输入框中的 Enter 键, short 时为 Short-Enter 键:
+// 设置中选择的发送键发送消息, 另一个键插入换行。
+func (ui *UI) enter(room *Room, short bool) {
+	if short == (ui.Settings.Get().SendKey == prefs.SendShortEnter) {
+		ui.submit(room)
+		return
+	}
+	room.Editor.Insert("\n")
+}
+
+// setSendKey 设置并保存发送消息的按键。
+func (ui *UI) setSendKey(k prefs.SendKey) {
+	values := ui.Settings.Get()
+	values.SendKey = k
+	ui.Settings.Set(values)
+	go func() {
+		if err := ui.Settings.Save(); err != nil {
+			log.Printf("saving settings: %v", err)
+		}
+	}()
+}
+
+// shortcutName 是 Short 修饰键在当前系统上的名称。
+var shortcutName = func() string {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return "Cmd"
+	}
+	return "Ctrl"
+}()
+
+// sendKeyMenu 返回选择发送键的菜单选项, 当前的选择带有勾选标记。
+func (ui *UI) sendKeyMenu() []func(gtx C) D {
+	current := ui.Settings.Get().SendKey
+	option := func(btn *widget.Clickable, label string, k prefs.SendKey) func(gtx C) D {
+		item := component.MenuItem(th.Theme, btn, label)
+		item.Icon = Check
+		item.IconSize = unit.Dp(18)
+		if k != current {
+			item.IconColor = color.NRGBA{}
+		}
+		return item.Layout
+	}
+	return []func(gtx C) D{
+		option(&ui.SendEnterBtn, "Press Enter to send", prefs.SendEnter),
+		option(&ui.SendShortEnterBtn, "Press "+shortcutName+"+Enter to send", prefs.SendShortEnter),
+	}
+}
+
+// layoutSendKey 在发送按钮旁布局下拉箭头, 点击后弹出选择发送键的菜单。
+func (ui *UI) layoutSendKey(gtx C) D {
+	if ui.SendKeyArea.Active() {
+		ui.SendKeyMenu.Options = ui.sendKeyMenu()
+	}
+	ui.SendKeyArea.Activation = pointer.ButtonPrimary
+	return layout.Stack{}.Layout(gtx,
+		layout.Stacked(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+				size := gtx.Dp(unit.Dp(20))
+				gtx.Constraints = layout.Exact(image.Pt(size, size))
+				return ArrowDropDown.Layout(gtx, th.Fg)
+			})
+		}),
+		layout.Expanded(func(gtx C) D {
+			return ui.SendKeyArea.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min = image.Point{}
+				return component.Menu(th.Theme, &ui.SendKeyMenu).Layout(gtx)
+			})
+		}),
+	)
+}
+
+// browseHistory 以房间历史中的上一条消息替换输入框的内容, back 为假时为下一条。
+// 只在输入框为空时开始浏览历史, 返回是否替换了内容。
+func (ui *UI) browseHistory(room *Room, back bool) bool {
+	editor := &room.Editor
+	if !editor.Focused() {
+		return false
+	}
+	var (
+		text string
+		ok   bool
+	)
+	switch {
+	case back && (editor.Len() == 0 || room.History.Recalling()):
+		text, ok = room.History.Prev(editor.Text())
+	case !back:
+		text, ok = room.History.Next()
+	}
+	if !ok {
+		return false
+	}
+	editor.SetText(text)
+	// 光标放在继续浏览的方向上, 使下一次按键不被输入框移动光标占用
+	if back {
+		editor.SetCaret(0, 0)
+	} else {
+		editor.SetCaret(editor.Len(), editor.Len())
+	}
+	return true
+}
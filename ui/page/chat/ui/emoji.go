@@ -0,0 +1,274 @@
+package ui
+
+import (
+	"log"
+	"wechat_ui/ui/pkg/emoji"
+	chatlayout "wechat_ui/ui/pkg/layout"
+
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+)
+
+var (
+	// EmojiPickerWidth and EmojiPickerHeight size the emoji picker.
+	EmojiPickerWidth  = unit.Dp(380)
+	EmojiPickerHeight = unit.Dp(340)
+	// EmojiSize is the text size emoji are presented at in the picker.
+	EmojiSize = unit.Sp(22)
+)
+
+// emojiColumns is the number of emoji on each row of the picker.
+const emojiColumns = 8
+
+// recentTab is the tab of the recently used emoji, which precedes the tabs
+// of the categories.
+const recentTab = 0
+
+// EmojiPicker holds the state of the modal picking emoji, to insert them in
+// the editor or react to messages.
+type EmojiPicker struct {
+	// Search is the editor filtering the emoji by shortcode.
+	Search widget.Editor
+	// Tabs track clicks on the tabs: the recently used emoji followed by
+	// the categories.
+	Tabs []widget.Clickable
+	// Grid presents the emoji of the selected tab or matching the search.
+	Grid widget.List
+	// Recent holds the recently used emoji of the local user.
+	Recent *emoji.Recent
+	// pick handles the emoji picked.
+	pick func(emoji.Emoji)
+	// tab is the selected tab.
+	tab int
+	// keys track clicks on each emoji.
+	keys map[string]*widget.Clickable
+	// card absorbs the clicks on the picker, which would otherwise
+	// dismiss the modal.
+	card widget.Clickable
+}
+
+// key returns the click state of e.
+func (p *EmojiPicker) key(e emoji.Emoji) *widget.Clickable {
+	if p.keys == nil {
+		p.keys = make(map[string]*widget.Clickable)
+	}
+	k, ok := p.keys[e.Char]
+	if !ok {
+		k = &widget.Clickable{}
+		p.keys[e.Char] = k
+	}
+	return k
+}
+
+// showEmojiPicker shows the emoji picker, opened on the recently used emoji
+// if there are any. The emoji picked is passed to pick.
+func (ui *UI) showEmojiPicker(gtx C, pick func(emoji.Emoji)) {
+	p := &ui.EmojiPicker
+	p.pick = pick
+	p.Tabs = make([]widget.Clickable, len(emoji.Categories())+1)
+	p.tab = recentTab
+	if len(p.Recent.List()) == 0 {
+		p.tab = recentTab + 1
+	}
+	p.Search.SingleLine = true
+	p.Search.Submit = true
+	p.Search.SetText("")
+	p.Search.Focus()
+	p.Grid.Position = layout.Position{}
+	ui.Modal.Show(gtx.Now, ui.layoutEmojiPicker)
+}
+
+// insertEmoji inserts e at the caret of the active room's editor and
+// remembers it as recently used.
+func (ui *UI) insertEmoji(e emoji.Emoji) {
+	editor := &ui.Rooms.Active().Editor
+	editor.Insert(e.Char)
+	editor.Focus()
+	ui.useEmoji(e)
+}
+
+// useEmoji remembers e as recently used.
+func (ui *UI) useEmoji(e emoji.Emoji) {
+	recent := ui.EmojiPicker.Recent
+	recent.Use(e.Char)
+	go func() {
+		if err := recent.Save(); err != nil {
+			log.Printf("emoji: %v", err)
+		}
+	}()
+}
+
+// expandShortcode replaces the shortcode, such as ":smile:", just typed
+// before the caret of editor by the emoji it names.
+func (ui *UI) expandShortcode(editor *widget.Editor) {
+	caret, end := editor.Selection()
+	if caret != end {
+		return
+	}
+	start, e, ok := emoji.ShortcodeBefore(editor.Text(), caret)
+	if !ok {
+		return
+	}
+	editor.SetCaret(caret, start)
+	editor.Insert(e.Char)
+	ui.useEmoji(e)
+}
+
+// layoutEmojiPicker lays out the emoji picker.
+func (ui *UI) layoutEmojiPicker(gtx C) D {
+	p := &ui.EmojiPicker
+	var (
+		categories = emoji.Categories()
+		query      = p.Search.Text()
+		title      string
+		list       []emoji.Emoji
+	)
+	for ii := range p.Tabs {
+		if p.Tabs[ii].Clicked() {
+			p.tab = ii
+			p.Grid.Position = layout.Position{}
+			p.Search.SetText("")
+			query = ""
+		}
+	}
+	switch {
+	case query != "":
+		title, list = "搜索结果", emoji.Search(query)
+	case p.tab == recentTab:
+		title, list = "最近使用", p.Recent.List()
+	default:
+		c := categories[p.tab-1]
+		title, list = c.Name, c.Emoji
+	}
+	for _, e := range p.Search.Events() {
+		if _, ok := e.(widget.SubmitEvent); ok && query != "" && len(list) > 0 {
+			p.pick(list[0])
+			ui.Modal.Disappear(gtx.Now)
+		}
+	}
+	for _, e := range list {
+		if p.key(e).Clicked() {
+			p.pick(e)
+			ui.Modal.Disappear(gtx.Now)
+		}
+	}
+	return layout.Center.Layout(gtx, func(gtx C) D {
+		width, height := gtx.Dp(EmojiPickerWidth), gtx.Dp(EmojiPickerHeight)
+		if width > gtx.Constraints.Max.X {
+			width = gtx.Constraints.Max.X
+		}
+		if height > gtx.Constraints.Max.Y {
+			height = gtx.Constraints.Max.Y
+		}
+		gtx.Constraints.Min.X, gtx.Constraints.Max.X = width, width
+		gtx.Constraints.Min.Y, gtx.Constraints.Max.Y = height, height
+		return p.card.Layout(gtx, func(gtx C) D {
+			return chatlayout.Rounded(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+				return chatlayout.Background(th.Palette.Surface).Layout(gtx, func(gtx C) D {
+					return layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx C) D {
+						return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+							// 搜索框
+							layout.Rigid(func(gtx C) D {
+								return component.Surface(th.Theme).Layout(gtx, func(gtx C) D {
+									return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Editor(th.Theme, &p.Search, "Search emoji").Layout)
+								})
+							}),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+							// 分类
+							layout.Rigid(func(gtx C) D {
+								return ui.layoutEmojiTabs(gtx, query != "")
+							}),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+							layout.Rigid(func(gtx C) D {
+								lbl := material.Body2(th.Theme, title)
+								lbl.Color = component.WithAlpha(th.Fg, 150)
+								return lbl.Layout(gtx)
+							}),
+							layout.Rigid(layout.Spacer{Height: unit.Dp(4)}.Layout),
+							// 表情
+							layout.Flexed(1, func(gtx C) D {
+								return ui.layoutEmojiGrid(gtx, list)
+							}),
+						)
+					})
+				})
+			})
+		})
+	})
+}
+
+// layoutEmojiTabs lays out the tabs of the picker, each presented by an
+// emoji. No tab is highlighted while searching.
+func (ui *UI) layoutEmojiTabs(gtx C, searching bool) D {
+	p := &ui.EmojiPicker
+	categories := emoji.Categories()
+	children := make([]layout.FlexChild, len(p.Tabs))
+	for ii := range p.Tabs {
+		ii := ii
+		char := "🕘"
+		if ii != recentTab {
+			char = categories[ii-1].Emoji[0].Char
+		}
+		children[ii] = layout.Flexed(1, func(gtx C) D {
+			return p.Tabs[ii].Layout(gtx, func(gtx C) D {
+				bg := th.Palette.Surface
+				if !searching && ii == p.tab {
+					bg = component.WithAlpha(th.Fg, 30)
+				}
+				return chatlayout.Rounded(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+					return chatlayout.Background(bg).Layout(gtx, func(gtx C) D {
+						return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+							return layoutEmoji(gtx, char, unit.Sp(18))
+						})
+					})
+				})
+			})
+		})
+	}
+	return layout.Flex{}.Layout(gtx, children...)
+}
+
+// layoutEmojiGrid lays out the emoji of list in rows of emojiColumns.
+func (ui *UI) layoutEmojiGrid(gtx C, list []emoji.Emoji) D {
+	p := &ui.EmojiPicker
+	if len(list) == 0 {
+		lbl := material.Body2(th.Theme, "No emoji")
+		lbl.Color = component.WithAlpha(th.Fg, 150)
+		return layout.Center.Layout(gtx, lbl.Layout)
+	}
+	rows := (len(list) + emojiColumns - 1) / emojiColumns
+	p.Grid.Axis = layout.Vertical
+	return material.List(th.Theme, &p.Grid).Layout(gtx, rows, func(gtx C, row int) D {
+		children := make([]layout.FlexChild, emojiColumns)
+		for col := range children {
+			ii := row*emojiColumns + col
+			if ii >= len(list) {
+				children[col] = layout.Flexed(1, func(gtx C) D { return D{} })
+				continue
+			}
+			e := list[ii]
+			children[col] = layout.Flexed(1, func(gtx C) D {
+				return material.Clickable(gtx, p.key(e), func(gtx C) D {
+					return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+						return layoutEmoji(gtx, e.Char, EmojiSize)
+					})
+				})
+			})
+		}
+		return layout.Flex{}.Layout(gtx, children...)
+	})
+}
+
+// layoutEmoji lays out char centered, shaped with the emoji typeface.
+func layoutEmoji(gtx C, char string, size unit.Sp) D {
+	lbl := material.Label(th.Theme, size, char)
+	lbl.Font.Typeface = emoji.Typeface
+	lbl.Alignment = text.Middle
+	lbl.MaxLines = 1
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return lbl.Layout(gtx)
+}
@@ -0,0 +1,449 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"wechat_ui/ui/page/chat/model"
+	chatlayout "wechat_ui/ui/pkg/layout"
+	chatwidget "wechat_ui/ui/pkg/widget"
+	matchat "wechat_ui/ui/pkg/widget/material"
+
+	"gioui.org/layout"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+)
+
+var (
+	// GroupPanelWidth is the width of the group panel.
+	GroupPanelWidth = unit.Dp(300)
+	// MemberAvatarSize is the size of the avatars of the member grid.
+	MemberAvatarSize = unit.Dp(44)
+)
+
+// memberColumns is the number of members on each row of the grid.
+const memberColumns = 4
+
+// GroupPanel holds the state of the side panel presenting the members and
+// settings of the active room, and letting its managers change them.
+type GroupPanel struct {
+	// Visible reports whether the panel is shown.
+	Visible bool
+	// Close tracks clicks on the button hiding the panel.
+	Close widget.Clickable
+	// List scrolls the sections of the panel.
+	List widget.List
+	// Title and Announcement edit the name and announcement of the room.
+	Title, Announcement widget.Editor
+	// SaveTitle and SaveAnnouncement track clicks on the buttons
+	// submitting the editors.
+	SaveTitle, SaveAnnouncement widget.Clickable
+	// Add tracks clicks on the cell toggling between the members and the
+	// users that can be added.
+	Add widget.Clickable
+	// Leave tracks clicks on the button leaving the room.
+	Leave widget.Clickable
+	// room is the room presented.
+	room *Room
+	// title and announcement are the values the editors were last filled
+	// in with.
+	title, announcement string
+	// adding reports whether the users that can be added to the room are
+	// presented in place of its members.
+	adding bool
+	// cells hold the state of the grid cells by user name.
+	cells map[string]*memberCell
+}
+
+// memberCell is the state of a user in the grid.
+type memberCell struct {
+	// Clickable tracks clicks on the user, adding it to the room.
+	widget.Clickable
+	// Remove tracks clicks on the button removing the member.
+	Remove widget.Clickable
+	// Role tracks clicks on the button promoting the member to admin, or
+	// demoting the admin.
+	Role   widget.Clickable
+	avatar chatwidget.CachedImage
+	src    image.Image
+}
+
+// cell returns the state of the named user in the grid.
+func (p *GroupPanel) cell(name string) *memberCell {
+	if p.cells == nil {
+		p.cells = make(map[string]*memberCell)
+	}
+	c, ok := p.cells[name]
+	if !ok {
+		c = &memberCell{}
+		p.cells[name] = c
+	}
+	return c
+}
+
+// sync fills in the editors with the settings of room, when another room
+// is presented or the settings changed.
+func (p *GroupPanel) sync(room *Room, g model.Group) {
+	if p.room != room {
+		p.room = room
+		p.adding = false
+		p.title, p.announcement = "", ""
+		p.Title.SetText("")
+		p.Announcement.SetText("")
+		p.List.Position = layout.Position{}
+	}
+	if name := room.DisplayName(); name != p.title {
+		p.title = name
+		p.Title.SetText(name)
+	}
+	if g.Announcement != p.announcement {
+		p.announcement = g.Announcement
+		p.Announcement.SetText(g.Announcement)
+	}
+}
+
+// candidates returns the users that can be added to g.
+func (ui *UI) candidates(g model.Group) []*model.User {
+	var users []*model.User
+	for _, u := range ui.Users.List() {
+		if _, ok := g.Member(u.Name); !ok && u.Name != ui.Local.Name {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// layoutGroupPanel lays out the group panel of the active room.
+func (ui *UI) layoutGroupPanel(gtx C) D {
+	p := &ui.GroupPanel
+	room := ui.Rooms.Active()
+	if room.Room == nil {
+		return D{}
+	}
+	var (
+		g      = room.Group()
+		local  = ui.Local.Name
+		manage = g.CanManage(local)
+		_, in  = g.Member(local)
+	)
+	p.sync(room, g)
+	p.Title.SingleLine = true
+	p.Title.Submit = true
+	if p.Close.Clicked() {
+		p.Visible = false
+	}
+	if p.Add.Clicked() {
+		p.adding = !p.adding
+	}
+	rename := p.SaveTitle.Clicked()
+	for _, e := range p.Title.Events() {
+		if _, ok := e.(widget.SubmitEvent); ok {
+			rename = true
+		}
+	}
+	if title := strings.TrimSpace(p.Title.Text()); rename && title != "" && title != p.title {
+		room.ChangeGroup(model.GroupChange{Kind: model.ChangeRename, Text: title})
+	}
+	if p.SaveAnnouncement.Clicked() {
+		if text := strings.TrimSpace(p.Announcement.Text()); text != p.announcement {
+			room.ChangeGroup(model.GroupChange{Kind: model.ChangeAnnounce, Text: text})
+		}
+	}
+	if p.Leave.Clicked() && in {
+		room.ChangeGroup(model.GroupChange{Kind: model.ChangeLeave})
+		p.Visible = false
+	}
+	var cells []string
+	if p.adding {
+		for _, u := range ui.candidates(g) {
+			if p.cell(u.Name).Clicked() {
+				room.ChangeGroup(model.GroupChange{Kind: model.ChangeAdd, Users: []string{u.Name}})
+			}
+			cells = append(cells, u.Name)
+		}
+	} else {
+		for _, m := range g.Members {
+			c := p.cell(m.Name)
+			if c.Remove.Clicked() && g.CanRemove(local, m.Name) {
+				room.ChangeGroup(model.GroupChange{Kind: model.ChangeRemove, Users: []string{m.Name}})
+			}
+			if c.Role.Clicked() && g.CanSetRole(local, m.Name) {
+				role := model.RoleAdmin
+				if m.Role == model.RoleAdmin {
+					role = model.RoleMember
+				}
+				room.ChangeGroup(model.GroupChange{Kind: model.ChangeRole, Users: []string{m.Name}, Role: role})
+			}
+			cells = append(cells, m.Name)
+		}
+	}
+	sections := []layout.Widget{
+		func(gtx C) D {
+			return ui.layoutGroupHeader(gtx, g)
+		},
+		func(gtx C) D {
+			return ui.layoutMemberGrid(gtx, g, cells, manage)
+		},
+		func(gtx C) D {
+			return ui.layoutGroupSetting(gtx, "Group name", &p.Title, &p.SaveTitle, p.title, manage)
+		},
+		func(gtx C) D {
+			return ui.layoutGroupSetting(gtx, "Announcement", &p.Announcement, &p.SaveAnnouncement, p.announcement, manage)
+		},
+	}
+	if in {
+		sections = append(sections, func(gtx C) D {
+			btn := material.Button(th.Theme, &p.Leave, "Leave group")
+			btn.Background = matchat.DefaultDangerColor
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.Inset{Top: unit.Dp(16)}.Layout(gtx, btn.Layout)
+		})
+	}
+	gtx.Constraints.Min.X = gtx.Dp(GroupPanelWidth)
+	gtx.Constraints.Max.X = gtx.Constraints.Min.X
+	gtx.Constraints.Min.Y = gtx.Constraints.Max.Y
+	return chatlayout.Background(th.Palette.Surface).Layout(gtx, func(gtx C) D {
+		p.List.Axis = layout.Vertical
+		return material.List(th.Theme, &p.List).Layout(gtx, len(sections), func(gtx C, ii int) D {
+			return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Top: unit.Dp(8), Bottom: unit.Dp(8)}.Layout(gtx, sections[ii])
+		})
+	})
+}
+
+// layoutGroupHeader lays out the title of the panel and the button hiding
+// it.
+func (ui *UI) layoutGroupHeader(gtx C, g model.Group) D {
+	p := &ui.GroupPanel
+	title := "Group info"
+	if g.Open() {
+		title = "Chat info"
+	}
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(material.H6(th.Theme, title).Layout),
+				layout.Rigid(func(gtx C) D {
+					caption := "Open to everyone"
+					if !g.Open() {
+						caption = plural(len(g.Members), "member")
+					}
+					lbl := material.Caption(th.Theme, caption)
+					lbl.Color = component.WithAlpha(th.Fg, 150)
+					return lbl.Layout(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			btn := material.IconButton(th.Theme, &p.Close, Close, "Close")
+			btn.Background = color.NRGBA{}
+			btn.Color = th.Fg
+			btn.Size = unit.Dp(18)
+			btn.Inset = layout.UniformInset(unit.Dp(4))
+			return btn.Layout(gtx)
+		}),
+	)
+}
+
+// layoutMemberGrid lays out the named users in rows of memberColumns,
+// followed by the cell toggling between the members and the users that can
+// be added if manage is set.
+func (ui *UI) layoutMemberGrid(gtx C, g model.Group, names []string, manage bool) D {
+	p := &ui.GroupPanel
+	cells := make([]layout.Widget, 0, len(names)+1)
+	for _, name := range names {
+		name := name
+		cells = append(cells, func(gtx C) D {
+			return ui.layoutMember(gtx, g, name)
+		})
+	}
+	if manage {
+		cells = append(cells, func(gtx C) D {
+			label := "Add"
+			if p.adding {
+				label = "Done"
+			}
+			return material.Clickable(gtx, &p.Add, func(gtx C) D {
+				return layoutMemberCell(gtx, func(gtx C) D {
+					return chatlayout.Rounded(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+						return chatlayout.Background(component.WithAlpha(th.Fg, 20)).Layout(gtx, func(gtx C) D {
+							size := gtx.Dp(MemberAvatarSize)
+							gtx.Constraints = layout.Exact(image.Pt(size, size))
+							return layout.Center.Layout(gtx, func(gtx C) D {
+								icon := ContentAdd
+								if p.adding {
+									icon = Close
+								}
+								gtx.Constraints.Min.X = gtx.Dp(unit.Dp(20))
+								return icon.Layout(gtx, component.WithAlpha(th.Fg, 150))
+							})
+						})
+					})
+				}, label, "")
+			})
+		})
+	}
+	var rows []layout.FlexChild
+	if p.adding && len(names) == 0 {
+		lbl := material.Body2(th.Theme, "Everyone is a member")
+		lbl.Color = component.WithAlpha(th.Fg, 150)
+		rows = append(rows, layout.Rigid(lbl.Layout))
+	}
+	for start := 0; start < len(cells); start += memberColumns {
+		children := make([]layout.FlexChild, memberColumns)
+		for col := range children {
+			if start+col >= len(cells) {
+				children[col] = layout.Flexed(1, func(gtx C) D { return D{} })
+				continue
+			}
+			children[col] = layout.Flexed(1, cells[start+col])
+		}
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx, children...)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+}
+
+// layoutMember lays out the avatar, name and role of the named user. The
+// members that the local user can remove have a button doing so, those
+// whose role it can change a button promoting or demoting them, and the
+// users that are not members can be clicked to add them.
+func (ui *UI) layoutMember(gtx C, g model.Group, name string) D {
+	c := ui.GroupPanel.cell(name)
+	var avatar image.Image = avatarPlaceholder
+	if u, ok := ui.Users.Lookup(name); ok && u.Avatar != "" {
+		if img := loadImage("member-"+name, u.Avatar, &ui.Loader); img != nil {
+			avatar = img
+		}
+	}
+	if avatar != c.src {
+		c.src = avatar
+		c.avatar.Reload()
+	}
+	c.avatar.Cache(avatar)
+	var role string
+	m, member := g.Member(name)
+	switch m.Role {
+	case model.RoleOwner:
+		role = "Owner"
+	case model.RoleAdmin:
+		role = "Admin"
+	}
+	img := func(gtx C) D {
+		return matchat.Image{
+			Image: widget.Image{
+				Src:      c.avatar.Op(),
+				Fit:      widget.Cover,
+				Position: layout.Center,
+			},
+			Radii:  unit.Dp(4),
+			Width:  MemberAvatarSize,
+			Height: MemberAvatarSize,
+		}.Layout(gtx)
+	}
+	if !member {
+		return material.Clickable(gtx, &c.Clickable, func(gtx C) D {
+			return layoutMemberCell(gtx, img, name, role)
+		})
+	}
+	if g.CanRemove(ui.Local.Name, name) {
+		avatar := img
+		img = func(gtx C) D {
+			btn := material.IconButton(th.Theme, &c.Remove, Close, "Remove "+name)
+			btn.Background = matchat.DefaultDangerColor
+			btn.Size = unit.Dp(10)
+			btn.Inset = layout.UniformInset(unit.Dp(2))
+			return layout.Stack{Alignment: layout.NE}.Layout(gtx,
+				layout.Stacked(avatar),
+				layout.Stacked(btn.Layout),
+			)
+		}
+	}
+	if !g.CanSetRole(ui.Local.Name, name) {
+		return layoutMemberCell(gtx, img, name, role)
+	}
+	action := "Make admin"
+	if m.Role == model.RoleAdmin {
+		action = "Revoke admin"
+	}
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layoutMemberCell(gtx, img, name, role)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return material.Clickable(gtx, &c.Role, func(gtx C) D {
+				lbl := material.Caption(th.Theme, action)
+				lbl.Color = component.WithAlpha(th.Fg, 150)
+				lbl.MaxLines = 1
+				return lbl.Layout(gtx)
+			})
+		}),
+	)
+}
+
+// layoutMemberCell lays out a cell of the member grid: an image above a
+// name, and a role if it is not empty.
+func layoutMemberCell(gtx C, img layout.Widget, name, role string) D {
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(img),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(4)}.Layout),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Caption(th.Theme, name)
+				lbl.MaxLines = 1
+				lbl.Alignment = text.Middle
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if role == "" {
+					return D{}
+				}
+				lbl := material.Caption(th.Theme, role)
+				lbl.Color = th.ContrastBg
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+}
+
+// layoutGroupSetting lays out a setting of the room: an editor and a
+// button saving it if manage is set, or else its current value.
+func (ui *UI) layoutGroupSetting(gtx C, title string, editor *widget.Editor, save *widget.Clickable, value string, manage bool) D {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			lbl := material.Body2(th.Theme, title)
+			lbl.Color = component.WithAlpha(th.Fg, 150)
+			return lbl.Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(4)}.Layout),
+		layout.Rigid(func(gtx C) D {
+			if !manage {
+				if value == "" {
+					value = "None"
+				}
+				return material.Body1(th.Theme, value).Layout(gtx)
+			}
+			return layout.Flex{Alignment: layout.End}.Layout(gtx,
+				layout.Flexed(1, func(gtx C) D {
+					return component.Surface(th.Theme).Layout(gtx, func(gtx C) D {
+						return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Editor(th.Theme, editor, title).Layout)
+					})
+				}),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+				layout.Rigid(material.Button(th.Theme, save, "Save").Layout),
+			)
+		}),
+	)
+}
+
+// plural formats count followed by noun, pluralized unless count is one.
+func plural(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(count) + " " + noun + "s"
+}
@@ -19,3 +19,23 @@ var ContentAdd = func() *widget.Icon {
 	icon, _ := widget.NewIcon(icons.ContentAddBox)
 	return icon
 }()
+
+var Close = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationClose)
+	return icon
+}()
+
+var MoreHoriz = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationMoreHoriz)
+	return icon
+}()
+
+var ArrowDropDown = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationArrowDropDown)
+	return icon
+}()
+
+var Check = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.NavigationCheck)
+	return icon
+}()
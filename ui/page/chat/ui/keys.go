@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"time"
+
+	"gioui.org/io/key"
+)
+
+// Keys are the shortcuts handled by HandleKeyPress:
+//
+//   - Short-F focuses the search editor.
+//   - Short-Shift-V stages the image on the clipboard as an attachment.
+//     Short-V is handled by the editor, which pastes text, and watched
+//     for by pasteWatcher, which stages the image if there is one.
+//   - Short-Enter sends the message being composed, or inserts a line
+//     break, depending on the send key chosen in the settings. Enter does
+//     the other, and is handled by the editor.
+//   - ↑ and ↓ recall the messages sent from the editor, starting at an
+//     empty editor.
+//   - Esc dismisses the modal, hides the users suggested for a mention,
+//     clears the search, or stops recording, selecting, editing or
+//     replying.
+//   - Alt-↑ and Alt-↓ switch to the previous and next room.
+const Keys key.Set = "Short-F|Short-Shift-V|Short-[" + key.NameReturn + "," + key.NameEnter + "]|" + key.NameEscape + "|(Alt)-[" + key.NameUpArrow + "," + key.NameDownArrow + "]"
+
+// HandleKeyPress performs the shortcut bound to evt, if any.
+func (ui *UI) HandleKeyPress(evt *key.Event) {
+	switch evt.Name {
+	case "F":
+		if evt.Modifiers.Contain(key.ModShortcut) {
+			ui.InsideRoom = false
+			ui.SearchEditor.Focus()
+			ui.SearchEditor.SetCaret(ui.SearchEditor.Len(), 0)
+		}
+	case key.NameReturn, key.NameEnter:
+		if active := ui.Rooms.Active(); evt.Modifiers.Contain(key.ModShortcut) && active.Editor.Focused() {
+			ui.enter(active, true)
+		}
+	case "V":
+		if evt.Modifiers.Contain(key.ModShortcut | key.ModShift) {
+			ui.pasteImage(ui.Rooms.Active())
+		}
+	case key.NameEscape:
+		if ui.Modal.Visible() {
+			ui.Modal.Disappear(time.Now())
+		} else if ui.MentionList.room != nil {
+			ui.MentionList.room = nil
+		} else if ui.SearchEditor.Len() > 0 {
+			ui.SearchEditor.SetText("")
+		} else if active := ui.Rooms.Active(); ui.VoiceBar.room == active {
+			ui.stopRecording(false)
+		} else if active.Selected != nil {
+			active.Selected = nil
+		} else if active.Editing != nil {
+			active.CancelEdit()
+		} else if active.ReplyTo != nil {
+			active.ReplyTo = nil
+		}
+	case key.NameUpArrow, key.NameDownArrow:
+		up := evt.Name == key.NameUpArrow
+		switch {
+		case evt.Modifiers.Contain(key.ModAlt):
+			step := 1
+			if up {
+				step = -1
+			}
+			ui.Rooms.Step(step)
+			ui.InsideRoom = true
+		case evt.Modifiers == 0:
+			ui.browseHistory(ui.Rooms.Active(), up)
+		}
+	}
+}
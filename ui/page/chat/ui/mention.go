@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"wechat_ui/ui/page/chat/model"
+	chatlayout "wechat_ui/ui/pkg/layout"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+)
+
+// MaxMentionSuggestions limits the users suggested for a mention.
+const MaxMentionSuggestions = 6
+
+// MentionList holds the state of the users suggested above the editor
+// while a mention, "@" followed by part of a name, is typed. It is only
+// accessed while laying out.
+type MentionList struct {
+	// room is the room whose editor the mention is typed in, nil if none
+	// is.
+	room *Room
+	// start is the rune offset of the "@" of the mention, and query the
+	// part of the name typed after it.
+	start int
+	query string
+	// picks track clicks on each suggested user.
+	picks map[string]*widget.Clickable
+}
+
+// pick returns the click state of the named user.
+func (m *MentionList) pick(name string) *widget.Clickable {
+	if m.picks == nil {
+		m.picks = make(map[string]*widget.Clickable)
+	}
+	p, ok := m.picks[name]
+	if !ok {
+		p = &widget.Clickable{}
+		m.picks[name] = p
+	}
+	return p
+}
+
+// updateMention shows the suggestions when a mention is typed before the
+// caret of the room's editor, and hides them otherwise.
+func (ui *UI) updateMention(room *Room) {
+	m := &ui.MentionList
+	m.room = nil
+	caret, end := room.Editor.Selection()
+	if caret != end {
+		return
+	}
+	start, query, ok := model.MentionBefore(room.Editor.Text(), caret)
+	if !ok {
+		return
+	}
+	m.room, m.start, m.query = room, start, query
+}
+
+// mentionSuggestions returns the users matching the mention typed in the
+// room's editor, if any: the members of the room, or every user of open
+// rooms, but the local user.
+func (ui *UI) mentionSuggestions(room *Room) []string {
+	m := &ui.MentionList
+	if m.room != room || room.Room == nil {
+		return nil
+	}
+	g := room.Group()
+	var names []string
+	for _, u := range ui.Users.List() {
+		if u.Name != ui.Local.Name && g.Visible(u.Name) {
+			names = append(names, u.Name)
+		}
+	}
+	names = model.MatchNames(names, m.query)
+	if len(names) > MaxMentionSuggestions {
+		names = names[:MaxMentionSuggestions]
+	}
+	return names
+}
+
+// insertMention replaces the mention typed in the room's editor by the
+// complete mention of the named user, and remembers to notify them when
+// the message is sent.
+func (ui *UI) insertMention(room *Room, name string) {
+	m := &ui.MentionList
+	caret, _ := room.Editor.Selection()
+	room.Editor.SetCaret(caret, m.start)
+	room.Editor.Insert("@" + name + " ")
+	room.Editor.Focus()
+	room.Mentioning = append(room.Mentioning, name)
+	m.room = nil
+}
+
+// layoutMentions lays out the users suggested for the mention typed in the
+// room's editor, if any. The first one is highlighted, as submitting the
+// editor picks it.
+func (ui *UI) layoutMentions(gtx C, room *Room, names []string) D {
+	m := &ui.MentionList
+	for _, name := range names {
+		if m.pick(name).Clicked() {
+			ui.insertMention(room, name)
+			return D{}
+		}
+	}
+	if len(names) == 0 {
+		return D{}
+	}
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+		return chatlayout.Rounded(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+			return chatlayout.Background(th.Palette.Surface).Layout(gtx, func(gtx C) D {
+				children := make([]layout.FlexChild, len(names))
+				for ii, name := range names {
+					ii, name := ii, name
+					children[ii] = layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Constraints.Max.X
+						return material.Clickable(gtx, m.pick(name), func(gtx C) D {
+							bg := th.Palette.Surface
+							if ii == 0 {
+								bg = component.WithAlpha(th.Fg, 20)
+							}
+							return chatlayout.Background(bg).Layout(gtx, func(gtx C) D {
+								return layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6), Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+									gtx.Constraints.Min.X = gtx.Constraints.Max.X
+									return material.Body2(th.Theme, "@"+name).Layout(gtx)
+								})
+							})
+						})
+					})
+				}
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+			})
+		})
+	})
+}
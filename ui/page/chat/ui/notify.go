@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"log"
+	"time"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/notify"
+)
+
+// notifiable reports whether msg, received by the local user, may be
+// notified: it was sent by another user and is unread.
+func notifiable(msg model.Message, local string) bool {
+	return !msg.Read && !msg.System && msg.Sender != local
+}
+
+// notify notifies msg, received in room, as a toast of the window and
+// through the Notifier. Messages are not notified in the active room while
+// the chat is shown, in muted rooms unless they mention the local user,
+// during the do not disturb schedule, nor at all if the settings turn
+// notifications off. It is called by listen, and does not wait for the
+// window to be laid out.
+func (ui *UI) notify(room *Room, msg model.Message) {
+	if ui.shown.Load() && room == ui.Rooms.Active() {
+		return
+	}
+	if ui.RoomPrefs.Get(room.Name).Muted && !msg.Mentioned(ui.Local.Name) {
+		return
+	}
+	settings := ui.Settings.Get()
+	if settings.NotificationsOff || settings.DoNotDisturb.Active(time.Now()) {
+		return
+	}
+	title := msg.Sender
+	if name := room.DisplayName(); name != msg.Sender {
+		title += " · " + name
+	}
+	body := msg.Preview()
+	if settings.HidePreview {
+		body = "New message"
+	}
+	n := notify.Notification{Title: title, Body: body, Tag: room.Name}
+	if ui.Toasts != nil {
+		ui.Toasts.Push(n)
+	}
+	go func() {
+		if err := ui.Notifier.Notify(n); err != nil {
+			log.Printf("notifying message: %v", err)
+		}
+	}()
+}
@@ -1,20 +1,36 @@
 package ui
 
 import (
-	"math/rand"
+	"log"
+	"sort"
 	"sync"
+	"time"
 	"wechat_ui/ui/page/chat/appwidget"
+	"wechat_ui/ui/page/chat/backend"
 	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/search"
 	"wechat_ui/ui/pkg/list"
 
+	"gioui.org/layout"
 	"gioui.org/widget"
 )
 
-// Rooms contains a selectable list of rooms.
+const (
+	// ComposingRefresh is how often the signal that the local user is
+	// composing is renewed while it keeps typing. It is shorter than
+	// model.ComposingTimeout, so that the signal does not expire.
+	ComposingRefresh = 3 * time.Second
+	// ComposingIdle is how long after the local user stops typing the
+	// signal that it is composing is withdrawn.
+	ComposingIdle = 5 * time.Second
+)
+
+// Rooms contains a selectable list of rooms, in the order they are
+// presented.
 type Rooms struct {
-	active  int
+	active  *Room
 	changed bool
-	List    []Room
+	List    []*Room
 	sync.Mutex
 }
 
@@ -27,11 +43,13 @@ type Room struct {
 	*model.Room
 	// Interact defines the interactive state for a room widget.
 	Interact appwidget.Room
-	// Messages implements what would be a backend data model.
-	// This would be the facade to your business api.
+	// Backend is the facade to the business api.
 	// This is the source of truth.
-	// This type gets asked to create messages and queried for message history.
-	Messages *RowTracker
+	// It gets asked to create messages and queried for message history.
+	Backend backend.Backend
+	// Index is the search index kept up to date with the messages of the
+	// room.
+	Index *search.Index
 	// ListState dynamically manages list state.
 	// This lets us surf across a vast ocean of infinite messages, only ever
 	// rendering what is actualy viewable.
@@ -42,60 +60,508 @@ type Room struct {
 	List widget.List
 	// Editor contains the edit buffer for composing messages.
 	Editor widget.Editor
+	// ReplyTo quotes the message being replied to with the contents of the
+	// editor, if any. It is only accessed while laying out.
+	ReplyTo *model.Quote
+	// Editing is the message whose content is being edited in the editor,
+	// if any. It is only accessed while laying out.
+	Editing *model.Message
+	// Staged holds the attachments to send with the contents of the
+	// editor. It is only accessed while laying out.
+	Staged []*Staged
+	// StagedList lays out the staged attachments.
+	StagedList layout.List
+	// History recalls the messages sent from the editor. It is only
+	// accessed while laying out.
+	History model.History
+	// Mentioning holds the names of the users picked to mention while
+	// composing. It is only accessed while laying out.
+	Mentioning []string
+	// Selected holds the messages picked to copy while selecting them,
+	// and is nil otherwise. It is only accessed while laying out.
+	Selected map[list.Serial]model.Message
+	// Pinned rooms are listed ahead of the others, and Muted ones present
+	// their unread messages without a count. They are only accessed while
+	// laying out.
+	Pinned, Muted bool
+	// statuses tracks the delivery status of the messages sent from this
+	// client, so that stale updates can be discarded. Entries outlive
+	// the read status, which is terminal.
+	statuses map[list.Serial]model.Status
+	// read is the serial of the latest message marked read.
+	read list.Serial
+	// composing reports whether the local user was last signalled to be
+	// composing in the room, at signalledAt. typedAt is when the editor
+	// last changed. They are only accessed while laying out.
+	composing            bool
+	signalledAt, typedAt time.Time
+	// signal is the composing state waiting to be sent to the backend if
+	// signalPending is set, and signalling reports whether a goroutine is
+	// sending it. They are guarded by the mutex.
+	signal, signalPending, signalling bool
 	sync.Mutex
 }
 
+// Typed signals the other participants that the local user is composing a
+// message, after a change of the editor. The signal is debounced: it is
+// sent when the user starts typing, and renewed every ComposingRefresh as
+// it keeps typing. Emptying the editor withdraws it. It must be called from
+// layout code.
+func (r *Room) Typed(now time.Time) {
+	if r.Editor.Len() == 0 {
+		r.StopComposing()
+		return
+	}
+	r.typedAt = now
+	if r.composing && now.Sub(r.signalledAt) < ComposingRefresh {
+		return
+	}
+	r.composing, r.signalledAt = true, now
+	r.signalComposing(true)
+}
+
+// StopComposing withdraws the signal that the local user is composing a
+// message, if it was sent. It must be called from layout code.
+func (r *Room) StopComposing() {
+	if !r.composing {
+		return
+	}
+	r.composing = false
+	r.signalComposing(false)
+}
+
+// idleComposing withdraws the signal that the local user is composing a
+// message once it stopped typing for ComposingIdle. It returns when to
+// check again, zero if the user is not composing. It must be called from
+// layout code.
+func (r *Room) idleComposing(now time.Time) time.Time {
+	if !r.composing {
+		return time.Time{}
+	}
+	if idle := r.typedAt.Add(ComposingIdle); now.Before(idle) {
+		return idle
+	}
+	r.StopComposing()
+	return time.Time{}
+}
+
+// signalComposing sends the composing state of the local user to the
+// backend without blocking. Signals are sent one at a time by a single
+// goroutine, so that they arrive in order. A signal superseded before it
+// was sent is dropped.
+func (r *Room) signalComposing(composing bool) {
+	r.Lock()
+	r.signal, r.signalPending = composing, true
+	if r.signalling {
+		r.Unlock()
+		return
+	}
+	r.signalling = true
+	r.Unlock()
+	go func() {
+		for {
+			r.Lock()
+			if !r.signalPending {
+				r.signalling = false
+				r.Unlock()
+				return
+			}
+			composing := r.signal
+			r.signalPending = false
+			r.Unlock()
+			if err := r.Backend.Typing(r.Name, composing); err != nil {
+				log.Printf("signalling composing: %v", err)
+			}
+		}
+	}()
+}
+
 // SetComposing sets the composing status for a user in this room.
 // Note: doesn't actually verify the user pertains to this room.
 func (r *Room) SetComposing(user string, isComposing bool) {
 	r.Room.SetComposing(user, isComposing)
 }
 
-// Send attempts to send arbitrary content as a message from the specified user.
-func (r *Room) Send(user, content string) {
-	row := r.Messages.Send(user, content)
+// Receive presents a message pushed by the backend.
+func (r *Room) Receive(row model.Message) {
 	r.Lock()
 	r.Room.Latest = &row
+	if !row.Read {
+		r.Room.Unread++
+		if row.Mentioned(r.Backend.Local().Name) {
+			r.Room.Mentions = append([]list.Serial{row.Serial()}, r.Room.Mentions...)
+		}
+	}
 	r.Unlock()
+	r.Index.Add(r.Name, row)
 	go r.ListState.Modify([]list.Element{row}, nil, nil)
 }
 
 // SendLocal attempts to send the contents of the edit buffer as a
-// to the model.
+// to the model, as a reply to the quoted message if replyTo is not nil,
+// mentioning the named users.
+// Each attachment is sent as a message of its own, ahead of the text.
+// The messages are shown as pending right away, and updated as the backend
+// reports their delivery status.
 // All of the work of this method is dispatched in a new goroutine
 // so that it can safely be called from layout code without blocking.
-func (r *Room) SendLocal(msg string) {
+func (r *Room) SendLocal(msg string, mentions []string, replyTo *model.Quote, attachments ...model.Attachment) {
+	go func() {
+		var rows []model.Message
+		for ii := range attachments {
+			row := r.Backend.Compose(r.Name, "")
+			setAttachment(&row, attachments[ii])
+			rows = append(rows, row)
+		}
+		if msg != "" {
+			row := r.Backend.Compose(r.Name, msg)
+			row.Mentions = mentions
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 {
+			return
+		}
+		rows[0].ReplyTo = replyTo
+		r.post(rows)
+	}()
+}
+
+// SendVoice sends a voice message, whose audio is the attachment a, as
+// SendLocal sends messages.
+func (r *Room) SendVoice(a model.Attachment, voice model.Voice, replyTo *model.Quote) {
+	go func() {
+		row := r.Backend.Compose(r.Name, "")
+		setAttachment(&row, a)
+		row.Voice = &voice
+		row.ReplyTo = replyTo
+		r.post([]model.Message{row})
+	}()
+}
+
+// post shows the composed rows as pending, and delivers them.
+func (r *Room) post(rows []model.Message) {
+	elems := make([]list.Element, len(rows))
+	r.Lock()
+	if r.statuses == nil {
+		r.statuses = make(map[list.Serial]model.Status)
+	}
+	for ii, row := range rows {
+		r.statuses[row.Serial()] = row.Status
+		elems[ii] = row
+	}
+	r.Room.Latest = &rows[len(rows)-1]
+	r.Unlock()
+	for _, row := range rows {
+		r.Index.Add(r.Name, row)
+	}
+	r.ListState.Modify(elems, nil, nil)
+	for _, row := range rows {
+		r.UpdateStatus(r.deliver(row))
+	}
+}
+
+// deliver uploads the attachment of the row, if any, and sends it,
+// returning the row as reported by the backend.
+func (r *Room) deliver(row model.Message) model.Message {
+	if row.Attachment != nil {
+		a, err := r.Backend.Attach(r.Name, *row.Attachment)
+		if err != nil {
+			log.Printf("sending message: %v", err)
+			row.Status = model.StatusFailed
+			return row
+		}
+		setAttachment(&row, a)
+	}
+	sent, err := r.Backend.Send(r.Name, row)
+	if err != nil {
+		log.Printf("sending message: %v", err)
+		row.Status = model.StatusFailed
+		return row
+	}
+	return sent
+}
+
+// setAttachment sets the attachment of the message. Images are also
+// presented as the image of the message.
+func setAttachment(row *model.Message, a model.Attachment) {
+	row.Attachment = &a
+	if a.IsImage() {
+		row.Image = a.URL
+	}
+}
+
+// MarkRead marks the messages of the room up to and including the one with
+// the provided serial as read, unless a later one already was. All of the
+// work of this method is dispatched in a new goroutine so that it can
+// safely be called from layout code.
+func (r *Room) MarkRead(serial list.Serial) {
+	r.Lock()
+	if r.read != list.NoSerial && !model.SerialLessThan(r.read, serial) {
+		r.Unlock()
+		return
+	}
+	r.read = serial
+	r.Unlock()
 	go func() {
+		unread, err := r.Backend.MarkRead(r.Name, serial)
+		if err != nil {
+			log.Printf("marking room read: %v", err)
+			return
+		}
 		r.Lock()
-		row := r.Messages.Send(r.Messages.Local.Name, msg)
-		r.Room.Latest = &row
+		// Counts reported for earlier marks are stale.
+		if r.read == serial {
+			r.Room.Unread = unread
+		}
+		r.Room.ReadMentions(serial)
 		r.Unlock()
-		r.ListState.Modify([]list.Element{row}, nil, nil)
 	}()
 }
 
-// NewRow generates a new row in the Room's RowTracker and inserts it
-// into the list manager for the room.
-func (r *Room) NewRow() {
-	row := r.Messages.NewRow()
-	go r.ListState.Modify([]list.Element{row}, nil, nil)
+// Group returns a copy of the members and settings of the room.
+func (r *Room) Group() model.Group {
+	r.Lock()
+	defer r.Unlock()
+	return r.Room.Group
+}
+
+// DisplayName returns the name the room is presented under.
+func (r *Room) DisplayName() string {
+	r.Lock()
+	defer r.Unlock()
+	return r.Room.DisplayName()
+}
+
+// SetGroup presents a change to the members or settings of the room.
+func (r *Room) SetGroup(g model.Group) {
+	r.Lock()
+	r.Room.Group = g
+	r.Unlock()
+}
+
+// ChangeGroup applies a change to the members or settings of the room on
+// behalf of the local user, and presents the notice recording it. All of
+// the work of this method is dispatched in a new goroutine so that it can
+// safely be called from layout code.
+func (r *Room) ChangeGroup(change model.GroupChange) {
+	go func() {
+		g, notice, err := r.Backend.ChangeGroup(r.Name, change)
+		if err != nil {
+			log.Printf("changing group: %v", err)
+			return
+		}
+		r.SetGroup(g)
+		r.Receive(notice)
+	}()
+}
+
+// Unread returns the number of messages of the room the local user has not
+// read.
+func (r *Room) Unread() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.Room.Unread
+}
+
+// Mentions returns the number of unread messages of the room that mention
+// the local user.
+func (r *Room) Mentions() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.Room.Mentions)
+}
+
+// UpdateStatus presents a change in the delivery status of a message sent
+// from this client. Updates that would move the message back to an
+// earlier status are discarded.
+func (r *Room) UpdateStatus(row model.Message) {
+	r.Lock()
+	if current, ok := r.statuses[row.Serial()]; ok {
+		if !current.CanAdvance(row.Status) {
+			r.Unlock()
+			return
+		}
+	}
+	if r.statuses == nil {
+		r.statuses = make(map[list.Serial]model.Status)
+	}
+	// Read is kept rather than forgotten, so that it keeps discarding
+	// stale updates that arrive after it.
+	r.statuses[row.Serial()] = row.Status
+	if r.Room.Latest != nil && r.Room.Latest.Serial() == row.Serial() {
+		r.Room.Latest = &row
+	}
+	r.Unlock()
+	go r.ListState.InPlace([]list.Element{row})
+}
+
+// ReadBy presents that user read the room up to and including the message
+// with the provided serial, advancing the messages sent by the local user
+// up to it to the read status. Pages are walked backwards from the latest
+// message until one that was already read is found. All of the work of
+// this method is dispatched in a new goroutine.
+func (r *Room) ReadBy(user string, serial list.Serial) {
+	local := r.Backend.Local().Name
+	if user == local {
+		return
+	}
+	go func() {
+		relativeTo := list.NoSerial
+		for {
+			elems, more := r.Backend.Load(r.Name, list.Before, relativeTo)
+			for ii := len(elems) - 1; ii >= 0; ii-- {
+				row := elems[ii].(model.Message)
+				if row.Sender != local || model.SerialLessThan(serial, row.Serial()) {
+					continue
+				}
+				r.Lock()
+				if current, ok := r.statuses[row.Serial()]; ok {
+					row.Status = current
+				}
+				r.Unlock()
+				if row.Status == model.StatusRead {
+					return
+				}
+				if !row.Status.CanAdvance(model.StatusRead) {
+					continue
+				}
+				row.Status = model.StatusRead
+				r.UpdateStatus(row)
+			}
+			if !more || len(elems) == 0 {
+				return
+			}
+			relativeTo = elems[0].Serial()
+		}
+	}()
+}
+
+// React adds the reaction of the local user with emoji to the message with
+// the provided serial, or removes it if add is false. All of the work of
+// this method is dispatched in a new goroutine so that it can safely be
+// called from layout code.
+func (r *Room) React(serial list.Serial, emoji string, add bool) {
+	go func() {
+		row, err := r.Backend.React(r.Name, serial, emoji, add)
+		if err != nil {
+			log.Printf("reacting to message: %v", err)
+			return
+		}
+		r.UpdateMessage(row)
+	}()
+}
+
+// Edit replaces the content of the message with the provided serial, sent
+// by the local user. All of the work of this method is dispatched in a new
+// goroutine so that it can safely be called from layout code.
+func (r *Room) Edit(serial list.Serial, content string) {
+	go func() {
+		row, err := r.Backend.Edit(r.Name, serial, content)
+		if err != nil {
+			log.Printf("editing message: %v", err)
+			return
+		}
+		r.UpdateMessage(row)
+	}()
+}
+
+// CancelEdit stops editing a message, clearing the editor. It must be
+// called from layout code.
+func (r *Room) CancelEdit() {
+	r.Editing = nil
+	r.Mentioning = nil
+	r.Editor.SetText("")
+	r.StopComposing()
+}
+
+// Recall recalls the message with the provided serial, sent by the local
+// user. All of the work of this method is dispatched in a new goroutine so
+// that it can safely be called from layout code.
+func (r *Room) Recall(serial list.Serial) {
+	go func() {
+		row, err := r.Backend.Recall(r.Name, serial)
+		if err != nil {
+			log.Printf("recalling message: %v", err)
+			return
+		}
+		r.UpdateMessage(row)
+	}()
+}
+
+// UpdateMessage presents a change made to a message after it was sent,
+// such as reactions, edits and recalls. The delivery status presented for
+// messages sent from this client is kept if it is more recent than the one
+// of row.
+func (r *Room) UpdateMessage(row model.Message) {
+	if row.Recalled {
+		r.Index.Remove(r.Name, row.Serial())
+	} else {
+		r.Index.Add(r.Name, row)
+	}
+	r.Lock()
+	if current, ok := r.statuses[row.Serial()]; ok && !current.CanAdvance(row.Status) {
+		row.Status = current
+	}
+	if r.Room.Latest != nil && r.Room.Latest.Serial() == row.Serial() {
+		r.Room.Latest = &row
+	}
+	r.Unlock()
+	go r.ListState.InPlace([]list.Element{row})
+}
+
+// Retry re-submits a message that failed to send. The failed row is
+// removed and its content sent anew, moving it to the end of the room.
+func (r *Room) Retry(row model.Message) {
+	go func() {
+		if err := r.Backend.Delete(r.Name, row.Serial()); err != nil {
+			log.Printf("retrying message: %v", err)
+			return
+		}
+		r.Lock()
+		delete(r.statuses, row.Serial())
+		r.Unlock()
+		r.Index.Remove(r.Name, row.Serial())
+		r.ListState.Modify(nil, nil, []list.Serial{row.Serial()})
+		if row.Voice != nil && row.Attachment != nil {
+			r.SendVoice(*row.Attachment, *row.Voice, row.ReplyTo)
+			return
+		}
+		var attachments []model.Attachment
+		if row.Attachment != nil {
+			attachments = append(attachments, *row.Attachment)
+		}
+		r.SendLocal(row.Content, row.Mentions, row.ReplyTo, attachments...)
+	}()
 }
 
 // DeleteRow removes the row with the provided serial from both the
-// row tracker and the list manager for the room.
+// backend and the list manager for the room.
 func (r *Room) DeleteRow(serial list.Serial) {
-	r.Messages.Delete(serial)
-	go r.ListState.Modify(nil, nil, []list.Serial{serial})
+	go func() {
+		if err := r.Backend.Delete(r.Name, serial); err != nil {
+			log.Printf("deleting message: %v", err)
+			return
+		}
+		r.Index.Remove(r.Name, serial)
+		r.ListState.Modify(nil, nil, []list.Serial{serial})
+	}()
+}
+
+// JumpTo shows the message with the provided serial, loading the history
+// surrounding it if needed. All of the work of this method is dispatched
+// in a new goroutine so that it can safely be called from layout code.
+func (r *Room) JumpTo(serial list.Serial) {
+	go r.ListState.JumpTo(serial)
 }
 
 // Active returns the active room, empty if not rooms are available.
 func (r *Rooms) Active() *Room {
 	r.Lock()
 	defer r.Unlock()
-	if len(r.List) == 0 {
+	if r.active == nil {
 		return &Room{}
 	}
-	return &r.List[r.active]
+	return r.active
 }
 
 // Latest returns a copy of the latest message for the room.
@@ -108,21 +574,108 @@ func (r *Room) Latest() model.Message {
 	return *r.Room.Latest
 }
 
+// Unread returns the number of messages the local user has not read across
+// the rooms that are not muted, or that mention the local user.
+func (r *Rooms) Unread() int {
+	r.Lock()
+	defer r.Unlock()
+	count := 0
+	for _, room := range r.List {
+		if !room.Muted || room.Mentions() > 0 {
+			count += room.Unread()
+		}
+	}
+	return count
+}
+
+// Sort orders the rooms by latest activity, the pinned ones first.
+func (r *Rooms) Sort() {
+	r.Lock()
+	defer r.Unlock()
+	latest := make(map[*Room]time.Time, len(r.List))
+	for _, room := range r.List {
+		latest[room] = room.Latest().SentAt
+	}
+	sort.SliceStable(r.List, func(i, j int) bool {
+		a, b := r.List[i], r.List[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		return latest[a].After(latest[b])
+	})
+}
+
+// Remove drops room from the list. The first room is selected if room was
+// active.
+func (r *Rooms) Remove(room *Room) {
+	r.Lock()
+	defer r.Unlock()
+	for ii := range r.List {
+		if r.List[ii] == room {
+			r.List = append(r.List[:ii], r.List[ii+1:]...)
+			break
+		}
+	}
+	if r.active == room {
+		room.Interact.Active = false
+		r.active = nil
+		r.changed = true
+		r.selectLocked(0)
+	}
+}
+
 // Select the room at the given index.
 // Index is bounded by [0, len(rooms)).
 func (r *Rooms) Select(index int) {
 	r.Lock()
 	defer r.Unlock()
+	r.selectLocked(index)
+}
+
+// Step selects the room delta positions away from the active one.
+// The resulting index is bounded by [0, len(rooms)).
+func (r *Rooms) Step(delta int) {
+	r.Lock()
+	defer r.Unlock()
+	for ii, room := range r.List {
+		if room == r.active {
+			r.selectLocked(ii + delta)
+			return
+		}
+	}
+	r.selectLocked(0)
+}
+
+func (r *Rooms) selectLocked(index int) {
+	if len(r.List) == 0 {
+		return
+	}
 	if index < 0 {
 		index = 0
 	}
-	if index > len(r.List) {
+	if index >= len(r.List) {
 		index = len(r.List) - 1
 	}
 	r.changed = true
-	r.List[r.active].Interact.Active = false
-	r.active = index
-	r.List[r.active].Interact.Active = true
+	if r.active != nil {
+		r.active.Interact.Active = false
+	}
+	r.active = r.List[index]
+	r.active.Interact.Active = true
+}
+
+// SelectName selects the room with the given name, reporting whether
+// there is one.
+func (r *Rooms) SelectName(name string) bool {
+	r.Lock()
+	defer r.Unlock()
+	for ii, room := range r.List {
+		if room.Name == name {
+			r.selectLocked(ii)
+			return true
+		}
+	}
+	return false
 }
 
 // Changed if the active room has changed since last call.
@@ -141,15 +694,20 @@ func (r *Rooms) Index(index int) *Room {
 	if index < 0 {
 		index = 0
 	}
-	if index > len(r.List) {
+	if index >= len(r.List) {
 		index = len(r.List) - 1
 	}
-	return &r.List[index]
+	return r.List[index]
 }
 
-// Index returns a pointer to a random Room in the list.
-func (r *Rooms) Random() *Room {
+// Lookup returns the room with the given name, or nil if there is none.
+func (r *Rooms) Lookup(name string) *Room {
 	r.Lock()
 	defer r.Unlock()
-	return &r.List[rand.Intn(len(r.List)-1)]
+	for _, room := range r.List {
+		if room.Name == name {
+			return room
+		}
+	}
+	return nil
 }
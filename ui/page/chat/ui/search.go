@@ -0,0 +1,195 @@
+package ui
+
+import (
+	"strings"
+	"wechat_ui/ui/page/chat/appwidget"
+	"wechat_ui/ui/page/chat/appwidget/apptheme"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/search"
+	"wechat_ui/ui/pkg/list"
+	"wechat_ui/ui/theme"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+	"gioui.org/x/richtext"
+
+	matchat "wechat_ui/ui/pkg/widget/material"
+)
+
+const (
+	// MaxSearchResults bounds how many messages a search presents.
+	MaxSearchResults = 50
+	// snippetWidth is the number of characters of a message shown in a
+	// search result.
+	snippetWidth = 24
+)
+
+// Search holds the state of the search sidebar.
+type Search struct {
+	// Index of the messages of every room.
+	Index *search.Index
+	// List of search results.
+	List widget.List
+	// query the results were computed for.
+	query string
+	rooms []*searchRoom
+	hits  []*searchHit
+}
+
+// searchRoom is a room whose name matches the query.
+type searchRoom struct {
+	room     *Room
+	interact appwidget.Room
+}
+
+// searchHit is a message matching the query.
+type searchHit struct {
+	search.Hit
+	clickable widget.Clickable
+	text      richtext.InteractiveText
+}
+
+// indexHistory adds the history of the rooms to the search index, paging
+// backwards from the most recent message.
+func (ui *UI) indexHistory(rooms []*model.Room) {
+	for _, r := range rooms {
+		relativeTo := list.NoSerial
+		for {
+			elems, more := ui.Backend.Load(r.Name, list.Before, relativeTo)
+			for _, e := range elems {
+				if msg, ok := e.(model.Message); ok {
+					ui.Search.Index.Add(r.Name, msg)
+				}
+			}
+			if !more || len(elems) == 0 {
+				break
+			}
+			relativeTo = elems[0].Serial()
+		}
+	}
+}
+
+// updateSearch recomputes the results if the query changed.
+func (ui *UI) updateSearch(query string) {
+	s := &ui.Search
+	if query == s.query {
+		return
+	}
+	s.query = query
+	s.rooms, s.hits = s.rooms[:0], s.hits[:0]
+	s.List.Position = layout.Position{}
+	if query == "" {
+		return
+	}
+	lower := strings.ToLower(query)
+	for _, r := range ui.Rooms.List {
+		if strings.Contains(strings.ToLower(r.Name), lower) {
+			s.rooms = append(s.rooms, &searchRoom{room: r})
+		}
+	}
+	for _, hit := range s.Index.Search(query, MaxSearchResults) {
+		s.hits = append(s.hits, &searchHit{Hit: hit})
+	}
+}
+
+// layoutSearchResult returns the widget presenting the rooms and messages
+// matching the search text, or nil if there is none.
+func (ui *UI) layoutSearchResult() layout.Widget {
+	query := strings.TrimSpace(ui.SearchEditor.Text())
+	ui.updateSearch(query)
+	if query == "" {
+		return nil
+	}
+	s := &ui.Search
+	for _, r := range s.rooms {
+		if r.interact.Clicked() {
+			ui.openSearchResult(r.room.Name, list.NoSerial)
+			return nil
+		}
+	}
+	for _, hit := range s.hits {
+		if hit.clickable.Clicked() {
+			ui.openSearchResult(hit.Room, hit.Message.Serial())
+			return nil
+		}
+	}
+	return func(gtx C) D {
+		if len(s.rooms)+len(s.hits) == 0 {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				lbl := material.Body2(th.Theme, "无结果")
+				lbl.Color = theme.Current().Hint
+				return lbl.Layout(gtx)
+			})
+		}
+		s.List.Axis = layout.Vertical
+		gtx.Constraints.Min = gtx.Constraints.Max
+		return material.List(th.Theme, &s.List).Layout(gtx, len(s.rooms)+len(s.hits), func(gtx C, ii int) D {
+			if ii < len(s.rooms) {
+				r := s.rooms[ii]
+				latest := r.room.Latest()
+				return apptheme.Room(th.Theme, &r.interact, &apptheme.RoomConfig{
+					Name:    r.room.Name,
+					Image:   r.room.Image,
+					Content: latest.Preview(),
+					SentAt:  latest.SentAt,
+				}).Layout(gtx)
+			}
+			return ui.layoutSearchHit(gtx, s.hits[ii-len(s.rooms)])
+		})
+	}
+}
+
+// layoutSearchHit lays out a message matching the query, with the matches
+// highlighted.
+func (ui *UI) layoutSearchHit(gtx C, hit *searchHit) D {
+	snippet, matches := search.Snippet(hit.Message.Content, hit.Matches, snippetWidth)
+	var (
+		spans = make([]richtext.SpanStyle, 0, 2*len(matches)+1)
+		plain = richtext.SpanStyle{Size: unit.Sp(12), Color: th.Fg}
+		match = richtext.SpanStyle{Size: unit.Sp(12), Color: th.ContrastBg, Font: font.Font{Weight: font.Bold}}
+		last  int
+	)
+	for _, m := range matches {
+		plain.Content, match.Content = snippet[last:m.Start], snippet[m.Start:m.End]
+		spans = append(append(spans, matchat.EmojiSpans(plain)...), match)
+		last = m.End
+	}
+	plain.Content = snippet[last:]
+	spans = append(spans, matchat.EmojiSpans(plain)...)
+
+	return material.Clickable(gtx, &hit.clickable, func(gtx C) D {
+		return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+						layout.Rigid(material.Label(th.Theme, unit.Sp(14), hit.Message.Sender).Layout),
+						layout.Flexed(1, func(gtx C) D {
+							lbl := material.Label(th.Theme, unit.Sp(12), hit.Room)
+							lbl.Color = component.WithAlpha(th.Fg, 150)
+							return layout.E.Layout(gtx, lbl.Layout)
+						}),
+					)
+				}),
+				layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+				layout.Rigid(richtext.Text(&hit.text, th.Shaper, spans...).Layout),
+			)
+		})
+	})
+}
+
+// openSearchResult makes the room active and jumps to the message with the
+// serial, if any, then clears the search.
+func (ui *UI) openSearchResult(room string, serial list.Serial) {
+	if !ui.Rooms.SelectName(room) {
+		return
+	}
+	ui.InsideRoom = true
+	if serial != list.NoSerial {
+		ui.Rooms.Active().JumpTo(serial)
+	}
+	ui.SearchEditor.SetText("")
+}
@@ -7,27 +7,38 @@ import (
 	"image/color"
 	"image/png"
 	"log"
-	"strconv"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/components"
 	"wechat_ui/ui/page/chat/appwidget/apptheme"
-	"wechat_ui/ui/page/chat/gen"
+	"wechat_ui/ui/page/chat/backend"
 	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/prefs"
+	"wechat_ui/ui/page/chat/search"
 	"wechat_ui/ui/pkg/async"
+	"wechat_ui/ui/pkg/audio"
+	"wechat_ui/ui/pkg/emoji"
 	"wechat_ui/ui/pkg/list"
 	"wechat_ui/ui/pkg/ninepatch"
+	"wechat_ui/ui/pkg/notify"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/v"
 	"wechat_ui/ui/values"
 
+	"gioui.org/io/clipboard"
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"gioui.org/x/component"
-	lorem "github.com/drhodes/golorem"
+	"gioui.org/x/richtext"
 
 	chatlayout "wechat_ui/ui/pkg/layout"
 
@@ -41,15 +52,29 @@ type (
 )
 
 type Config struct {
-	// theme to use {light,dark}.
-	Theme string
-	// latency specifies maximum latency (in millis) to simulate
-	Latency int
-	// loadSize specifies maximum number of items to load at a time.
-	LoadSize int
 	// bufferSize specifies how many elements to hold in memory before
 	// compacting the list.
 	BufferSize int
+	// RecallWindow is how long after sending a message the local user is
+	// offered to edit or recall it. Defaults to model.DefaultRecallWindow.
+	RecallWindow time.Duration
+	// DataDir is the directory local state, such as the recently used
+	// emoji, is persisted in. Empty keeps that state in memory only.
+	DataDir string
+	// Recorder and Player record and play voice messages. They default to
+	// the file backed implementations of package audio, which need no
+	// audio hardware.
+	Recorder audio.Recorder
+	Player   audio.Player
+	// Notifier notifies incoming messages outside of the window. Defaults
+	// to notify.Nop, which leaves them to the toasts of the window.
+	Notifier notify.Notifier
+	// Toasts present incoming messages in the window, above every page.
+	// They are not presented if nil.
+	Toasts *components.Toasts
+	// Settings are the settings of the local user, shared with the pages
+	// changing them. They are opened from DataDir if nil.
+	Settings *prefs.Settings
 }
 
 // th is the active theme object.
@@ -80,6 +105,8 @@ type UI struct {
 	Local *model.User
 	// Users contains user data.
 	Users *model.Users
+	// Backend is the source of rooms and messages.
+	Backend backend.Backend
 	// RoomList for the sidebar.
 	RoomList widget.List
 	// Modal can show widgets atop the rest of the ui.
@@ -95,15 +122,109 @@ type UI struct {
 	// DeleteBtn holds click state for a button that removes a message
 	// from the current room.
 	DeleteBtn widget.Clickable
+	// ReplyBtn holds click state for a button that starts a reply to a
+	// message of the current room.
+	ReplyBtn widget.Clickable
+	// CancelReplyBtn holds click state for a button that stops replying.
+	CancelReplyBtn widget.Clickable
+	// ReactBtn holds click state for a button that reacts to a message of
+	// the current room with an emoji.
+	ReactBtn widget.Clickable
+	// EditBtn and RecallBtn hold click state for buttons that edit and
+	// recall a message the local user sent to the current room.
+	EditBtn, RecallBtn widget.Clickable
+	// CancelEditBtn holds click state for a button that stops editing.
+	CancelEditBtn widget.Clickable
+	// CopyBtn and SelectBtn hold click state for buttons that copy a
+	// message of the current room, and start selecting messages to copy.
+	CopyBtn, SelectBtn widget.Clickable
+	// CopySelectedBtn and CancelSelectBtn hold click state for buttons that
+	// copy the transcript of the selected messages, and stop selecting.
+	CopySelectedBtn, CancelSelectBtn widget.Clickable
+	// FileBtn and ScreenshotBtn hold click state for the editor toolbar
+	// buttons attaching a file and an image.
+	FileBtn, ScreenshotBtn widget.Clickable
+	// AttachDialog asks for the file to attach.
+	AttachDialog AttachDialog
+	// EmojiBtn holds click state for the editor toolbar button showing
+	// the emoji picker.
+	EmojiBtn widget.Clickable
+	// EmojiPicker inserts emoji in the editor.
+	EmojiPicker EmojiPicker
+	// MentionList suggests the users to mention in the editor.
+	MentionList MentionList
+	// VoiceBtn holds click state for the editor toolbar button recording
+	// a voice message.
+	VoiceBtn widget.Clickable
+	// VoiceBar records voice messages in place of the editor.
+	VoiceBar VoiceBar
+	// Recorder records voice messages, and Player plays them.
+	Recorder audio.Recorder
+	Player   audio.Player
+	// EditorArea holds the clicks state for the right-click context menu
+	// of the editor.
+	EditorArea component.ContextArea
+	// EditorMenu is the context menu available on the editor.
+	EditorMenu component.MenuState
+	// PasteBtn and PasteImageBtn hold click state for the editor menu
+	// buttons pasting the text and the image on the clipboard.
+	PasteBtn, PasteImageBtn widget.Clickable
+	// SendKeyArea holds the clicks state of the arrow beside the send
+	// button, which offers SendKeyMenu.
+	SendKeyArea component.ContextArea
+	// SendKeyMenu chooses the key that sends messages.
+	SendKeyMenu component.MenuState
+	// SendEnterBtn and SendShortEnterBtn hold click state for the send key
+	// menu buttons choosing Enter and Short-Enter to send messages.
+	SendEnterBtn, SendShortEnterBtn widget.Clickable
 	// MessageMenu is the context menu available on messages.
 	MessageMenu component.MenuState
 	// ContextMenuTarget tracks the message state on which the context
 	// menu is currently acting.
 	ContextMenuTarget *model.Message
+	// RoomMenu is the context menu available on the rooms of the room
+	// list.
+	RoomMenu component.MenuState
+	// RoomMenuTarget is the room on which the room menu is acting.
+	RoomMenuTarget *Room
+	// PinBtn and MuteBtn hold click state for the room menu buttons
+	// toggling whether the room is pinned and muted.
+	PinBtn, MuteBtn widget.Clickable
+	// RoomPrefs persists the pinned and muted rooms.
+	RoomPrefs *prefs.Rooms
+	// Settings persists the settings of the local user, such as the key
+	// that sends messages.
+	Settings *prefs.Settings
+	// GroupBtn holds click state for the chat bar button toggling the
+	// group panel.
+	GroupBtn widget.Clickable
+	// GroupPanel presents the members and settings of the active room.
+	GroupPanel GroupPanel
+	// Toasts presents the messages received in the other rooms in the
+	// window, if set.
+	Toasts *components.Toasts
+	// Notifier notifies them outside of the window.
+	Notifier notify.Notifier
 
-	SearchEditor  *widget.Editor
+	SearchEditor *widget.Editor
+	// Search holds the results for the text of SearchEditor.
+	Search        Search
 	AddContactBtn v.IconButton
 	SearchHeight  int
+
+	conf       Config
+	invalidate func()
+	// created receives the rooms created on behalf of OpenRoom.
+	created chan *model.Room
+	// pasted receives the images pasted by pasteImage.
+	pasted chan pastedImage
+	// playback is the state of Player as of the frame being laid out.
+	playback audio.Status
+	// shown reports whether the chat is the page displayed, as set by
+	// SetShown.
+	shown atomic.Bool
+	// dark reports whether the dark palette is in use.
+	dark bool
 }
 
 // loadNinePatch from the embedded resources package.
@@ -125,114 +246,197 @@ var (
 	hotdog = loadNinePatch("9-Patch/iap_hotdog_asset.png")
 )
 
-// NewUI constructs a UI and populates it with dummy data.
-func NewUI(invalidator func(), conf Config) *UI {
+// NewUI constructs a UI presenting the rooms and messages of the provided
+// backend.
+func NewUI(invalidator func(), conf Config, b backend.Backend) *UI {
 	var ui UI
 
-	switch conf.Theme {
-	case "light":
-		th.UsePalette(apptheme.Light)
-	case "dark":
-		th.UsePalette(apptheme.Dark)
-	}
+	th.UsePalette(apptheme.NewPalette(theme.Current()))
+	ui.dark = theme.IsDark()
 
 	ui.SearchEditor = &widget.Editor{}
 
 	ui.Modal.VisibilityAnimation.Duration = time.Millisecond * 250
 
-	ui.MessageMenu = component.MenuState{
-		Options: []func(gtx C) D{
-			component.MenuItem(th.Theme, &ui.DeleteBtn, "Delete").Layout,
-		},
+	ui.AddContactBtn = v.NewIconButton(ContentAdd, theme.Current().Icon, th.Bg)
+	ui.AddContactBtn.Size = unit.Dp(30)
+
+	ui.Backend = b
+	ui.Users = b.Users()
+	ui.Local = b.Local()
+	recent, err := emoji.OpenRecent(conf.DataDir, ui.Local.Name)
+	if err != nil {
+		log.Printf("opening recent emoji: %v", err)
+	}
+	ui.EmojiPicker.Recent = recent
+	roomPrefs, err := prefs.OpenRooms(conf.DataDir, ui.Local.Name)
+	if err != nil {
+		log.Printf("opening room preferences: %v", err)
 	}
+	ui.RoomPrefs = roomPrefs
+	if conf.Settings == nil {
+		settings, err := prefs.OpenSettings(conf.DataDir, ui.Local.Name)
+		if err != nil {
+			log.Printf("opening settings: %v", err)
+		}
+		conf.Settings = settings
+	}
+	ui.Settings = conf.Settings
 
-	g := &gen.Generator{
-		FetchImage: func(sz image.Point) image.Image {
-			img, _ := randomImage(sz)
-			return img
-		},
+	if conf.RecallWindow <= 0 {
+		conf.RecallWindow = model.DefaultRecallWindow
+	}
+	if conf.Recorder == nil {
+		conf.Recorder = &audio.FileRecorder{Dir: filepath.Join(os.TempDir(), "chat", "voice")}
+	}
+	if conf.Player == nil {
+		conf.Player = &audio.FilePlayer{}
 	}
+	ui.Recorder, ui.Player = conf.Recorder, conf.Player
+	if conf.Notifier == nil {
+		conf.Notifier = notify.Nop{}
+	}
+	ui.Notifier = conf.Notifier
+	ui.Toasts = conf.Toasts
+	ui.conf = conf
+	ui.invalidate = invalidator
+	ui.created = make(chan *model.Room, 1)
+	ui.pasted = make(chan pastedImage, 1)
+	ui.Search.Index = search.New()
+	rooms := b.Rooms()
+	for _, r := range rooms {
+		ui.addRoom(r)
+	}
+	go ui.indexHistory(rooms)
 
-	ui.AddContactBtn = v.NewIconButton(ContentAdd, values.Gray1, th.Bg)
-	ui.AddContactBtn.Size = unit.Dp(30)
+	go ui.listen(b.Subscribe())
 
-	// Generate most of the model data.
-	var (
-		rooms = g.GenRooms(3, 10)
-		users = g.GenUsers(10, 30)
-		local = users.Random()
-	)
+	ui.Rooms.Sort()
+	ui.Rooms.Select(0)
 
-	ui.Users = users
-	ui.Local = local
-
-	for _, r := range rooms.List() {
-		rt := NewExampleData(users, local, g, 100)
-		rt.SimulateLatency = conf.Latency
-		rt.MaxLoads = conf.LoadSize
-		lm := list.NewManager(conf.BufferSize,
-			list.Hooks{
-				// Define an allocator function that can instaniate the appropriate
-				// state type for each kind of row data in our list.
-				Allocator: func(data list.Element) interface{} {
-					switch data.(type) {
-					case model.Message:
-						return &chatwidget.Row{}
-					default:
-						return nil
-					}
-				},
-				// Define a presenter that can transform each kind of row data
-				// and state into a widget.
-				Presenter: ui.presentChatRow,
-				// NOTE(jfm): awkard coupling between message data and `list.Manager`.
-				Loader:      rt.Load,
-				Synthesizer: synth,
-				Comparator:  rowLessThan,
-				Invalidator: invalidator,
+	ui.Bg = th.Palette.Bg
+
+	return &ui
+}
+
+// addRoom appends a room presenting the messages of r to the room list.
+func (ui *UI) addRoom(r *model.Room) {
+	b := ui.Backend
+	lm := list.NewManager(ui.conf.BufferSize,
+		list.Hooks{
+			// Define an allocator function that can instaniate the appropriate
+			// state type for each kind of row data in our list.
+			Allocator: func(data list.Element) interface{} {
+				switch data.(type) {
+				case model.Message:
+					return &chatwidget.Row{}
+				default:
+					return nil
+				}
 			},
-		)
-		lm.Stickiness = list.After
-		ui.Rooms.List = append(ui.Rooms.List, Room{
-			Room:      r,
-			Messages:  rt,
-			ListState: lm,
-		})
+			// Define a presenter that can transform each kind of row data
+			// and state into a widget.
+			Presenter: ui.presentChatRow,
+			Loader: func(dir list.Direction, relativeTo list.Serial) ([]list.Element, bool) {
+				return b.Load(r.Name, dir, relativeTo)
+			},
+			AroundLoader: func(serial list.Serial) ([]list.Element, bool, bool) {
+				return b.LoadAround(r.Name, serial)
+			},
+			Synthesizer: synth,
+			Comparator:  model.RowLessThan,
+			Invalidator: ui.invalidate,
+		},
+	)
+	lm.Stickiness = list.After
+	ui.Rooms.Lock()
+	defer ui.Rooms.Unlock()
+	pref := ui.RoomPrefs.Get(r.Name)
+	added := &Room{
+		Room:      r,
+		Backend:   b,
+		Index:     ui.Search.Index,
+		ListState: lm,
+		Pinned:    pref.Pinned,
+		Muted:     pref.Muted,
 	}
+	ui.Rooms.List = append(ui.Rooms.List, added)
+	added.List.ScrollToEnd = true
+	added.List.Axis = layout.Vertical
+}
 
-	// spin up a bunch of async actors to send messages to rooms.
-	for _, u := range users.List() {
-		u := u
-		if u.Name == local.Name {
-			continue
-		}
-		go func() {
-			for {
-				var (
-					respond = time.Second * time.Duration(1)
-					compose = time.Second * time.Duration(1)
-					room    = ui.Rooms.Random()
-				)
-				func() {
-					time.Sleep(respond)
-					room.SetComposing(u.Name, true)
-					time.Sleep(compose)
-					room.SetComposing(u.Name, false)
-					room.Send(u.Name, lorem.Paragraph(1, 4))
-				}()
-			}
-		}()
+// OpenRoom makes the named room active, asking the backend to create it
+// if it does not exist yet. It must be called from the goroutine that is
+// performing layout.
+func (ui *UI) OpenRoom(name string) {
+	if ui.Rooms.SelectName(name) {
+		ui.InsideRoom = true
+		return
 	}
+	go func() {
+		r, err := ui.Backend.CreateRoom(name)
+		if err != nil {
+			log.Printf("opening room: %v", err)
+			return
+		}
+		ui.created <- r
+		ui.invalidate()
+	}()
+}
 
-	ui.Rooms.Select(0)
-	for ii := range ui.Rooms.List {
-		ui.Rooms.List[ii].List.ScrollToEnd = true
-		ui.Rooms.List[ii].List.Axis = layout.Vertical
+// openCreated adds the room created by OpenRoom, if any, and makes it
+// active.
+func (ui *UI) openCreated() {
+	select {
+	case r := <-ui.created:
+		if ui.Rooms.Lookup(r.Name) == nil {
+			ui.addRoom(r)
+		}
+		ui.Rooms.SelectName(r.Name)
+		ui.InsideRoom = true
+	default:
 	}
+}
 
-	ui.Bg = th.Palette.Bg
+// SetShown records whether the chat is the page displayed. Messages of
+// the active room are only notified while it is not.
+func (ui *UI) SetShown(shown bool) {
+	ui.shown.Store(shown)
+}
 
-	return &ui
+// listen applies the events pushed by the backend to the rooms they
+// pertain to.
+func (ui *UI) listen(events <-chan backend.Event) {
+	for e := range events {
+		room := ui.Rooms.Lookup(e.RoomName())
+		if room == nil {
+			continue
+		}
+		switch e := e.(type) {
+		case backend.MessageEvent:
+			room.Receive(e.Message)
+			if notifiable(e.Message, ui.Local.Name) {
+				ui.notify(room, e.Message)
+			}
+		case backend.DeleteEvent:
+			room.Index.Remove(room.Name, e.Serial)
+			room.ListState.Modify(nil, nil, []list.Serial{e.Serial})
+		case backend.ComposingEvent:
+			room.SetComposing(e.User, e.Composing)
+			ui.invalidate()
+		case backend.StatusEvent:
+			room.UpdateStatus(e.Message)
+		case backend.ReadEvent:
+			room.ReadBy(e.User, e.Serial)
+		case backend.ReactionEvent:
+			room.UpdateMessage(e.Message)
+		case backend.UpdateEvent:
+			room.UpdateMessage(e.Message)
+		case backend.GroupEvent:
+			room.SetGroup(e.Group)
+			ui.invalidate()
+		}
+	}
 }
 
 // Layout the application UI.
@@ -242,8 +446,21 @@ func (ui *UI) Layout(gtx C) D {
 }
 
 func (ui *UI) layout(gtx C) D {
+	if dark := theme.IsDark(); dark != ui.dark {
+		ui.useDark(dark)
+	}
+	ui.openCreated()
+	ui.stagePasted()
+	ui.updatePlayback(gtx)
+	ui.dropLeftRooms()
+	ui.Rooms.Sort()
+	for _, r := range ui.Rooms.List {
+		if at := r.idleComposing(gtx.Now); !at.IsZero() {
+			op.InvalidateOp{At: at}.Add(gtx.Ops)
+		}
+	}
 	for ii := range ui.Rooms.List {
-		r := &ui.Rooms.List[ii]
+		r := ui.Rooms.List[ii]
 		if r.Interact.Clicked() {
 			ui.Rooms.Select(ii)
 			ui.InsideRoom = true
@@ -281,7 +498,18 @@ func (ui *UI) layout(gtx C) D {
 			return layout.Stack{}.Layout(gtx,
 				layout.Stacked(func(gtx C) D {
 					gtx.Constraints.Min = gtx.Constraints.Max
-					return ui.layoutChat(gtx)
+					return layout.Flex{}.Layout(gtx,
+						layout.Flexed(1, ui.layoutChat),
+						layout.Rigid(func(gtx C) D {
+							if !ui.GroupPanel.Visible {
+								return D{}
+							}
+							return layout.Flex{}.Layout(gtx,
+								layout.Rigid(v.SeparatorVertical(gtx.Constraints.Max.Y, 1, component.WithAlpha(th.Fg, 50)).Layout),
+								layout.Rigid(ui.layoutGroupPanel),
+							)
+						}),
+					)
 				}),
 				layout.Expanded(func(gtx C) D {
 					return ui.layoutModal(gtx)
@@ -290,24 +518,61 @@ func (ui *UI) layout(gtx C) D {
 		}),
 	)
 }
+
+// layoutChatBar lays out the name of the active room, who is typing in it,
+// and the button toggling its group panel.
 func (ui *UI) layoutChatBar(gtx C) D {
 	gtx.Constraints.Max.Y = ui.SearchHeight
 	gtx.Constraints.Min = gtx.Constraints.Max
+	room := ui.Rooms.Active()
+	typing := ui.composing(gtx, room)
+	if ui.GroupBtn.Clicked() {
+		ui.GroupPanel.Visible = !ui.GroupPanel.Visible
+	}
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Flexed(1, func(gtx C) D {
-			return layout.Center.Layout(gtx, func(gtx C) D {
-				room := ui.Rooms.Active()
-				return material.H5(th.Theme, room.Name).Layout(gtx)
-			})
+			return layout.Stack{Alignment: layout.E}.Layout(gtx,
+				layout.Expanded(func(gtx C) D {
+					return layout.Center.Layout(gtx, func(gtx C) D {
+						return ui.layoutChatTitle(gtx, room.DisplayName(), typing)
+					})
+				}),
+				layout.Stacked(func(gtx C) D {
+					btn := material.IconButton(th.Theme, &ui.GroupBtn, MoreHoriz, "Group info")
+					btn.Background = color.NRGBA{}
+					btn.Color = th.Fg
+					btn.Size = unit.Dp(20)
+					btn.Inset = layout.UniformInset(unit.Dp(8))
+					return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, btn.Layout)
+				}),
+			)
 		}),
 		// 分割线
 		layout.Rigid(v.NewSeparator(component.WithAlpha(th.Fg, 50)).Layout),
 	)
 }
 
+// layoutChatTitle lays out the name of a room above who is typing in it.
+func (ui *UI) layoutChatTitle(gtx C, name, typing string) D {
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(material.H5(th.Theme, name).Layout),
+		layout.Rigid(func(gtx C) D {
+			if typing == "" {
+				return D{}
+			}
+			lbl := material.Caption(th.Theme, typing)
+			lbl.Color = component.WithAlpha(th.Fg, 150)
+			return lbl.Layout(gtx)
+		}),
+	)
+}
+
 // layoutChat lays out the chat interface with associated controls.
 func (ui *UI) layoutChat(gtx C) D {
 	room := ui.Rooms.Active()
+	if room.Room == nil {
+		return D{Size: gtx.Constraints.Min}
+	}
 	var (
 		//scrollWidth unit.Dp
 		list  = &room.List
@@ -320,10 +585,12 @@ func (ui *UI) layoutChat(gtx C) D {
 	}.Layout(gtx,
 		layout.Rigid(ui.layoutChatBar),
 		layout.Flexed(1, func(gtx C) D {
-			return listStyle.Layout(gtx,
+			dims := listStyle.Layout(gtx,
 				state.UpdatedLen(&list.List),
 				state.Layout,
 			)
+			ui.markRead(gtx, room)
+			return dims
 		}),
 		layout.Rigid(func(gtx C) D {
 			return ui.layoutEditor2(gtx)
@@ -359,6 +626,17 @@ func (ui *UI) layoutChat(gtx C) D {
 // layoutRoomList lays out a list of rooms that can be clicked to view
 // the messages in that room.
 func (ui *UI) layoutRoomList(gtx C) D {
+	if target := ui.RoomMenuTarget; target != nil {
+		if ui.PinBtn.Clicked() {
+			target.Pinned = !target.Pinned
+			ui.saveRoomPrefs(target)
+		}
+		if ui.MuteBtn.Clicked() {
+			target.Muted = !target.Muted
+			ui.saveRoomPrefs(target)
+		}
+	}
+	active := ui.Rooms.Active()
 	return layout.Stack{}.Layout(
 		gtx,
 		layout.Expanded(func(gtx C) D {
@@ -377,43 +655,118 @@ func (ui *UI) layoutRoomList(gtx C) D {
 			listL.AnchorStrategy = material.Overlay
 			return listL.Layout(gtx, len(ui.Rooms.List), func(gtx C, ii int) D {
 				r := ui.Rooms.Index(ii)
+				if r.Interact.ContextArea.Active() {
+					ui.RoomMenuTarget = r
+					ui.RoomMenu.Options = ui.roomMenu(r)
+				}
 				latest := r.Latest()
-				return apptheme.Room(th.Theme, &r.Interact, &apptheme.RoomConfig{
-					Name:    r.Room.Name,
-					Image:   r.Room.Image,
-					Content: latest.Content,
-					SentAt:  latest.SentAt,
-				}).Layout(gtx)
+				conf := apptheme.RoomConfig{
+					Name:     r.DisplayName(),
+					Image:    r.Room.Image,
+					Content:  latest.Preview(),
+					SentAt:   latest.SentAt,
+					Unread:   r.Unread(),
+					Mentions: r.Mentions(),
+					Pinned:   r.Pinned,
+					Muted:    r.Muted,
+					Typing:   ui.composing(gtx, r),
+					Menu:     &ui.RoomMenu,
+				}
+				// 当前会话的输入框可见, 不显示草稿
+				if r != active {
+					conf.Draft = strings.TrimSpace(r.Editor.Text())
+				}
+				return apptheme.Room(th.Theme, &r.Interact, &conf).Layout(gtx)
 			})
 		}),
 	)
 }
-func (ui *UI) layoutSearchResult() layout.Widget {
-	searchContent := strings.TrimSpace(ui.SearchEditor.Text())
-	for !ui.SearchEditor.Focused() || len(searchContent) == 0 {
-		return nil
+
+// composing describes who is typing in room, empty if nobody is. The frame
+// is invalidated when the description expires.
+func (ui *UI) composing(gtx C, room *Room) string {
+	if room.Room == nil {
+		return ""
 	}
-	searchContent = strings.ToLower(searchContent)
-	var r Room
-	has := false
-	for _, roomInfo := range ui.Rooms.List {
-		if strings.ToLower(roomInfo.Name) == searchContent {
-			has = true
-			r = roomInfo
-			break
+	users, expiry := room.ComposingUsers(gtx.Now)
+	if !expiry.IsZero() {
+		op.InvalidateOp{At: expiry}.Add(gtx.Ops)
+	}
+	others := users[:0]
+	for _, u := range users {
+		if u != ui.Local.Name {
+			others = append(others, u)
 		}
 	}
-	if !has {
-		return layout.Spacer{}.Layout
+	users = others
+	switch len(users) {
+	case 0:
+		return ""
+	case 1:
+		return users[0] + " is typing…"
 	}
+	return "Several people are typing…"
+}
 
-	latest := r.Latest()
-	return apptheme.Room(th.Theme, &r.Interact, &apptheme.RoomConfig{
-		Name:    r.Room.Name,
-		Image:   r.Room.Image,
-		Content: latest.Content,
-		SentAt:  latest.SentAt,
-	}).Layout
+// dropLeftRooms removes the rooms the local user left, or was removed from,
+// from the room list.
+func (ui *UI) dropLeftRooms() {
+	for _, r := range append([]*Room(nil), ui.Rooms.List...) {
+		if !r.Group().Visible(ui.Local.Name) {
+			ui.Rooms.Remove(r)
+		}
+	}
+}
+
+// roomMenu returns the context menu options applicable to room.
+func (ui *UI) roomMenu(room *Room) []func(gtx C) D {
+	pin, mute := "Pin", "Mute"
+	if room.Pinned {
+		pin = "Unpin"
+	}
+	if room.Muted {
+		mute = "Unmute"
+	}
+	return []func(gtx C) D{
+		component.MenuItem(th.Theme, &ui.PinBtn, pin).Layout,
+		component.MenuItem(th.Theme, &ui.MuteBtn, mute).Layout,
+	}
+}
+
+// useDark presents the ui with the palette of the application theme, after
+// it switched to dark if dark is set, or to light otherwise.
+func (ui *UI) useDark(dark bool) {
+	ui.dark = dark
+	th.UsePalette(apptheme.NewPalette(theme.Current()))
+	ui.Bg = th.Palette.Bg
+	ui.AddContactBtn.ChangeColorStyle(&values.ColorStyle{Foreground: theme.Current().Icon, Background: th.Bg})
+}
+
+// saveRoomPrefs persists whether room is pinned and muted.
+func (ui *UI) saveRoomPrefs(room *Room) {
+	ui.RoomPrefs.Set(room.Name, prefs.Room{Pinned: room.Pinned, Muted: room.Muted})
+	go func() {
+		if err := ui.RoomPrefs.Save(); err != nil {
+			log.Printf("saving room preferences: %v", err)
+		}
+	}()
+}
+
+// markRead marks the messages of room laid out in its list as read, up to
+// the latest one in view.
+func (ui *UI) markRead(gtx C, room *Room) {
+	elems := room.ListState.ManagedElements(gtx)
+	pos := room.List.Position
+	last := pos.First + pos.Count - 1
+	if last >= len(elems) {
+		last = len(elems) - 1
+	}
+	for ii := last; ii >= pos.First; ii-- {
+		if msg, ok := elems[ii].(model.Message); ok {
+			room.MarkRead(msg.Serial())
+			return
+		}
+	}
 }
 
 // layoutSearch lays out the search editor.
@@ -431,7 +784,7 @@ func (ui *UI) layoutSearch(gtx C) D {
 		}.Layout(gtx, layout.Rigid(func(gtx C) D {
 			gtx.Constraints.Min.X = gtx.Constraints.Max.X / 4 * 3
 			return chatlayout.Rounded(unit.Dp(2)).Layout(gtx, func(gtx C) D {
-				return chatlayout.Background(values.Gray1).Layout(gtx, func(gtx C) D {
+				return chatlayout.Background(theme.Current().Input).Layout(gtx, func(gtx C) D {
 					return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
 						for _, e := range ui.SearchEditor.Events() {
 							switch e.(type) {
@@ -441,7 +794,6 @@ func (ui *UI) layoutSearch(gtx C) D {
 						}
 						ui.SearchEditor.Submit = true
 						ui.SearchEditor.SingleLine = true
-						ui.SearchEditor.MaxLen = 10
 						ed := material.Editor(th.Theme, ui.SearchEditor, "Search")
 						return ed.Layout(gtx)
 					})
@@ -470,8 +822,7 @@ func (ui *UI) layoutEditor(gtx C) D {
 				for _, e := range editor.Events() {
 					switch e.(type) {
 					case widget.SubmitEvent:
-						active.SendLocal(editor.Text())
-						editor.SetText("")
+						ui.submit(active)
 					}
 				}
 				editor.Submit = true
@@ -489,7 +840,7 @@ func (ui *UI) layoutModal(gtx C) D {
 	// NOTE(jfm): scrim should be dark regardless of theme.
 	// Perhaps "scrim color" could be specified on the theme.
 	t := *th.Theme
-	t.Fg = apptheme.Dark.Surface
+	t.Fg = values.DarkPalette.Surface
 	return component.Modal(&t, &ui.Modal).Layout(gtx)
 }
 
@@ -527,15 +878,6 @@ func synth(previous, row, next list.Element) []list.Element {
 	return out
 }
 
-// rowLessThan acts as a list.Comparator, returning whether a sorts before b.
-func rowLessThan(a, b list.Element) bool {
-	aID := string(a.Serial())
-	bID := string(b.Serial())
-	aAsInt, _ := strconv.Atoi(aID)
-	bAsInt, _ := strconv.Atoi(bID)
-	return aAsInt < bAsInt
-}
-
 // presentChatRow returns a widget closure that can layout the given chat item.
 // `data` contains managed data for this chat item, `state` contains UI defined
 // interactive state.
@@ -546,6 +888,16 @@ func (ui *UI) presentChatRow(data list.Element, state interface{}) layout.Widget
 		if !ok {
 			return func(C) D { return D{} }
 		}
+		if data.System {
+			return matchat.Notice(th.Theme, data.Content).Layout
+		}
+		if data.Recalled {
+			notice := data.Preview()
+			if data.Sender == ui.Local.Name {
+				notice = "You recalled a message"
+			}
+			return matchat.Notice(th.Theme, notice).Layout
+		}
 		return func(gtx C) D {
 			if state.Clicked() {
 				ui.Modal.Show(gtx.Now, func(gtx C) D {
@@ -558,11 +910,43 @@ func (ui *UI) presentChatRow(data list.Element, state interface{}) layout.Widget
 					})
 				})
 			}
+			if state.Retry.Clicked() && data.Status == model.StatusFailed {
+				ui.Rooms.Active().Retry(data)
+			}
+			for ii, r := range data.Reactions {
+				if ii < len(state.Reactions) && state.Reactions[ii].Clicked() {
+					ui.Rooms.Active().React(data.Serial(), r.Emoji, !r.Has(ui.Local.Name))
+				}
+			}
+			if state.Quote.Clicked() && data.ReplyTo != nil {
+				ui.Rooms.Active().JumpTo(data.ReplyTo.Serial())
+			}
+			if data.Voice != nil && data.Attachment != nil {
+				if state.Voice.Play.Clicked() {
+					ui.toggleVoice(data)
+				}
+				if pos, ok := state.Voice.Seeked(); ok {
+					ui.seekVoice(data, pos)
+				}
+			}
+			if state.Open.Clicked() && data.Attachment != nil {
+				a := *data.Attachment
+				go func() {
+					if err := openAttachment(a); err != nil {
+						log.Printf("%v", err)
+					}
+				}()
+			}
+			ui.handleLinks(gtx, &state.Message.InteractiveText)
 			if state.ContextArea.Active() {
 				// If the right-click context area for this message is activated,
 				// inform the UI that this message is the target of any action
 				// taken within that menu.
 				ui.ContextMenuTarget = &data
+				ui.MessageMenu.Options = ui.messageMenu(data, gtx.Now)
+			}
+			if active := ui.Rooms.Active(); active.Selected != nil {
+				return ui.layoutSelectable(gtx, active, data, state, ui.row(data, state))
 			}
 			return ui.row(data, state)(gtx)
 		}
@@ -575,6 +959,25 @@ func (ui *UI) presentChatRow(data list.Element, state interface{}) layout.Widget
 	}
 }
 
+// messageMenu returns the context menu options applicable to msg at now.
+// The local user is offered to edit and recall its messages for a while
+// after sending them.
+func (ui *UI) messageMenu(msg model.Message, now time.Time) []func(gtx C) D {
+	options := []func(gtx C) D{
+		component.MenuItem(th.Theme, &ui.ReplyBtn, "Reply").Layout,
+		component.MenuItem(th.Theme, &ui.ReactBtn, "React").Layout,
+		component.MenuItem(th.Theme, &ui.CopyBtn, "Copy").Layout,
+		component.MenuItem(th.Theme, &ui.SelectBtn, "Select").Layout,
+	}
+	if msg.CheckChange(ui.Local.Name, now, ui.conf.RecallWindow) == nil {
+		if msg.Attachment == nil {
+			options = append(options, component.MenuItem(th.Theme, &ui.EditBtn, "Edit").Layout)
+		}
+		options = append(options, component.MenuItem(th.Theme, &ui.RecallBtn, "Recall").Layout)
+	}
+	return append(options, component.MenuItem(th.Theme, &ui.DeleteBtn, "Delete").Layout)
+}
+
 // row returns either a plato.RowStyle or a chatmaterial.RowStyle based on the
 // provided boolean.
 func (ui *UI) row(data model.Message, state *chatwidget.Row) layout.Widget {
@@ -609,24 +1012,108 @@ func (ui *UI) row(data model.Message, state *chatwidget.Row) layout.Widget {
 			body = img
 		}
 	}
+	local := user.Name == ui.Local.Name
+	status := matchat.StatusNone
+	if local {
+		status = rowStatus(data.Status)
+	}
+	var (
+		file  *matchat.FileConfig
+		voice *matchat.VoiceConfig
+	)
+	switch a := data.Attachment; {
+	case a != nil && data.Voice != nil:
+		voice = ui.voiceConfig(data)
+	case a != nil && !a.IsImage():
+		file = &matchat.FileConfig{Name: a.Name, Size: a.Size}
+	}
+	var replyTo *matchat.QuoteConfig
+	if data.ReplyTo != nil {
+		replyTo = &matchat.QuoteConfig{
+			Sender:  data.ReplyTo.Sender,
+			Content: data.ReplyTo.Content,
+		}
+	}
+	reactions := make([]matchat.ReactionConfig, len(data.Reactions))
+	for ii, r := range data.Reactions {
+		reactions[ii] = matchat.ReactionConfig{
+			Emoji: r.Emoji,
+			Count: len(r.Users),
+			Mine:  r.Has(ui.Local.Name),
+		}
+	}
 	msg := matchat.NewRow(th.Theme, state, &ui.MessageMenu, matchat.RowConfig{
-		Sender:  data.Sender,
-		Content: data.Content,
-		SentAt:  data.SentAt,
-		Avatar:  avatar,
-		Image:   body,
-		Local:   user.Name == ui.Local.Name,
+		Sender:    data.Sender,
+		Content:   data.Content,
+		SentAt:    data.SentAt,
+		Avatar:    avatar,
+		Image:     body,
+		Local:     local,
+		Status:    status,
+		ReplyTo:   replyTo,
+		File:      file,
+		Voice:     voice,
+		Reactions: reactions,
+		Edited:    data.Edited(),
+		Mentions:  data.Mentions,
 	})
 	if np != nil {
 		msg.MessageStyle = msg.WithNinePatch(th.Theme, *np)
 	}
 	msg.MessageStyle.BubbleStyle.Color = user.Color
-	for i := range msg.Content.Styles {
-		msg.Content.Styles[i].Color = th.Contrast(matchat.Luminance(user.Color))
+	msg.MessageStyle = msg.WithTextColor(th.Contrast(matchat.Luminance(user.Color)))
+	msg.Edited.Color = component.WithAlpha(th.Contrast(matchat.Luminance(user.Color)), 180)
+	if msg.File != nil {
+		msg.File.Name.Color = th.Contrast(matchat.Luminance(user.Color))
+		msg.File.Size.Color = msg.File.Name.Color
+	}
+	if msg.Voice != nil {
+		msg.Voice.Time.Color = th.Contrast(matchat.Luminance(user.Color))
+		msg.Voice.Color = component.WithAlpha(msg.Voice.Time.Color, 90)
 	}
 	return msg.Layout
 }
 
+// handleLinks handles the interactions with the links of a message: clicks
+// open them, except phone numbers, which are copied, as are the links that
+// are long-pressed.
+func (ui *UI) handleLinks(gtx C, text *richtext.InteractiveText) {
+	for span, events := text.Events(); span != nil; span, events = text.Events() {
+		url, _ := span.Get(matchat.LinkKey).(string)
+		content, _ := span.Content()
+		for _, e := range events {
+			switch {
+			case e.Type == richtext.LongPress, e.Type == richtext.Click && strings.HasPrefix(url, "tel:"):
+				clipboard.WriteOp{Text: content}.Add(gtx.Ops)
+			case e.Type == richtext.Click && url != "":
+				go func() {
+					if err := openLink(url); err != nil {
+						log.Printf("%v", err)
+					}
+				}()
+			}
+		}
+	}
+}
+
+// rowStatus maps the delivery status of a message to the state its row
+// presents.
+func rowStatus(s model.Status) matchat.Status {
+	switch s {
+	case model.StatusPending:
+		return matchat.StatusPending
+	case model.StatusSent:
+		return matchat.StatusSent
+	case model.StatusDelivered:
+		return matchat.StatusDelivered
+	case model.StatusRead:
+		return matchat.StatusRead
+	case model.StatusFailed:
+		return matchat.StatusFailed
+	}
+	return matchat.StatusNone
+}
+
 var (
 	// placeholderColor to use for placeholder images.
 	placeholderColor = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
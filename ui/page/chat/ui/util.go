@@ -9,76 +9,30 @@ import (
 	_ "image/png"
 	"io"
 	"io/fs"
-	"io/ioutil"
-	"math/rand"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"strconv"
-	"wechat_ui/ui/pkg/list"
-
-	"gioui.org/app"
+	"runtime"
+	"strings"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
 )
 
-// randomImage returns a random image at the given size.
-// Downloads some number of random images from unplash and caches them on disk.
-//
-// TODO(jfm) [performance]: download images concurrently (parallel downloads,
-// async to the gui event loop).
-func randomImage(sz image.Point) (image.Image, error) {
-	mkCacheDir := func(base string) string {
-		return filepath.Join(base, "chat", fmt.Sprintf("%dx%d", sz.X, sz.Y))
-	}
-	cache := mkCacheDir(os.TempDir())
-	if err := os.MkdirAll(cache, 0755); err != nil {
-		if !errors.Is(err, os.ErrPermission) {
-			return nil, fmt.Errorf("preparing cache directory: %w", err)
-		}
-		dir, err := app.DataDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed finding application data dir: %w", err)
-		}
-		cache = mkCacheDir(dir)
-		if err := os.MkdirAll(cache, 0755); err != nil {
-			return nil, fmt.Errorf("preparing fallback cache directory: %w", err)
-		}
-	}
-	entries, err := ioutil.ReadDir(cache)
+// fetch image for the given id.
+// Image is initially downloaded from the provided url and stored on disk.
+// Images referred to by a file:// url are read in place.
+func fetch(id, u string) (image.Image, error) {
+	path, err := cacheResource(id, u)
 	if err != nil {
-		return nil, fmt.Errorf("reading cache entries: %w", err)
-	}
-	entries = filter(entries, isFile)
-	if len(entries) == 0 {
-		for ii := 0; ii < 10; ii++ {
-			ii := ii
-			if err := func() error {
-				r, err := http.Get(fmt.Sprintf("https://source.unsplash.com/random/%dx%d?nature", sz.X, sz.Y))
-				if err != nil {
-					return fmt.Errorf("fetching image data: %w", err)
-				}
-				defer r.Body.Close()
-				imgf, err := os.Create(filepath.Join(cache, strconv.Itoa(ii)))
-				if err != nil {
-					return fmt.Errorf("creating image file on disk: %w", err)
-				}
-				defer imgf.Close()
-				if _, err := io.Copy(imgf, r.Body); err != nil {
-					return fmt.Errorf("downloading image: %w", err)
-				}
-				return nil
-			}(); err != nil {
-				return nil, fmt.Errorf("populating image cache: %w", err)
-			}
-		}
-		return randomImage(sz)
+		return nil, err
 	}
-	selection := entries[rand.Intn(len(entries))]
-	imgf, err := os.Open(filepath.Join(cache, selection.Name()))
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("opening image file: %w", err)
+		return nil, fmt.Errorf("opening resource file: %w", err)
 	}
-	defer imgf.Close()
-	img, _, err := image.Decode(imgf)
+	defer f.Close()
+	img, _, err := image.Decode(f)
 	if err != nil {
 		return nil, fmt.Errorf("decoding image: %w", err)
 	}
@@ -91,97 +45,135 @@ func randomImage(sz image.Point) (image.Image, error) {
 	return dst, nil
 }
 
-// isFile filters out non-file entries.
-func isFile(info fs.FileInfo) bool {
-	return !info.IsDir()
-}
-
-func filter(list []fs.FileInfo, predicate func(fs.FileInfo) bool) (filtered []fs.FileInfo) {
-	for _, item := range list {
-		if predicate(item) {
-			filtered = append(filtered, item)
+// openAttachment opens the attached file with the default application of
+// the system. Files that are not local are first saved to the downloads
+// directory of the user.
+func openAttachment(a model.Attachment) error {
+	path, ok := backend.LocalPath(a.URL)
+	if !ok {
+		var err error
+		if path, err = download(a); err != nil {
+			return fmt.Errorf("opening attachment: %w", err)
 		}
 	}
-	return filtered
+	if err := systemOpen(path); err != nil {
+		return fmt.Errorf("opening attachment: %w", err)
+	}
+	return nil
 }
 
-// dupSlice returns a slice composed of the same elements in the same order,
-// but backed by a different array.
-func dupSlice(in []list.Element) []list.Element {
-	out := make([]list.Element, len(in))
-	for i := range in {
-		out[i] = in[i]
-	}
-	return out
+// openLink opens the URL of a link with the default application of the
+// system, such as the web browser or the mail client.
+func openLink(url string) error {
+	if err := systemOpen(url); err != nil {
+		return fmt.Errorf("opening link: %w", err)
+	}
+	return nil
 }
 
-// sliceRemove takes the given index of a slice and swaps it with the final
-// index in the slice, then shortens the slice by one element. This hides
-// the element at index from the slice, though it does not erase its data.
-func sliceRemove(s *[]list.Element, index int) {
-	lastIndex := len(*s) - 1
-	(*s)[index], (*s)[lastIndex] = (*s)[lastIndex], (*s)[index]
-	*s = (*s)[:lastIndex]
+// systemOpen opens a file or URL with the default application of the
+// system for it.
+func systemOpen(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	case "darwin":
+		cmd = exec.Command("open", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
 }
 
-func maximum(a, b int) int {
-	if a > b {
-		return a
+// download saves the attached file to the downloads directory of the
+// user, returning its path. Existing files are not overwritten.
+func download(a model.Attachment) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating downloads: %w", err)
+	}
+	dir := filepath.Join(home, "Downloads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("preparing downloads: %w", err)
+	}
+	r, err := http.Get(a.URL)
+	if err != nil {
+		return "", fmt.Errorf("GET: %w", err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET: %s", r.Status)
+	}
+	name := filepath.Base(a.Name)
+	if name == "." || name == string(filepath.Separator) {
+		name = "download"
+	}
+	ext := filepath.Ext(name)
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	for ii := 1; errors.Is(err, fs.ErrExist); ii++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), ii, ext))
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	}
-	return b
+	if err != nil {
+		return "", fmt.Errorf("creating download: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r.Body); err != nil {
+		return "", fmt.Errorf("downloading %s: %w", a.Name, err)
+	}
+	return path, nil
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// resourcePath returns the path the resource at u is stored at on disk,
+// under id unless u is a file:// url.
+func resourcePath(id, u string) string {
+	if path, local := backend.LocalPath(u); local {
+		return path
 	}
-	return b
+	return filepath.Join(CacheDir(), id)
 }
 
-// fetch image for the given id.
-// Image is initially downloaded from the provided url and stored on disk.
-func fetch(id, u string) (image.Image, error) {
-	path := filepath.Join(os.TempDir(), "chat", "resources", id)
+// CacheDir returns the directory the resources of messages are downloaded
+// to. Its contents can be removed at any time, to be downloaded again.
+func CacheDir() string {
+	return filepath.Join(os.TempDir(), "chat", "resources")
+}
+
+// cacheResource downloads the resource at u to disk, under id, unless it
+// already is, returning its path. Resources referred to by a file:// url
+// are used in place.
+func cacheResource(id, u string) (string, error) {
+	path := resourcePath(id, u)
+	if _, local := backend.LocalPath(u); local {
+		return path, nil
+	}
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return nil, fmt.Errorf("preparing resource directory: %w", err)
-	}
-	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
-		if err := func() error {
-			f, err := os.Create(path)
-			if err != nil {
-				return fmt.Errorf("creating resource file: %w", err)
-			}
-			defer f.Close()
-			r, err := http.Get(u)
-			if err != nil {
-				return fmt.Errorf("GET: %w", err)
-			}
-			defer r.Body.Close()
-			if r.StatusCode != http.StatusOK {
-				return fmt.Errorf("GET: %s", r.Status)
-			}
-			if _, err := io.Copy(f, r.Body); err != nil {
-				return fmt.Errorf("downloading resource to disk: %w", err)
-			}
-			return nil
-		}(); err != nil {
-			return nil, err
-		}
+		return "", fmt.Errorf("preparing resource directory: %w", err)
 	}
-	f, err := os.Open(path)
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	f, err := os.Create(path)
 	if err != nil {
-		return nil, fmt.Errorf("opening resource file: %w", err)
+		return "", fmt.Errorf("creating resource file: %w", err)
 	}
 	defer f.Close()
-	img, _, err := image.Decode(f)
+	r, err := http.Get(u)
 	if err != nil {
-		return nil, fmt.Errorf("decoding image: %w", err)
+		return "", fmt.Errorf("GET: %w", err)
 	}
-	// Copy the image into a GPU friendly format.
-	dst := image.NewRGBA(image.Rectangle{
-		Max: img.Bounds().Size(),
-	})
-	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
-
-	return dst, nil
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET: %s", r.Status)
+	}
+	if _, err := io.Copy(f, r.Body); err != nil {
+		return "", fmt.Errorf("downloading resource to disk: %w", err)
+	}
+	return path, nil
 }
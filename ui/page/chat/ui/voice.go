@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"image"
+	"log"
+	"sync/atomic"
+	"time"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/v"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+
+	matchat "wechat_ui/ui/pkg/widget/material"
+)
+
+// VoiceRefresh is how often the frame is refreshed while recording or
+// playing voice messages, to present their progress.
+const VoiceRefresh = time.Second / 20
+
+// VoiceBar holds the state of the bar recording a voice message, which
+// replaces the editor of the room it is recorded for.
+type VoiceBar struct {
+	// Send and Cancel track clicks on the buttons sending and discarding
+	// the recording.
+	Send, Cancel widget.Clickable
+	// room the voice message is recorded for, nil when not recording. It
+	// is only accessed while laying out.
+	room *Room
+	// saving reports that a recording is being saved, until which no
+	// other recording can start.
+	saving atomic.Bool
+}
+
+// startRecording starts recording a voice message for room, unless one is
+// being recorded.
+func (ui *UI) startRecording(room *Room) {
+	if ui.VoiceBar.room != nil || ui.VoiceBar.saving.Load() {
+		return
+	}
+	if err := ui.Recorder.Start(); err != nil {
+		log.Printf("recording voice message: %v", err)
+		return
+	}
+	ui.VoiceBar.room = room
+}
+
+// stopRecording stops recording the voice message, and sends it if send is
+// set, as a reply to the message the room replies to, if any. Recordings
+// shorter than model.MinVoiceDuration are discarded.
+func (ui *UI) stopRecording(send bool) {
+	bar := &ui.VoiceBar
+	room := bar.room
+	if room == nil {
+		return
+	}
+	bar.room = nil
+	if !send || ui.Recorder.Elapsed() < model.MinVoiceDuration {
+		ui.Recorder.Cancel()
+		return
+	}
+	replyTo := room.ReplyTo
+	room.ReplyTo = nil
+	bar.saving.Store(true)
+	go func() {
+		defer bar.saving.Store(false)
+		rec, err := ui.Recorder.Stop()
+		if err != nil {
+			log.Printf("recording voice message: %v", err)
+			return
+		}
+		a, err := backend.DescribeFile(rec.Path)
+		if err != nil {
+			log.Printf("recording voice message: %v", err)
+			return
+		}
+		room.SendVoice(a, model.Voice{Duration: rec.Duration, Waveform: rec.Waveform}, replyTo)
+	}()
+}
+
+// layoutVoiceBar lays out the bar replacing the editor while recording a
+// voice message: how long the recording lasts, the level of the input,
+// and the buttons sending and discarding it. Recording stops when it
+// reaches model.MaxVoiceDuration, and the recording is sent.
+func (ui *UI) layoutVoiceBar(gtx C) D {
+	bar := &ui.VoiceBar
+	if bar.Cancel.Clicked() {
+		ui.stopRecording(false)
+	}
+	elapsed := ui.Recorder.Elapsed()
+	if bar.Send.Clicked() || elapsed >= model.MaxVoiceDuration {
+		ui.stopRecording(true)
+	}
+	op.InvalidateOp{At: gtx.Now.Add(VoiceRefresh)}.Add(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(v.NewSeparator(component.WithAlpha(th.Fg, 50)).Layout),
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						size := gtx.Dp(unit.Dp(10))
+						paint.FillShape(gtx.Ops, matchat.DefaultDangerColor, clip.Ellipse{Max: image.Pt(size, size)}.Op(gtx.Ops))
+						return D{Size: image.Pt(size, size)}
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Body1(th.Theme, matchat.FormatDuration(elapsed)+" / "+matchat.FormatDuration(model.MaxVoiceDuration)).Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(12)}.Layout),
+					layout.Rigid(func(gtx C) D {
+						return layoutLevel(gtx, ui.Recorder.Level())
+					}),
+					layout.Flexed(1, func(gtx C) D {
+						return D{Size: gtx.Constraints.Min}
+					}),
+					layout.Rigid(func(gtx C) D {
+						btn := material.Button(th.Theme, &bar.Cancel, "Cancel")
+						btn.Background = th.Palette.Surface
+						btn.Color = th.Fg
+						return btn.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(th.Theme, &bar.Send, "Send").Layout),
+				)
+			})
+		}),
+	)
+}
+
+// layoutLevel lays out a meter of the level of the input, from 0 to 1.
+func layoutLevel(gtx C, level float32) D {
+	size := image.Pt(gtx.Dp(unit.Dp(80)), gtx.Dp(unit.Dp(6)))
+	track := clip.UniformRRect(image.Rectangle{Max: size}, size.Y/2)
+	paint.FillShape(gtx.Ops, component.WithAlpha(th.Fg, 40), track.Op(gtx.Ops))
+	filled := image.Rectangle{Max: image.Pt(int(level*float32(size.X)), size.Y)}
+	paint.FillShape(gtx.Ops, th.ContrastBg, clip.UniformRRect(filled, size.Y/2).Op(gtx.Ops))
+	return D{Size: size}
+}
+
+// voicePath returns the path the audio of the voice message msg is played
+// from.
+func voicePath(msg model.Message) string {
+	return resourcePath(string(msg.Serial())+"-voice", msg.Attachment.URL)
+}
+
+// toggleVoice plays the voice message msg, resuming where it was paused, or
+// pauses it if it is being played.
+func (ui *UI) toggleVoice(msg model.Message) {
+	st := ui.playback
+	if st.Path != voicePath(msg) {
+		ui.playVoice(msg, 0)
+		return
+	}
+	if st.Playing {
+		ui.Player.Pause()
+		return
+	}
+	offset := st.Position
+	if st.Ended() {
+		offset = 0
+	}
+	ui.playVoice(msg, offset)
+}
+
+// seekVoice moves the playback of the voice message msg to pos, from 0 to
+// 1, playing it from there if another message is being played.
+func (ui *UI) seekVoice(msg model.Message, pos float32) {
+	offset := time.Duration(pos * float32(msg.Voice.Duration))
+	if ui.playback.Path == voicePath(msg) {
+		ui.Player.Seek(offset)
+		return
+	}
+	ui.playVoice(msg, offset)
+}
+
+// playVoice plays the voice message msg from offset, once its audio is
+// downloaded.
+func (ui *UI) playVoice(msg model.Message, offset time.Duration) {
+	id, url := string(msg.Serial())+"-voice", msg.Attachment.URL
+	go func() {
+		path, err := cacheResource(id, url)
+		if err == nil {
+			err = ui.Player.Play(path, offset)
+		}
+		if err != nil {
+			log.Printf("playing voice message: %v", err)
+			return
+		}
+		ui.invalidate()
+	}()
+}
+
+// voiceConfig returns the presentation of the voice message msg, along
+// with its playback if the player is playing it.
+func (ui *UI) voiceConfig(msg model.Message) *matchat.VoiceConfig {
+	conf := &matchat.VoiceConfig{
+		Duration: msg.Voice.Duration,
+		Waveform: msg.Voice.Waveform,
+	}
+	if st := ui.playback; st.Path == voicePath(msg) && !st.Ended() {
+		conf.Playing = st.Playing
+		conf.Position = st.Position
+	}
+	return conf
+}
+
+// updatePlayback records the state of the player for the frame, which is
+// refreshed while the player plays.
+func (ui *UI) updatePlayback(gtx C) {
+	ui.playback = ui.Player.Status()
+	if ui.playback.Playing {
+		op.InvalidateOp{At: gtx.Now.Add(VoiceRefresh)}.Add(gtx.Ops)
+	}
+}
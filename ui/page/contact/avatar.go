@@ -0,0 +1,86 @@
+package contact
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"time"
+	"wechat_ui/ui/assets"
+	"wechat_ui/ui/page/chat/model"
+)
+
+// avatarClient 下载头像使用的 http 客户端.
+var avatarClient = &http.Client{Timeout: 10 * time.Second}
+
+// placeholder 头像加载完成之前使用的纯色图片, 颜色和用户的消息气泡一致.
+func placeholder(u *model.User) image.Image {
+	c := u.Color
+	if c.A == 0 {
+		c = color.NRGBA{R: 50, G: 50, B: 50, A: 255}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// loadAvatars 在后台下载所有联系人的头像, 每下载完一个就刷新窗口.
+func (p *Page) loadAvatars() {
+	for _, e := range p.entries {
+		c := e.contact
+		if c == nil || c.user.Avatar == "" {
+			continue
+		}
+		go func() {
+			img, err := fetchAvatar(c.user.Avatar)
+			if err != nil {
+				log.Printf("loading avatar of %s: %v", c.user.Name, err)
+				return
+			}
+			c.setAvatar(img)
+			if assets.Window != nil {
+				assets.Window.Invalidate()
+			}
+		}()
+	}
+}
+
+// fetchAvatar 下载并解码 url 指向的图片.
+func fetchAvatar(url string) (image.Image, error) {
+	r, err := avatarClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("GET: %w", err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET: %s", r.Status)
+	}
+	img, _, err := image.Decode(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	return img, nil
+}
+
+// setAvatar 替换联系人的头像.
+func (c *contact) setAvatar(img image.Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.avatar = img
+	c.changed = true
+}
+
+// getAvatar 返回联系人当前的头像. 头像被替换后会让界面重新缓存图片, 因此只能在布局的 goroutine 中调用.
+func (c *contact) getAvatar() image.Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed {
+		c.row.Image.Reload()
+		c.changed = false
+	}
+	return c.avatar
+}
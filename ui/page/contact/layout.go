@@ -1,13 +1,103 @@
 package contact
 
 import (
+	"wechat_ui/ui/assets"
+	"wechat_ui/ui/page/chat/appwidget/apptheme"
+	"wechat_ui/ui/theme"
+	"wechat_ui/ui/v"
+	"wechat_ui/ui/values"
+
 	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
 	"gioui.org/widget/material"
-	"wechat_ui/ui/assets"
+	"gioui.org/x/component"
+	matchat "wechat_ui/ui/pkg/widget/material"
+)
+
+type (
+	C = layout.Context
+	D = layout.Dimensions
 )
 
-func (p *Page) Layout(gtx layout.Context) layout.Dimensions {
-	gtx.Constraints.Min.X = gtx.Constraints.Max.X
-	gtx.Constraints.Min.Y = gtx.Constraints.Max.Y
-	return layout.Center.Layout(gtx, material.Body2(assets.Theme, "通讯录").Layout)
+// ListWidth 联系人列表的宽度.
+var ListWidth = unit.Dp(250)
+
+func (p *Page) Layout(gtx C) D {
+	gtx.Constraints.Min = gtx.Constraints.Max
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Max.X = gtx.Dp(ListWidth)
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return p.layoutList(gtx)
+		}),
+		layout.Rigid(v.SeparatorVertical(gtx.Constraints.Max.Y, 1, component.WithAlpha(assets.Theme.Fg, 50)).Layout),
+		layout.Flexed(1, p.layoutDetail),
+	)
+}
+
+// layoutList 分组的联系人列表.
+func (p *Page) layoutList(gtx C) D {
+	if len(p.entries) == 0 {
+		return layout.Center.Layout(gtx, material.Body2(assets.Theme, "暂无联系人").Layout)
+	}
+	return material.List(assets.Theme, &p.list).Layout(gtx, len(p.entries), func(gtx C, ii int) D {
+		e := p.entries[ii]
+		if e.contact == nil {
+			return layout.Inset{
+				Top:    values.MarginPadding10,
+				Bottom: values.MarginPadding4,
+				Left:   values.MarginPadding8,
+			}.Layout(gtx, func(gtx C) D {
+				lbl := material.Label(assets.Theme, values.TextSize12, string(e.group))
+				lbl.Color = theme.Current().Hint
+				return lbl.Layout(gtx)
+			})
+		}
+		c := e.contact
+		row := apptheme.Room(assets.Theme, &c.row, &apptheme.RoomConfig{
+			Name:    c.user.Name,
+			Image:   c.getAvatar(),
+			Content: c.user.Notes,
+		})
+		row.TimeStamp.Text = ""
+		return row.Layout(gtx)
+	})
+}
+
+// layoutDetail 选中联系人的详情: 头像, 名字, 备注以及发消息按钮.
+func (p *Page) layoutDetail(gtx C) D {
+	c := p.selected
+	if c == nil {
+		return layout.Center.Layout(gtx, material.Body2(assets.Theme, "通讯录").Layout)
+	}
+	notes := c.user.Notes
+	if notes == "" {
+		notes = "暂无备注"
+	}
+	return layout.Center.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return matchat.Image{
+					Image: widget.Image{
+						Src: c.row.Image.Cache(c.getAvatar()).Op(),
+						Fit: widget.Contain,
+					},
+					Radii:  unit.Dp(4),
+					Width:  unit.Dp(80),
+					Height: unit.Dp(80),
+				}.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: values.MarginPadding12}.Layout),
+			layout.Rigid(material.H6(assets.Theme, c.user.Name).Layout),
+			layout.Rigid(layout.Spacer{Height: values.MarginPadding8}.Layout),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(assets.Theme, notes)
+				lbl.Color = theme.Current().Hint
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: values.MarginPadding20}.Layout),
+			layout.Rigid(p.sendBtn.Layout),
+		)
+	})
 }
@@ -1,29 +1,106 @@
 package contact
 
 import (
+	"image"
+	"sort"
+	"sync"
 	"wechat_ui/app"
+	"wechat_ui/ui/page/chat/appwidget"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/pkg/pinyin"
+	"wechat_ui/ui/v"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
 )
 
 const PageID = "contact"
 
+// Page 通讯录页面, 按照首字母 (中文名按拼音首字母) 分组展示联系人, 并在右侧展示选中联系人的详情.
 type Page struct {
 	*app.GenericPageModal
+
+	// entries 是分组后的联系人列表, 每组以一个组标题开头.
+	entries  []entry
+	list     widget.List
+	selected *contact
+	sendBtn  v.Button
+	// sendMessage 打开或者创建与指定用户的会话.
+	sendMessage func(name string)
+	// fetchAvatars 保证头像只下载一次.
+	fetchAvatars sync.Once
+}
+
+// entry 是联系人列表中的一行, 要么是组标题, 要么是联系人.
+type entry struct {
+	group   rune
+	contact *contact
+}
+
+// contact 联系人及其界面状态.
+type contact struct {
+	user *model.User
+	row  appwidget.Room
+
+	mu     sync.Mutex
+	avatar image.Image
+	// changed 头像被替换后还没有重新缓存.
+	changed bool
 }
 
 func (p *Page) OnNavigatedTo() {
+	p.fetchAvatars.Do(p.loadAvatars)
 }
 
 func (p *Page) OnNavigatedFrom() {
 }
 
-func NewPage() *Page {
+// NewPage 创建展示 users 中除 local 之外所有用户的通讯录页面.
+// 点击 "发消息" 时以联系人的名字调用 sendMessage.
+func NewPage(users *model.Users, local *model.User, sendMessage func(name string)) *Page {
 	page := &Page{
 		GenericPageModal: app.NewGenericPageModal(PageID),
+		sendBtn:          v.NewButton("发消息"),
+		sendMessage:      sendMessage,
+	}
+	page.list.Axis = layout.Vertical
+
+	var contacts []*contact
+	for _, u := range users.List() {
+		if local != nil && u.Name == local.Name {
+			continue
+		}
+		contacts = append(contacts, &contact{
+			user:   u,
+			avatar: placeholder(u),
+		})
+	}
+	sort.Slice(contacts, func(ii, jj int) bool {
+		return pinyin.Less(contacts[ii].user.Name, contacts[jj].user.Name)
+	})
+	for ii, c := range contacts {
+		group := pinyin.Group(c.user.Name)
+		if ii == 0 || group != pinyin.Group(contacts[ii-1].user.Name) {
+			page.entries = append(page.entries, entry{group: group})
+		}
+		page.entries = append(page.entries, entry{contact: c})
 	}
 
 	return page
 }
 
 func (p *Page) HandleUserInteractions() {
-
+	for _, e := range p.entries {
+		if e.contact != nil && e.contact.row.Clicked() {
+			p.selected = e.contact
+		}
+	}
+	for _, e := range p.entries {
+		if e.contact != nil {
+			e.contact.row.Active = e.contact == p.selected
+		}
+	}
+	if p.sendBtn.Clicked() && p.selected != nil && p.sendMessage != nil {
+		p.sendMessage(p.selected.user.Name)
+	}
 }
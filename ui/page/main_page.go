@@ -3,6 +3,11 @@ package page
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	giouiApp "gioui.org/app"
 	"gioui.org/io/key"
 	"gioui.org/layout"
 	"gioui.org/unit"
@@ -10,8 +15,13 @@ import (
 	"wechat_ui/app"
 	"wechat_ui/ui/components"
 	"wechat_ui/ui/page/chat"
+	"wechat_ui/ui/page/chat/backend"
+	"wechat_ui/ui/page/chat/prefs"
+	"wechat_ui/ui/page/chat/protocol"
 	"wechat_ui/ui/page/contact"
+	"wechat_ui/ui/page/settings"
 	"wechat_ui/ui/page/start"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/v"
 )
 
@@ -42,18 +52,101 @@ type MainPage struct {
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 	drawerNav components.NavDrawer
+
+	// backend is the source of rooms and messages shared by all child pages.
+	backend backend.Backend
+	// chatPage is created along with the main page and kept across
+	// navigations so that its list state and backend subscription survive
+	// switching pages.
+	chatPage *chat.Page
+	// contactPage is kept across navigations for the same reason.
+	contactPage *contact.Page
+	// settings are the settings of the local user, shared by the chat and
+	// settings pages.
+	settings *prefs.Settings
+	// settingsPage is kept across navigations for the same reason as
+	// chatPage.
+	settingsPage *settings.Page
+	// toasts present the messages received by the chat page above every
+	// page. Clicking one opens its room.
+	toasts *components.Toasts
 }
 
-func NewMainPage() *MainPage {
+func NewMainPage(toasts *components.Toasts) *MainPage {
 	mp := &MainPage{
 		MasterPage: app.NewMasterPage(MainPageID),
+		backend:    newBackend(),
+		toasts:     toasts,
 	}
+	toasts.Open = mp.openChat
+	mp.settings = openSettings(mp.backend.Local().Name)
+	mp.applyAppearance()
+	// 聊天页面在启动时创建, 使未读数和消息通知在打开它之前就可用.
+	mp.chatPage = chat.NewPage(mp.backend, mp.settings, mp.toasts)
 
 	mp.initNavItems()
 
 	return mp
 }
 
+// newBackend connects to the chat server named by the WECHAT_UI_SERVER
+// environment variable, if any. Otherwise it opens the demo backend
+// persisted in the application data directory, falling back to an
+// in-memory one if that fails.
+func newBackend() backend.Backend {
+	if url := os.Getenv("WECHAT_UI_SERVER"); url != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), protocol.DefaultTimeout)
+		defer cancel()
+		c, err := protocol.Dial(ctx, protocol.Config{
+			URL:      url,
+			User:     os.Getenv("WECHAT_UI_USER"),
+			Token:    os.Getenv("WECHAT_UI_TOKEN"),
+			LoadSize: 30,
+		})
+		if err == nil {
+			return c
+		}
+		log.Printf("connecting to chat server, falling back to demo: %v", err)
+	}
+	conf := backend.DemoConfig{
+		Latency:     1000,
+		LoadSize:    30,
+		FailureRate: 0.1,
+	}
+	if dir, err := giouiApp.DataDir(); err != nil {
+		log.Printf("finding application data dir: %v", err)
+	} else {
+		conf.StorePath = filepath.Join(dir, "wechat_ui", "messages.log")
+	}
+	b, err := backend.NewDemo(conf)
+	if err == nil {
+		return b
+	}
+	log.Printf("opening persistent backend: %v", err)
+	conf.StorePath = ""
+	b, err = backend.NewDemo(conf)
+	if err != nil {
+		panic(fmt.Errorf("opening in-memory backend: %w", err))
+	}
+	return b
+}
+
+// openSettings opens the settings of the named user, persisted in the
+// application data directory. They are kept in memory only if that fails.
+func openSettings(user string) *prefs.Settings {
+	var dir string
+	if d, err := giouiApp.DataDir(); err != nil {
+		log.Printf("finding application data dir: %v", err)
+	} else {
+		dir = filepath.Join(d, "wechat_ui")
+	}
+	s, err := prefs.OpenSettings(dir, user)
+	if err != nil {
+		log.Printf("opening settings: %v", err)
+	}
+	return s
+}
+
 // ID is a unique string that identifies the page and may be used
 // to differentiate this page from other pages.
 // Part of the load.Page interface.
@@ -69,6 +162,7 @@ func (mp *MainPage) initNavItems() {
 			ImageInactive: v.MsgIconInactive,
 			Title:         "消息",
 			PageID:        chat.PageID,
+			Badge:         mp.unread,
 		},
 		{
 			Clickable:     v.NewClickable(false),
@@ -94,6 +188,7 @@ func (mp *MainPage) initNavItems() {
 			Clickable:     v.NewClickable(false),
 			ImageInactive: v.MoreInactive,
 			Title:         "更多",
+			PageID:        settings.PageID,
 		},
 	}
 	mp.drawerNav = components.NewNavDrawer(mp.CurrentPageID(), navItems, utilItems)
@@ -126,45 +221,100 @@ func (mp *MainPage) HandleUserInteractions() {
 	}
 
 	mp.drawerNav.CurrentPage = mp.CurrentPageID()
+	mp.applyAppearance()
 
 	// 加载左侧导航栏
 	for _, item := range mp.drawerNav.DrawerNavItems {
 		for item.Clickable.Clicked() {
-			var pg app.Page
-			switch item.PageID {
-			case contact.PageID:
-				pg = contact.NewPage()
-			case chat.PageID:
-				pg = chat.NewPage()
-			}
-
-			if pg == nil || mp.ID() == mp.CurrentPageID() {
-				continue
-			}
-			mp.Display(pg)
+			mp.navigateTo(item.PageID)
 		}
 	}
 	// 加载左侧工具栏
 	for _, item := range mp.drawerNav.DrawerUtilItems {
 		for item.Clickable.Clicked() {
-			fmt.Println("点击工具栏:", item.Title)
+			if item.PageID == "" {
+				fmt.Println("点击工具栏:", item.Title)
+				continue
+			}
+			mp.navigateTo(item.PageID)
 		}
 	}
 }
 
-// KeysToHandle 监听的键盘事件
-func (mp *MainPage) KeysToHandle() key.Set {
-	if currentPage := mp.CurrentPage(); currentPage != nil {
+// applyAppearance 使应用的主题跟随设置中的外观.
+func (mp *MainPage) applyAppearance() {
+	switch mp.settings.Get().Appearance {
+	case prefs.AppearanceDark:
+		theme.SetMode(theme.Dark)
+	case prefs.AppearanceSystem:
+		theme.SetMode(theme.System)
+	default:
+		theme.SetMode(theme.Light)
+	}
+}
+
+// navigateTo 显示左侧导航栏中指定 ID 的页面.
+func (mp *MainPage) navigateTo(pageID string) {
+	var pg app.Page
+	switch pageID {
+	case contact.PageID:
+		if mp.contactPage == nil {
+			mp.contactPage = contact.NewPage(mp.backend.Users(), mp.backend.Local(), mp.openChat)
+		}
+		pg = mp.contactPage
+	case chat.PageID:
+		pg = mp.chatPage
+	case settings.PageID:
+		if mp.settingsPage == nil {
+			mp.settingsPage = settings.NewPage(mp.settings, mp.backend.Local(), mp.server())
+		}
+		pg = mp.settingsPage
+	}
 
+	if pg == nil || mp.ID() == mp.CurrentPageID() {
+		return
+	}
+	mp.Display(pg)
+}
+
+// server 返回连接的聊天服务器, 使用演示后端时为空.
+func (mp *MainPage) server() string {
+	if _, ok := mp.backend.(*protocol.Client); ok {
+		return os.Getenv("WECHAT_UI_SERVER")
 	}
 	return ""
 }
 
+// unread 返回所有会话中未读消息的总数, 由聊天页面统计, 不会阻塞布局.
+func (mp *MainPage) unread() int {
+	return mp.chatPage.Unread()
+}
+
+// openChat displays the chat page with the room of the named contact
+// active.
+func (mp *MainPage) openChat(name string) {
+	mp.chatPage.OpenRoom(name)
+	mp.Display(mp.chatPage)
+}
+
+// navKeys 切换左侧导航栏页面的快捷键, Short-1 对应第一个导航项, 以此类推.
+const navKeys = "Short-[1,2]"
+
+// KeysToHandle 监听的键盘事件, 包括导航快捷键和当前子页面监听的按键.
+func (mp *MainPage) KeysToHandle() key.Set {
+	return app.CombineKeys(navKeys, mp.MasterPage.KeysToHandle())
+}
+
 // HandleKeyPress 处理键盘事件.
 func (mp *MainPage) HandleKeyPress(evt *key.Event) {
-	if currentPage := mp.CurrentPage(); currentPage != nil {
-
+	if key.Set(navKeys).Contains(evt.Name, evt.Modifiers) {
+		index := int(evt.Name[0] - '1')
+		if index < len(mp.drawerNav.DrawerNavItems) {
+			mp.navigateTo(mp.drawerNav.DrawerNavItems[index].PageID)
+		}
+		return
 	}
+	mp.MasterPage.HandleKeyPress(evt)
 }
 
 // OnNavigatedFrom is called when the page is about to be removed from
@@ -183,6 +333,14 @@ func (mp *MainPage) OnNavigatedFrom() {
 	mp.ctxCancel()
 }
 
+// OnClosed is called when the page is removed from the window for good.
+// Part of the app.Closable interface.
+func (mp *MainPage) OnClosed() {
+	if err := mp.backend.Close(); err != nil {
+		log.Printf("closing backend: %v", err)
+	}
+}
+
 // Layout draws the page UI components into the provided layout context
 // to be eventually drawn on screen.
 // Part of the load.Page interface.
@@ -0,0 +1,201 @@
+package settings
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"wechat_ui/ui/assets"
+	"wechat_ui/ui/page/chat/prefs"
+	"wechat_ui/ui/theme"
+	"wechat_ui/ui/v"
+	"wechat_ui/ui/values"
+
+	"gioui.org/font"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+
+	matchat "wechat_ui/ui/pkg/widget/material"
+)
+
+type (
+	C = layout.Context
+	D = layout.Dimensions
+)
+
+// ContentWidth is the maximum width of the settings.
+var ContentWidth = unit.Dp(560)
+
+func (p *Page) Layout(gtx C) D {
+	gtx.Constraints.Min = gtx.Constraints.Max
+	th := assets.Theme
+	sections := []func(gtx C, th *material.Theme) D{
+		p.layoutAccount,
+		p.layoutNotifications,
+		p.layoutAppearance,
+		p.layoutSendKey,
+		p.layoutStorage,
+	}
+	return material.List(th, &p.list).Layout(gtx, len(sections)+1, func(gtx C, ii int) D {
+		return layout.N.Layout(gtx, func(gtx C) D {
+			if width := gtx.Dp(ContentWidth); width < gtx.Constraints.Max.X {
+				gtx.Constraints.Max.X = width
+			}
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.UniformInset(values.MarginPadding16).Layout(gtx, func(gtx C) D {
+				if ii == 0 {
+					return material.H5(th, "设置").Layout(gtx)
+				}
+				card := v.Card{Radius: v.Radius(8)}
+				return card.Layout(gtx, func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					return layout.UniformInset(values.MarginPadding16).Layout(gtx, func(gtx C) D {
+						return sections[ii-1](gtx, th)
+					})
+				})
+			})
+		})
+	})
+}
+
+// layoutSection lays out a group of settings below its title.
+func layoutSection(gtx C, th *material.Theme, title string, rows ...layout.Widget) D {
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			lbl := material.Subtitle1(th, title)
+			lbl.Font.Weight = font.Bold
+			return layout.Inset{Bottom: values.MarginPadding8}.Layout(gtx, lbl.Layout)
+		}),
+	}
+	for _, row := range rows {
+		children = append(children, layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: values.MarginPadding4, Bottom: values.MarginPadding4}.Layout(gtx, row)
+		}))
+	}
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// layoutRow lays out label on the left and control on the right.
+func layoutRow(gtx C, th *material.Theme, label string, control layout.Widget) D {
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Flexed(1, material.Body1(th, label).Layout),
+		layout.Rigid(control),
+	)
+}
+
+// hint lays out secondary text.
+func hint(th *material.Theme, text string) layout.Widget {
+	lbl := material.Body2(th, text)
+	lbl.Color = theme.Current().Hint
+	return lbl.Layout
+}
+
+func (p *Page) layoutAccount(gtx C, th *material.Theme) D {
+	name, server := "", p.server
+	if p.user != nil {
+		name = p.user.Name
+	}
+	if server == "" {
+		server = "演示模式"
+	}
+	return layoutSection(gtx, th, "账号",
+		func(gtx C) D {
+			return layoutRow(gtx, th, "用户名", hint(th, name))
+		},
+		func(gtx C) D {
+			return layoutRow(gtx, th, "服务器", hint(th, server))
+		},
+	)
+}
+
+func (p *Page) layoutNotifications(gtx C, th *material.Theme) D {
+	quiet := p.settings.Get().DoNotDisturb
+	rows := []layout.Widget{
+		func(gtx C) D {
+			return layoutRow(gtx, th, "新消息通知",
+				material.Switch(th, &p.notify, "新消息通知").Layout)
+		},
+		func(gtx C) D {
+			return layoutRow(gtx, th, "通知显示消息内容",
+				material.Switch(th, &p.preview, "通知显示消息内容").Layout)
+		},
+		func(gtx C) D {
+			return layoutRow(gtx, th, "免打扰",
+				material.Switch(th, &p.quiet, "免打扰").Layout)
+		},
+	}
+	if quiet.Enabled {
+		rows = append(rows,
+			func(gtx C) D {
+				return layoutRow(gtx, th, "开始时间", func(gtx C) D {
+					return layoutStepper(gtx, th, &p.startEarlier, &p.startLater, quiet.Start)
+				})
+			},
+			func(gtx C) D {
+				return layoutRow(gtx, th, "结束时间", func(gtx C) D {
+					return layoutStepper(gtx, th, &p.endEarlier, &p.endLater, quiet.End)
+				})
+			},
+		)
+	}
+	return layoutSection(gtx, th, "通知", rows...)
+}
+
+// layoutStepper lays out the time at between buttons moving it earlier and
+// later.
+func layoutStepper(gtx C, th *material.Theme, earlier, later *widget.Clickable, at time.Duration) D {
+	btn := func(c *widget.Clickable, text string) layout.Widget {
+		b := material.Button(th, c, text)
+		b.Background = component.WithAlpha(th.Fg, 20)
+		b.Color = th.Fg
+		b.Inset = layout.UniformInset(values.MarginPadding6)
+		return b.Layout
+	}
+	clock := fmt.Sprintf("%02d:%02d", int(at.Hours()), int(at.Minutes())%60)
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+		layout.Rigid(btn(earlier, "−")),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Left: values.MarginPadding12, Right: values.MarginPadding12}.Layout(gtx,
+				material.Body1(th, clock).Layout)
+		}),
+		layout.Rigid(btn(later, "+")),
+	)
+}
+
+func (p *Page) layoutAppearance(gtx C, th *material.Theme) D {
+	return layoutSection(gtx, th, "外观",
+		material.RadioButton(th, &p.appearance, strconv.Itoa(int(prefs.AppearanceLight)), "浅色").Layout,
+		material.RadioButton(th, &p.appearance, strconv.Itoa(int(prefs.AppearanceDark)), "深色").Layout,
+		material.RadioButton(th, &p.appearance, strconv.Itoa(int(prefs.AppearanceSystem)), "跟随系统").Layout,
+	)
+}
+
+func (p *Page) layoutSendKey(gtx C, th *material.Theme) D {
+	return layoutSection(gtx, th, "发送消息",
+		material.RadioButton(th, &p.sendKey, strconv.Itoa(int(prefs.SendEnter)), "按 Enter 发送").Layout,
+		material.RadioButton(th, &p.sendKey, strconv.Itoa(int(prefs.SendShortEnter)),
+			"按 "+key.ModShortcut.String()+"+Enter"+" 发送").Layout,
+	)
+}
+
+func (p *Page) layoutStorage(gtx C, th *material.Theme) D {
+	size := "正在统计…"
+	if n := p.cacheSize.Load(); n >= 0 {
+		size = matchat.FormatSize(n)
+	}
+	return layoutSection(gtx, th, "存储",
+		func(gtx C) D {
+			return layoutRow(gtx, th, "图片和文件缓存", hint(th, size))
+		},
+		func(gtx C) D {
+			p.clearBtn.Text = "清除缓存"
+			if p.cacheSize.Load() <= 0 {
+				gtx = gtx.Disabled()
+			}
+			return p.clearBtn.Layout(gtx)
+		},
+	)
+}
@@ -0,0 +1,195 @@
+package settings
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"wechat_ui/app"
+	"wechat_ui/ui/assets"
+	"wechat_ui/ui/page/chat/model"
+	"wechat_ui/ui/page/chat/prefs"
+	"wechat_ui/ui/v"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+
+	chatui "wechat_ui/ui/page/chat/ui"
+)
+
+const PageID = "settings"
+
+// QuietStep is how much each click moves the start or end of the do not
+// disturb period.
+const QuietStep = 30 * time.Minute
+
+// Page presents the settings of the local user, in sections for the
+// account, notifications, appearance, sending messages and storage.
+// Changes take effect right away and are saved to the settings file.
+type Page struct {
+	*app.GenericPageModal
+
+	settings *prefs.Settings
+	// user is the local user, and server the chat server connected to,
+	// empty in demo mode.
+	user   *model.User
+	server string
+
+	list widget.List
+	// notify, preview and quiet switch notifications, the content of
+	// messages in them, and do not disturb.
+	notify, preview, quiet widget.Bool
+	// startEarlier and the other buttons move the start and end of the do
+	// not disturb period by QuietStep.
+	startEarlier, startLater, endEarlier, endLater widget.Clickable
+	// appearance and sendKey hold the values of the matching settings.
+	appearance, sendKey widget.Enum
+	clearBtn            v.Button
+
+	// cacheSize is the number of bytes taken by the cache, -1 while it is
+	// measured.
+	cacheSize atomic.Int64
+}
+
+func (p *Page) OnNavigatedTo() {
+	p.measureCache()
+}
+
+func (p *Page) OnNavigatedFrom() {
+}
+
+// NewPage creates the page changing settings. user is the local user, and
+// server the chat server connected to, empty in demo mode.
+func NewPage(settings *prefs.Settings, user *model.User, server string) *Page {
+	page := &Page{
+		GenericPageModal: app.NewGenericPageModal(PageID),
+		settings:         settings,
+		user:             user,
+		server:           server,
+		clearBtn:         v.NewOutlineButton("清除缓存"),
+	}
+	page.list.Axis = layout.Vertical
+	page.sync(settings.Get())
+	return page
+}
+
+func (p *Page) HandleUserInteractions() {
+	values, changed := p.settings.Get(), false
+	if p.notify.Changed() {
+		values.NotificationsOff, changed = !p.notify.Value, true
+	}
+	if p.preview.Changed() {
+		values.HidePreview, changed = !p.preview.Value, true
+	}
+	if p.quiet.Changed() {
+		values.DoNotDisturb.Enabled, changed = p.quiet.Value, true
+	}
+	quiet := &values.DoNotDisturb
+	for _, step := range []struct {
+		btn   *widget.Clickable
+		at    *time.Duration
+		delta time.Duration
+	}{
+		{&p.startEarlier, &quiet.Start, -QuietStep},
+		{&p.startLater, &quiet.Start, QuietStep},
+		{&p.endEarlier, &quiet.End, -QuietStep},
+		{&p.endLater, &quiet.End, QuietStep},
+	} {
+		for step.btn.Clicked() {
+			*step.at, changed = timeOfDay(*step.at+step.delta), true
+		}
+	}
+	if p.appearance.Changed() {
+		values.Appearance, changed = prefs.Appearance(enumValue(p.appearance)), true
+	}
+	if p.sendKey.Changed() {
+		values.SendKey, changed = prefs.SendKey(enumValue(p.sendKey)), true
+	}
+	if changed {
+		p.settings.Set(values)
+		go func() {
+			if err := p.settings.Save(); err != nil {
+				log.Printf("saving settings: %v", err)
+			}
+		}()
+	}
+	// Settings may also change on other pages, such as from the send key
+	// menu of the chat page.
+	p.sync(values)
+	if p.clearBtn.Clicked() {
+		p.clearCache()
+	}
+}
+
+// sync presents values in the controls.
+func (p *Page) sync(values prefs.Values) {
+	p.notify.Value = !values.NotificationsOff
+	p.preview.Value = !values.HidePreview
+	p.quiet.Value = values.DoNotDisturb.Enabled
+	p.appearance.Value = strconv.Itoa(int(values.Appearance))
+	p.sendKey.Value = strconv.Itoa(int(values.SendKey))
+}
+
+// enumValue returns the number selected in e.
+func enumValue(e widget.Enum) int {
+	n, _ := strconv.Atoi(e.Value)
+	return n
+}
+
+// timeOfDay wraps d around to within a day.
+func timeOfDay(d time.Duration) time.Duration {
+	const day = 24 * time.Hour
+	return (d%day + day) % day
+}
+
+// measureCache measures the space taken by the cache in the background.
+func (p *Page) measureCache() {
+	p.cacheSize.Store(-1)
+	go func() {
+		size, err := dirSize(chatui.CacheDir())
+		if err != nil {
+			log.Printf("measuring cache: %v", err)
+		}
+		p.cacheSize.Store(size)
+		assets.Window.Invalidate()
+	}()
+}
+
+// clearCache deletes the cache in the background, then measures it again.
+func (p *Page) clearCache() {
+	p.cacheSize.Store(-1)
+	go func() {
+		if err := os.RemoveAll(chatui.CacheDir()); err != nil {
+			log.Printf("clearing cache: %v", err)
+		}
+		p.measureCache()
+	}()
+}
+
+// dirSize returns the total number of bytes of the files in dir, 0 if it
+// does not exist.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return size, err
+}
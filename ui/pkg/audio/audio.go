@@ -0,0 +1,79 @@
+/*
+Package audio records and plays voice messages through the Recorder and
+Player interfaces, which the audio backends of each platform implement.
+
+FileRecorder and FilePlayer implement them without audio hardware: the
+recorder synthesizes the signal it records, and the player keeps time
+against a clock without producing sound. Both work on WAV files, so that
+the whole flow of voice messages can run headless, such as in tests.
+*/
+package audio
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotRecording reports that a Recorder is stopped while not recording.
+var ErrNotRecording = errors.New("not recording")
+
+// Recorder records audio from an input device to a file. Start, Level,
+// Elapsed and Cancel return promptly; Stop may block while the recording
+// is saved.
+type Recorder interface {
+	// Start starts recording.
+	Start() error
+	// Level returns the level of the input while recording, from 0 for
+	// silence to 1 for the loudest.
+	Level() float32
+	// Elapsed returns how long the recording has lasted, zero if not
+	// recording.
+	Elapsed() time.Duration
+	// Stop stops recording and saves the recording.
+	Stop() (Recording, error)
+	// Cancel stops recording and discards the recording.
+	Cancel()
+}
+
+// Recording describes recorded audio.
+type Recording struct {
+	// Path of the WAV file holding the audio.
+	Path string
+	// Duration of the audio.
+	Duration time.Duration
+	// Waveform holds WaveformBars levels of the audio, as computed by
+	// Waveform.
+	Waveform []uint8
+}
+
+// Player plays audio files, one at a time. Play may block while the file
+// is opened; the other methods return promptly.
+type Player interface {
+	// Play plays the WAV file at path from offset, replacing the file
+	// being played, if any.
+	Play(path string, offset time.Duration) error
+	// Pause pauses playback, keeping its position.
+	Pause()
+	// Seek moves the position of the file being played or paused to
+	// offset.
+	Seek(offset time.Duration)
+	// Status returns the state of playback.
+	Status() Status
+}
+
+// Status describes the state of a Player.
+type Status struct {
+	// Path of the file being played or paused, empty if none.
+	Path string
+	// Position is the offset played up to in the file, of length
+	// Duration.
+	Position, Duration time.Duration
+	// Playing reports that the file is being played rather than paused.
+	// Playback pauses at the end of the file.
+	Playing bool
+}
+
+// Ended reports whether playback reached the end of the file.
+func (s Status) Ended() bool {
+	return s.Path != "" && s.Position >= s.Duration
+}
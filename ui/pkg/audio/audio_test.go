@@ -0,0 +1,127 @@
+package audio
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWAV(t *testing.T) {
+	samples := []int16{0, 1, -1, 32767, -32768, 1234}
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, samples, 16000); err != nil {
+		t.Fatal(err)
+	}
+	got, rate, err := ReadWAV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 16000 || !reflect.DeepEqual(got, samples) {
+		t.Errorf("expected %v at 16000, got %v at %d", samples, got, rate)
+	}
+	if _, _, err := ReadWAV(bytes.NewReader([]byte("not a wav file, but long enough for a header"))); err == nil {
+		t.Errorf("expected error reading garbage")
+	}
+}
+
+func TestWaveform(t *testing.T) {
+	samples := []int16{0, 0, 100, -16384, 32767, 0, -32768, 0}
+	if got, want := Waveform(samples, 4), []uint8{0, 127, 254, 255}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := Waveform(samples[:2], 4); len(got) != 2 {
+		t.Errorf("expected a level per sample, got %v", got)
+	}
+}
+
+// clock is a fake time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestRecordAndPlay(t *testing.T) {
+	c := &clock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	rec := &FileRecorder{Dir: t.TempDir(), Now: c.Now}
+	if _, err := rec.Stop(); err != ErrNotRecording {
+		t.Errorf("expected ErrNotRecording, got %v", err)
+	}
+	if err := rec.Start(); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(3 * time.Second)
+	if got := rec.Elapsed(); got != 3*time.Second {
+		t.Errorf("expected 3s elapsed, got %v", got)
+	}
+	if level := rec.Level(); level < 0 || level > 1 {
+		t.Errorf("expected level within [0, 1], got %v", level)
+	}
+	recording, err := rec.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recording.Duration != 3*time.Second || len(recording.Waveform) != WaveformBars {
+		t.Errorf("expected 3s with %d levels, got %+v", WaveformBars, recording)
+	}
+	if _, err := os.Stat(recording.Path); err != nil {
+		t.Errorf("expected recording file: %v", err)
+	}
+	if rec.Elapsed() != 0 {
+		t.Errorf("expected recording to stop")
+	}
+
+	p := &FilePlayer{Now: c.Now}
+	if err := p.Play(recording.Path, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(500 * time.Millisecond)
+	want := Status{Path: recording.Path, Position: 1500 * time.Millisecond, Duration: 3 * time.Second, Playing: true}
+	if got := p.Status(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	p.Pause()
+	c.Advance(time.Second)
+	want.Playing = false
+	if got := p.Status(); got != want {
+		t.Errorf("expected paused at %+v, got %+v", want, got)
+	}
+	p.Seek(2 * time.Second)
+	if got := p.Status(); got.Position != 2*time.Second || got.Playing {
+		t.Errorf("expected paused at 2s, got %+v", got)
+	}
+	if err := p.Play(recording.Path, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(5 * time.Second)
+	got := p.Status()
+	if got.Position != 3*time.Second || got.Playing || !got.Ended() {
+		t.Errorf("expected to pause at the end, got %+v", got)
+	}
+	if err := p.Play(recording.Path+".missing", 0); err == nil {
+		t.Errorf("expected error playing a missing file")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	c := &clock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	rec := &FileRecorder{Dir: dir, Now: c.Now}
+	rec.Start()
+	c.Advance(time.Second)
+	rec.Cancel()
+	if _, err := rec.Stop(); err != ErrNotRecording {
+		t.Errorf("expected ErrNotRecording after cancel, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing saved, got %v", entries)
+	}
+}
@@ -0,0 +1,223 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileRecorder is a Recorder that needs no input device. It records a
+// synthesized signal, resembling speech, for as long as it is recording,
+// to WAV files of a directory.
+type FileRecorder struct {
+	// Dir is the directory recordings are saved in.
+	Dir string
+	// Rate is the sample rate of recordings, DefaultRate if zero.
+	Rate int
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	mu sync.Mutex
+	// started is when recording started, zero when not recording.
+	started time.Time
+}
+
+var _ Recorder = (*FileRecorder)(nil)
+
+func (r *FileRecorder) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Start starts recording, restarting the recording in progress if any.
+func (r *FileRecorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = r.now()
+	return nil
+}
+
+// Level returns the level of the synthesized signal.
+func (r *FileRecorder) Level() float32 {
+	elapsed := r.Elapsed()
+	if elapsed == 0 {
+		return 0
+	}
+	return float32(envelope(elapsed.Seconds()))
+}
+
+// Elapsed returns how long the recording has lasted.
+func (r *FileRecorder) Elapsed() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started.IsZero() {
+		return 0
+	}
+	return r.now().Sub(r.started)
+}
+
+// Stop stops recording and saves the signal synthesized for its duration.
+func (r *FileRecorder) Stop() (Recording, error) {
+	r.mu.Lock()
+	started := r.started
+	r.started = time.Time{}
+	r.mu.Unlock()
+	if started.IsZero() {
+		return Recording{}, ErrNotRecording
+	}
+	rate := r.Rate
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+	samples := synthesize(r.now().Sub(started), rate)
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return Recording{}, fmt.Errorf("saving recording: %w", err)
+	}
+	path := filepath.Join(r.Dir, fmt.Sprintf("voice-%d.wav", started.UnixNano()))
+	f, err := os.Create(path)
+	if err != nil {
+		return Recording{}, fmt.Errorf("saving recording: %w", err)
+	}
+	defer f.Close()
+	if err := WriteWAV(f, samples, rate); err != nil {
+		return Recording{}, fmt.Errorf("saving recording: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return Recording{}, fmt.Errorf("saving recording: %w", err)
+	}
+	return Recording{
+		Path:     path,
+		Duration: samplesDuration(len(samples), rate),
+		Waveform: Waveform(samples, WaveformBars),
+	}, nil
+}
+
+// Cancel stops recording.
+func (r *FileRecorder) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = time.Time{}
+}
+
+// synthesize returns the samples, taken at rate, of a tone modulated by
+// envelope for d.
+func synthesize(d time.Duration, rate int) []int16 {
+	samples := make([]int16, int(d.Seconds()*float64(rate)))
+	for ii := range samples {
+		t := float64(ii) / float64(rate)
+		samples[ii] = int16(envelope(t) * math.Sin(2*math.Pi*220*t) * math.MaxInt16)
+	}
+	return samples
+}
+
+// envelope returns the amplitude, from 0 to 1, of the synthesized signal
+// at t seconds: syllables of varying loudness, about four per second.
+func envelope(t float64) float64 {
+	syllable := math.Abs(math.Sin(math.Pi * 4 * t))
+	loudness := 0.55 + 0.45*math.Sin(2*math.Pi*0.7*t)*math.Cos(2*math.Pi*0.3*t)
+	return syllable * loudness
+}
+
+// FilePlayer is a Player that needs no output device. It plays WAV files
+// silently, advancing their position against a clock.
+type FilePlayer struct {
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	path     string
+	duration time.Duration
+	// offset is the position when playback last resumed, or the position
+	// while paused.
+	offset time.Duration
+	// resumed is when playback last resumed, zero while paused.
+	resumed time.Time
+}
+
+var _ Player = (*FilePlayer)(nil)
+
+func (p *FilePlayer) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// Play plays the file at path from offset. The file must be a WAV file
+// of 16-bit mono PCM.
+func (p *FilePlayer) Play(path string, offset time.Duration) error {
+	p.mu.Lock()
+	current, duration := p.path, p.duration
+	p.mu.Unlock()
+	if path != current {
+		var err error
+		if duration, err = readDuration(path); err != nil {
+			return fmt.Errorf("playing %s: %w", path, err)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.path, p.duration = path, duration
+	p.offset = clamp(offset, duration)
+	p.resumed = p.now()
+	return nil
+}
+
+// Pause pauses playback.
+func (p *FilePlayer) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offset = p.position()
+	p.resumed = time.Time{}
+}
+
+// Seek moves the position of the file being played or paused.
+func (p *FilePlayer) Seek(offset time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offset = clamp(offset, p.duration)
+	if !p.resumed.IsZero() {
+		p.resumed = p.now()
+	}
+}
+
+// Status returns the state of playback.
+func (p *FilePlayer) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pos := p.position()
+	if pos >= p.duration {
+		// Pause at the end.
+		p.offset, p.resumed = p.duration, time.Time{}
+	}
+	return Status{
+		Path:     p.path,
+		Position: pos,
+		Duration: p.duration,
+		Playing:  !p.resumed.IsZero(),
+	}
+}
+
+// position returns the position of playback at the time of the clock.
+func (p *FilePlayer) position() time.Duration {
+	if p.resumed.IsZero() {
+		return p.offset
+	}
+	return clamp(p.offset+p.now().Sub(p.resumed), p.duration)
+}
+
+// clamp returns d clamped within [0, max].
+func clamp(d, max time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultRate is the sample rate of recordings, in samples per second,
+// which suffices for voice.
+const DefaultRate = 8000
+
+// WaveformBars is the number of levels in the waveform of recordings.
+const WaveformBars = 40
+
+// wavHeader is the header of a WAV file holding 16-bit mono PCM samples.
+type wavHeader struct {
+	Riff          [4]byte
+	Size          uint32
+	Wave          [4]byte
+	Fmt           [4]byte
+	FmtSize       uint32
+	Format        uint16
+	Channels      uint16
+	Rate          uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Data          [4]byte
+	DataSize      uint32
+}
+
+// WriteWAV writes samples, taken at rate, to w as a WAV file of 16-bit
+// mono PCM.
+func WriteWAV(w io.Writer, samples []int16, rate int) error {
+	size := uint32(2 * len(samples))
+	h := wavHeader{
+		Riff:          [4]byte{'R', 'I', 'F', 'F'},
+		Size:          36 + size,
+		Wave:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1,
+		Channels:      1,
+		Rate:          uint32(rate),
+		ByteRate:      uint32(2 * rate),
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      size,
+	}
+	if err := binary.Write(w, binary.LittleEndian, h); err != nil {
+		return fmt.Errorf("writing wav header: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, samples); err != nil {
+		return fmt.Errorf("writing wav samples: %w", err)
+	}
+	return nil
+}
+
+// ReadWAV reads the samples of a WAV file of 16-bit mono PCM, as written
+// by WriteWAV, along with their rate.
+func ReadWAV(r io.Reader) (samples []int16, rate int, err error) {
+	var h wavHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, 0, fmt.Errorf("reading wav header: %w", err)
+	}
+	if string(h.Riff[:]) != "RIFF" || string(h.Wave[:]) != "WAVE" || string(h.Data[:]) != "data" {
+		return nil, 0, errors.New("reading wav header: not a wav file")
+	}
+	if h.Format != 1 || h.Channels != 1 || h.BitsPerSample != 16 || h.Rate == 0 {
+		return nil, 0, errors.New("reading wav header: not 16-bit mono pcm")
+	}
+	samples = make([]int16, h.DataSize/2)
+	if err := binary.Read(r, binary.LittleEndian, samples); err != nil {
+		return nil, 0, fmt.Errorf("reading wav samples: %w", err)
+	}
+	return samples, int(h.Rate), nil
+}
+
+// readDuration returns the duration of the WAV file at path.
+func readDuration(path string) (time.Duration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	samples, rate, err := ReadWAV(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return samplesDuration(len(samples), rate), nil
+}
+
+// samplesDuration returns the duration of n samples taken at rate.
+func samplesDuration(n, rate int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(rate)
+}
+
+// Waveform returns the levels of samples over bars spans of even length:
+// the peak amplitude of each span, from 0 for silence to 255 for full
+// scale. It returns fewer levels if there are fewer samples than bars.
+func Waveform(samples []int16, bars int) []uint8 {
+	if len(samples) < bars {
+		bars = len(samples)
+	}
+	levels := make([]uint8, bars)
+	for ii := range levels {
+		var peak int32
+		for _, s := range samples[ii*len(samples)/bars : (ii+1)*len(samples)/bars] {
+			a := int32(s)
+			if a < 0 {
+				a = -a
+			}
+			if a > peak {
+				peak = a
+			}
+		}
+		levels[ii] = uint8(peak * 255 / 32768)
+	}
+	return levels
+}
@@ -0,0 +1,147 @@
+/*
+Package clipimage reads and writes images on the system clipboard, which
+the clipboard operations of Gio limit to text.
+
+It drives the clipboard tools of each platform: wl-copy and wl-paste on
+Wayland, xclip on X11, osascript on macOS and PowerShell on Windows.
+Images are exchanged encoded as PNG.
+*/
+package clipimage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrNoImage reports that the clipboard holds no image.
+var ErrNoImage = errors.New("no image on the clipboard")
+
+// Read returns the image on the clipboard, encoded as PNG.
+func Read() ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch runtime.GOOS {
+	case "darwin":
+		data, err = viaFile(func(path string) *exec.Cmd {
+			return exec.Command("osascript",
+				"-e", fmt.Sprintf(`set f to open for access POSIX file %q with write permission`, path),
+				"-e", `try`,
+				"-e", `write (the clipboard as «class PNGf») to f`,
+				"-e", `end try`,
+				"-e", `close access f`)
+		})
+	case "windows":
+		data, err = viaFile(func(path string) *exec.Cmd {
+			return powershell(fmt.Sprintf(`$i = [Windows.Forms.Clipboard]::GetImage(); if ($i) { $i.Save(%s, [Drawing.Imaging.ImageFormat]::Png) }`, quote(path)))
+		})
+	default:
+		cmd := exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-out")
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-paste", "--no-newline", "--type", "image/png")
+		}
+		data, err = cmd.Output()
+	}
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			// The tools fail when the clipboard holds no image.
+			return nil, ErrNoImage
+		}
+		return nil, fmt.Errorf("reading clipboard image: %w", err)
+	}
+	if !bytes.HasPrefix(data, pngMagic) {
+		return nil, ErrNoImage
+	}
+	return data, nil
+}
+
+// Write places the PNG encoded image on the clipboard.
+func Write(png []byte) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		err = fromFile(png, func(path string) *exec.Cmd {
+			if runtime.GOOS == "windows" {
+				return powershell(fmt.Sprintf(`[Windows.Forms.Clipboard]::SetImage([Drawing.Image]::FromFile(%s))`, quote(path)))
+			}
+			return exec.Command("osascript", "-e", fmt.Sprintf(`set the clipboard to (read (POSIX file %q) as «class PNGf»)`, path))
+		})
+	default:
+		cmd := exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-in")
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-copy", "--type", "image/png")
+		}
+		cmd.Stdin = bytes.NewReader(png)
+		err = cmd.Run()
+	}
+	if err != nil {
+		return fmt.Errorf("writing clipboard image: %w", err)
+	}
+	return nil
+}
+
+// pngMagic starts every PNG file.
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// viaFile runs the command returned by cmd to save the clipboard image to
+// a temporary file, and returns its contents.
+func viaFile(cmd func(path string) *exec.Cmd) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "clipimage")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clipboard.png")
+	if err := cmd(path).Run(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoImage
+	}
+	return data, err
+}
+
+// fromFile saves png to a temporary file and runs the command returned by
+// cmd to place it on the clipboard.
+func fromFile(png []byte, cmd func(path string) *exec.Cmd) error {
+	dir, err := os.MkdirTemp("", "clipimage")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clipboard.png")
+	if err := os.WriteFile(path, png, 0600); err != nil {
+		return err
+	}
+	return cmd(path).Run()
+}
+
+// powershell returns the command running script with the clipboard classes
+// of Windows Forms loaded. The clipboard requires a single threaded
+// apartment.
+func powershell(script string) *exec.Cmd {
+	return exec.Command("powershell", "-NoProfile", "-STA", "-Command",
+		"Add-Type -AssemblyName System.Windows.Forms, System.Drawing; "+script)
+}
+
+// quote returns s as a single quoted PowerShell string.
+func quote(s string) string {
+	var b bytes.Buffer
+	b.WriteByte('\'')
+	for _, r := range s {
+		if r == '\'' {
+			b.WriteByte('\'')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
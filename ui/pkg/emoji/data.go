@@ -0,0 +1,339 @@
+package emoji
+
+// table lists the emoji offered by the picker, one per line, as the emoji
+// followed by its shortcodes. Lines starting with "#" begin a category.
+const table = `
+# 表情
+😀 grinning
+😃 smiley
+😄 smile
+😁 grin
+😆 laughing satisfied
+😅 sweat_smile
+🤣 rofl
+😂 joy
+🙂 slightly_smiling_face
+🙃 upside_down_face
+😉 wink
+😊 blush
+😇 innocent
+🥰 smiling_face_with_three_hearts
+😍 heart_eyes
+🤩 star_struck
+😘 kissing_heart
+😋 yum
+😛 stuck_out_tongue
+😜 stuck_out_tongue_winking_eye
+🤪 zany_face
+🤗 hugs
+🤭 hand_over_mouth
+🤫 shushing_face
+🤔 thinking
+🤐 zipper_mouth_face
+😐 neutral_face
+😑 expressionless
+😶 no_mouth
+😏 smirk
+😒 unamused
+🙄 roll_eyes
+😬 grimacing
+😌 relieved
+😔 pensive
+😪 sleepy
+😴 sleeping
+😷 mask
+🤒 face_with_thermometer
+🤢 nauseated_face
+🤮 vomiting_face
+🥵 hot_face
+🥶 cold_face
+😵 dizzy_face
+🤯 exploding_head
+🥳 partying_face
+😎 sunglasses
+🤓 nerd_face
+😕 confused
+😟 worried
+😮 open_mouth
+😲 astonished
+😳 flushed
+🥺 pleading_face
+😨 fearful
+😰 cold_sweat
+😢 cry
+😭 sob
+😱 scream
+😖 confounded
+😣 persevere
+😞 disappointed
+😓 sweat
+😩 weary
+😫 tired_face
+🥱 yawning_face
+😤 triumph
+😡 rage pout
+😠 angry
+🤬 cursing_face
+😈 smiling_imp
+💀 skull
+💩 poop hankey
+🤡 clown_face
+👻 ghost
+🙈 see_no_evil
+🙉 hear_no_evil
+🙊 speak_no_evil
+# 手势
+👋 wave
+🤚 raised_back_of_hand
+✋ hand raised_hand
+👌 ok_hand
+✌️ v victory
+🤞 crossed_fingers
+🤟 love_you_gesture
+🤘 metal
+👈 point_left
+👉 point_right
+👆 point_up_2
+👇 point_down
+👍 +1 thumbsup
+👎 -1 thumbsdown
+✊ fist_raised fist
+👊 facepunch punch
+👏 clap
+🙌 raised_hands
+👐 open_hands
+🤝 handshake
+🙏 pray
+💪 muscle
+❤️ heart
+🧡 orange_heart
+💛 yellow_heart
+💚 green_heart
+💙 blue_heart
+💜 purple_heart
+🖤 black_heart
+💔 broken_heart
+💕 two_hearts
+💯 100
+# 动物
+🐶 dog
+🐱 cat
+🐭 mouse
+🐰 rabbit
+🦊 fox_face
+🐻 bear
+🐼 panda_face
+🐨 koala
+🐯 tiger
+🦁 lion
+🐮 cow
+🐷 pig
+🐸 frog
+🐵 monkey_face
+🐔 chicken
+🐧 penguin
+🐦 bird
+🦆 duck
+🦉 owl
+🐝 bee honeybee
+🦋 butterfly
+🐌 snail
+🐢 turtle
+🐍 snake
+🐙 octopus
+🐟 fish
+🐬 dolphin
+🐳 whale
+🌸 cherry_blossom
+🌹 rose
+🌻 sunflower
+🌲 evergreen_tree
+🍀 four_leaf_clover
+🍁 maple_leaf
+# 食物
+🍎 apple
+🍊 tangerine orange
+🍋 lemon
+🍌 banana
+🍉 watermelon
+🍇 grapes
+🍓 strawberry
+🍑 peach
+🍒 cherries
+🥭 mango
+🍍 pineapple
+🥝 kiwi_fruit
+🍅 tomato
+🌽 corn
+🌶️ hot_pepper
+🍞 bread
+🧀 cheese
+🍳 fried_egg
+🍔 hamburger
+🍟 fries
+🍕 pizza
+🌭 hotdog
+🍜 ramen
+🍲 stew
+🍣 sushi
+🍚 rice
+🥟 dumpling
+🍦 icecream
+🍰 cake
+🎂 birthday
+🍫 chocolate_bar
+🍬 candy
+☕ coffee
+🍵 tea
+🧋 bubble_tea
+🍺 beer
+🍻 beers
+🍷 wine_glass
+# 活动
+⚽ soccer
+🏀 basketball
+🏈 football
+⚾ baseball
+🎾 tennis
+🏐 volleyball
+🏓 ping_pong
+🏸 badminton
+🎳 bowling
+⛳ golf
+🎣 fishing_pole_and_fish
+🏊 swimmer
+🚴 bicyclist
+🏆 trophy
+🥇 1st_place_medal
+🎮 video_game
+🎲 game_die
+🧩 jigsaw
+🎯 dart
+🎨 art
+🎤 microphone
+🎧 headphones
+🎸 guitar
+🎹 musical_keyboard
+🎉 tada
+🎊 confetti_ball
+🎈 balloon
+🎁 gift
+🧧 red_envelope
+🧨 firecracker
+🎆 fireworks
+🏮 izakaya_lantern lantern
+# 旅行
+🚗 car red_car
+🚕 taxi
+🚌 bus
+🚑 ambulance
+🚓 police_car
+🚲 bike
+🛵 motor_scooter
+🚄 bullettrain_side
+🚇 metro
+✈️ airplane
+🚀 rocket
+🛸 flying_saucer
+🚢 ship
+⛵ boat sailboat
+🏠 house
+🏢 office
+🏥 hospital
+🏫 school
+⛪ church
+🗼 tokyo_tower
+🏯 japanese_castle
+🗽 statue_of_liberty
+🌋 volcano
+🏝️ desert_island
+🌅 sunrise
+🌃 night_with_stars
+🌈 rainbow
+☀️ sunny
+🌙 crescent_moon
+⭐ star
+🌟 star2
+⚡ zap
+🔥 fire
+❄️ snowflake
+☔ umbrella
+🌊 ocean
+# 物品
+⌚ watch
+📱 iphone
+💻 computer
+⌨️ keyboard
+🖨️ printer
+📷 camera
+📺 tv
+⏰ alarm_clock
+⌛ hourglass
+💡 bulb
+🔦 flashlight
+💰 moneybag
+💳 credit_card
+💎 gem
+🔧 wrench
+🔨 hammer
+🔑 key
+🔒 lock
+🚪 door
+🛏️ bed
+📦 package
+✉️ email envelope
+📝 memo pencil
+📎 paperclip
+📌 pushpin
+✂️ scissors
+📅 date
+📚 books
+💊 pill
+🧸 teddy_bear
+👓 eyeglasses
+👔 necktie
+👗 dress
+👟 athletic_shoe
+👜 handbag
+🌂 closed_umbrella
+# 符号
+✅ white_check_mark
+☑️ ballot_box_with_check
+✔️ heavy_check_mark
+❌ x
+❎ negative_squared_cross_mark
+➕ heavy_plus_sign
+➖ heavy_minus_sign
+❓ question
+❗ exclamation heavy_exclamation_mark
+‼️ bangbang
+⁉️ interrobang
+⚠️ warning
+🚫 no_entry_sign
+⛔ no_entry
+♻️ recycle
+🔴 red_circle
+🟢 green_circle
+🔵 large_blue_circle
+⬆️ arrow_up
+⬇️ arrow_down
+⬅️ arrow_left
+➡️ arrow_right
+🔄 arrows_counterclockwise
+🆗 ok
+🆕 new
+🆒 cool
+🆘 sos
+💤 zzz
+💬 speech_balloon
+💭 thought_balloon
+🎵 musical_note
+🔔 bell
+#️⃣ hash
+1️⃣ one
+2️⃣ two
+3️⃣ three
+🇨🇳 cn
+🇺🇸 us
+🏳️‍🌈 rainbow_flag
+`
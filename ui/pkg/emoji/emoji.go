@@ -0,0 +1,241 @@
+/*
+Package emoji provides the emoji offered by the message editor: a
+categorized table that can be searched, shortcodes such as ":smile:" that
+expand to the emoji they name, the recently used emoji of a user, and the
+segmentation of text into the runs that must be shaped with an emoji font.
+*/
+package emoji
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/font"
+)
+
+// Typeface lists the font families providing color emoji glyphs on the
+// common platforms. Runs of emoji are shaped with it so that the glyphs
+// of a color font are preferred over the monochrome glyphs some text
+// fonts carry.
+const Typeface font.Typeface = "Noto Color Emoji, Apple Color Emoji, Segoe UI Emoji, Twemoji Mozilla, Noto Emoji"
+
+// Emoji is a single emoji of the table.
+type Emoji struct {
+	// Char is the emoji itself, possibly made of several runes.
+	Char string
+	// Shortcodes name the emoji, without the surrounding colons. The
+	// first one is its canonical name.
+	Shortcodes []string
+}
+
+// Name returns the canonical shortcode of the emoji.
+func (e Emoji) Name() string {
+	return e.Shortcodes[0]
+}
+
+// Category groups related emoji.
+type Category struct {
+	// Name of the category, as displayed by the picker.
+	Name string
+	// Emoji of the category, in display order.
+	Emoji []Emoji
+}
+
+var (
+	// categories holds the parsed table.
+	categories []Category
+	// byShortcode indexes the table by shortcode.
+	byShortcode = map[string]Emoji{}
+	// byChar indexes the table by emoji.
+	byChar = map[string]Emoji{}
+)
+
+func init() {
+	for _, line := range strings.Split(table, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "# "):
+			categories = append(categories, Category{Name: strings.TrimPrefix(line, "# ")})
+			continue
+		}
+		fields := strings.Fields(line)
+		e := Emoji{Char: fields[0], Shortcodes: fields[1:]}
+		last := &categories[len(categories)-1]
+		last.Emoji = append(last.Emoji, e)
+		byChar[e.Char] = e
+		for _, code := range e.Shortcodes {
+			byShortcode[code] = e
+		}
+	}
+}
+
+// Categories returns the categories of the table, in display order. The
+// result must not be modified.
+func Categories() []Category {
+	return categories
+}
+
+// Lookup returns the emoji named by shortcode, which is given without the
+// surrounding colons.
+func Lookup(shortcode string) (Emoji, bool) {
+	e, ok := byShortcode[strings.ToLower(shortcode)]
+	return e, ok
+}
+
+// Find returns the emoji of the table matching char.
+func Find(char string) (Emoji, bool) {
+	e, ok := byChar[char]
+	return e, ok
+}
+
+// Search returns the emoji having a shortcode that contains query. Emoji
+// named exactly by query come first, then those with a shortcode starting
+// with query; the others follow in table order.
+func Search(query string) []Emoji {
+	query = strings.ToLower(strings.Trim(strings.TrimSpace(query), ":"))
+	if query == "" {
+		return nil
+	}
+	type match struct {
+		Emoji
+		// rank is 0 for exact matches, 1 for prefix matches and 2 for
+		// the others.
+		rank int
+	}
+	var matches []match
+	for _, c := range categories {
+		for _, e := range c.Emoji {
+			rank := -1
+			for _, code := range e.Shortcodes {
+				r := -1
+				switch {
+				case code == query:
+					r = 0
+				case strings.HasPrefix(code, query):
+					r = 1
+				case strings.Contains(code, query):
+					r = 2
+				}
+				if r >= 0 && (rank < 0 || r < rank) {
+					rank = r
+				}
+			}
+			if rank >= 0 {
+				matches = append(matches, match{Emoji: e, rank: rank})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].rank < matches[j].rank
+	})
+	results := make([]Emoji, len(matches))
+	for ii, m := range matches {
+		results[ii] = m.Emoji
+	}
+	return results
+}
+
+// ShortcodeBefore reports whether the runes of text ending at the rune
+// offset caret form a known shortcode, such as ":smile:". It returns the
+// rune offset the shortcode starts at and the emoji it names.
+func ShortcodeBefore(text string, caret int) (start int, e Emoji, ok bool) {
+	runes := []rune(text)
+	if caret > len(runes) || caret < 2 || runes[caret-1] != ':' {
+		return 0, Emoji{}, false
+	}
+	for ii := caret - 2; ii >= 0; ii-- {
+		r := runes[ii]
+		if r == ':' {
+			e, ok := Lookup(string(runes[ii+1 : caret-1]))
+			return ii, e, ok
+		}
+		if !isShortcodeRune(r) {
+			break
+		}
+	}
+	return 0, Emoji{}, false
+}
+
+// isShortcodeRune reports whether r can appear in a shortcode.
+func isShortcodeRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '_' || r == '+' || r == '-'
+}
+
+// Segment is a run of text, either made only of emoji or free of them.
+type Segment struct {
+	Text  string
+	Emoji bool
+}
+
+// Segments splits text into alternating runs of emoji and other text, so
+// that the emoji runs can be shaped with Typeface.
+func Segments(text string) []Segment {
+	var (
+		segments []Segment
+		start    int
+		inEmoji  bool
+		// joined is set after a zero width joiner, which binds the next
+		// rune to the emoji sequence.
+		joined bool
+	)
+	for ii := 0; ii < len(text); {
+		r, size := utf8.DecodeRuneInString(text[ii:])
+		next, _ := utf8.DecodeRuneInString(text[ii+size:])
+		emoji := isEmoji(r) || isKeycapBase(r) && (next == variationSelector || next == keycap)
+		if inEmoji {
+			emoji = emoji || joined || isExtender(r)
+		}
+		if emoji != inEmoji && ii > 0 {
+			segments = append(segments, Segment{Text: text[start:ii], Emoji: inEmoji})
+			start = ii
+		}
+		inEmoji = emoji
+		joined = r == zeroWidthJoiner
+		ii += size
+	}
+	if start < len(text) {
+		segments = append(segments, Segment{Text: text[start:], Emoji: inEmoji})
+	}
+	return segments
+}
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationSelector = '\ufe0f'
+	keycap            = '\u20e3'
+)
+
+// isEmoji reports whether r is presented as an emoji on its own.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		// Mahjong and playing cards, enclosed characters, regional
+		// indicators, pictographs, emoticons, transport and symbols.
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		// Miscellaneous symbols and dingbats.
+		return true
+	case r >= 0x2300 && r <= 0x23FF, r >= 0x2B00 && r <= 0x2BFF:
+		// Technical symbols such as ⌚, and arrows such as ⬆.
+		return true
+	case r == 0x203C, r == 0x2049, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// isKeycapBase reports whether r starts a keycap sequence such as 1️⃣.
+func isKeycapBase(r rune) bool {
+	return r >= '0' && r <= '9' || r == '#' || r == '*'
+}
+
+// isExtender reports whether r continues the emoji sequence before it:
+// joiners, variation selectors, skin tone modifiers, keycaps and tags.
+func isExtender(r rune) bool {
+	return r == zeroWidthJoiner || r == variationSelector || r == keycap ||
+		r >= 0x1F3FB && r <= 0x1F3FF || r >= 0xE0020 && r <= 0xE007F
+}
@@ -0,0 +1,119 @@
+package emoji
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	if len(Categories()) != 8 {
+		t.Fatalf("expected 8 categories, got %d", len(Categories()))
+	}
+	for _, c := range Categories() {
+		if len(c.Emoji) == 0 {
+			t.Errorf("category %s is empty", c.Name)
+		}
+		for _, e := range c.Emoji {
+			if len(e.Shortcodes) == 0 {
+				t.Errorf("%s has no shortcode", e.Char)
+			}
+			for _, s := range Segments(e.Char) {
+				if !s.Emoji {
+					t.Errorf("%s (%s) is not segmented as an emoji: %+v", e.Char, e.Name(), Segments(e.Char))
+				}
+			}
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	results := Search("smile")
+	if len(results) < 2 || results[0].Char != "😄" {
+		t.Fatalf("expected 😄 first, got %v", results)
+	}
+	for _, e := range Search(":heart") {
+		if e.Name() == "heart_eyes" {
+			return
+		}
+	}
+	t.Errorf("expected heart_eyes to match heart")
+}
+
+func TestShortcodeBefore(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		caret int
+		start int
+		char  string
+		ok    bool
+	}{
+		{text: "hi :smile:", caret: 10, start: 3, char: "😄", ok: true},
+		{text: "你好:+1:!", caret: 6, start: 2, char: "👍", ok: true},
+		{text: "hi :smile: there", caret: 10, start: 3, char: "😄", ok: true},
+		{text: "hi :smile", caret: 9},
+		{text: "hi :nope:", caret: 9},
+		{text: "a b: c:", caret: 7},
+		{text: ":", caret: 1},
+		{text: "::", caret: 2},
+	} {
+		start, e, ok := ShortcodeBefore(tc.text, tc.caret)
+		if ok != tc.ok || ok && (start != tc.start || e.Char != tc.char) {
+			t.Errorf("%q at %d: got %d %q %v", tc.text, tc.caret, start, e.Char, ok)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	for text, want := range map[string][]Segment{
+		"":       nil,
+		"hello":  {{Text: "hello"}},
+		"hi 😄!":  {{Text: "hi "}, {Text: "😄", Emoji: true}, {Text: "!"}},
+		"👍🏽👍":    {{Text: "👍🏽👍", Emoji: true}},
+		"a❤️b":   {{Text: "a"}, {Text: "❤️", Emoji: true}, {Text: "b"}},
+		"1️⃣ 12": {{Text: "1️⃣", Emoji: true}, {Text: " 12"}},
+		"🏳️‍🌈中":  {{Text: "🏳️‍🌈", Emoji: true}, {Text: "中"}},
+	} {
+		if got := Segments(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expected %+v, got %+v", text, want, got)
+		}
+	}
+}
+
+func TestRecent(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRecent(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Use("😄")
+	r.Use("👍")
+	r.Use("😄")
+	r.Use("not an emoji")
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenRecent(dir, "user/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range r.List() {
+		got = append(got, e.Char)
+	}
+	if want := []string{"😄", "👍"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	other, err := OpenRecent(dir, "user2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.List()) != 0 {
+		t.Errorf("expected recent emoji to be kept per user")
+	}
+	for ii := 0; ii < MaxRecent+5; ii++ {
+		r.Use(string(rune('a' + ii)))
+	}
+	if len(r.chars) != MaxRecent {
+		t.Errorf("expected %d recent emoji, got %d", MaxRecent, len(r.chars))
+	}
+}
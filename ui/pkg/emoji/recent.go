@@ -0,0 +1,98 @@
+package emoji
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MaxRecent is the number of recently used emoji remembered.
+const MaxRecent = 24
+
+// Recent tracks the emoji a user used most recently. It is safe for
+// concurrent use.
+type Recent struct {
+	// path of the file the list is persisted in. Empty keeps the list in
+	// memory only.
+	path string
+
+	mu    sync.Mutex
+	chars []string
+}
+
+// OpenRecent returns the recently used emoji of user, persisted in a file
+// of dir. An empty dir keeps them in memory only. A missing file yields an
+// empty list.
+func OpenRecent(dir, user string) (*Recent, error) {
+	r := &Recent{}
+	if dir == "" {
+		return r, nil
+	}
+	r.path = filepath.Join(dir, "emoji-recent-"+url.PathEscape(user)+".json")
+	b, err := os.ReadFile(r.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("reading recent emoji: %w", err)
+	}
+	if err := json.Unmarshal(b, &r.chars); err != nil {
+		return r, fmt.Errorf("decoding recent emoji: %w", err)
+	}
+	if len(r.chars) > MaxRecent {
+		r.chars = r.chars[:MaxRecent]
+	}
+	return r, nil
+}
+
+// Use moves char to the front of the list.
+func (r *Recent) Use(char string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chars := append(make([]string, 0, len(r.chars)+1), char)
+	for _, c := range r.chars {
+		if c != char && len(chars) < MaxRecent {
+			chars = append(chars, c)
+		}
+	}
+	r.chars = chars
+}
+
+// List returns the emoji of the table used most recently, most recent
+// first.
+func (r *Recent) List() []Emoji {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Emoji, 0, len(r.chars))
+	for _, c := range r.chars {
+		if e, ok := Find(c); ok {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// Save persists the list, if it has a file.
+func (r *Recent) Save() error {
+	if r.path == "" {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.Marshal(r.chars)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding recent emoji: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("saving recent emoji: %w", err)
+	}
+	if err := os.WriteFile(r.path, b, 0o644); err != nil {
+		return fmt.Errorf("saving recent emoji: %w", err)
+	}
+	return nil
+}
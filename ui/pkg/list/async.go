@@ -13,6 +13,9 @@ const (
 	// push indicates the results from an asynchronous insertion of
 	// data. The application pushed data to the list.
 	push
+	// jump indicates the results of a jump request. The update replaced
+	// all data with the data surrounding an element.
+	jump
 )
 
 func (u updateType) String() string {
@@ -21,6 +24,8 @@ func (u updateType) String() string {
 		return "pull"
 	case push:
 		return "push"
+	case jump:
+		return "jump"
 	default:
 		return "unknown"
 	}
@@ -38,6 +43,9 @@ type stateUpdate struct {
 	// believes to have no new content.
 	Ignore Direction
 	Type   updateType
+	// Jump is the serial of the element jumped to, for updates of type
+	// jump.
+	Jump Serial
 }
 
 func (s stateUpdate) String() string {
@@ -71,6 +79,7 @@ func asyncProcess(maxSize int, hooks Hooks) (chan<- interface{}, chan viewport,
 				newElems   []Element
 				updateOnly []Element
 				rmSerials  []Serial
+				cleared    []Serial
 			)
 			select {
 			case req, more := <-reqChan:
@@ -134,19 +143,50 @@ func asyncProcess(maxSize int, hooks Hooks) (chan<- interface{}, chan viewport,
 					} else {
 						ignore = NoDirection
 					}
+				case jumpRequest:
+					su.Type = jump
+					su.Jump = req.Serial
+					var moreBefore, moreAfter bool
+					newElems, moreBefore, moreAfter = hooks.AroundLoader(req.Serial)
+					cleared = compact.Clear()
+					// Keep the elements around the target when compacting.
+					viewport.Start, viewport.End = req.Serial, req.Serial
+					ignore = NoDirection
+					if !moreBefore {
+						ignore.Add(Before)
+					}
+					if !moreAfter {
+						ignore.Add(After)
+					}
 				}
 			}
 			// Apply state updates.
 			compact.Apply(newElems, updateOnly, rmSerials)
 
-			// Update the viewport if there is a new one available.
-			select {
-			case viewport = <-viewports:
-			default:
+			// Update the viewport if there is a new one available. A jump
+			// makes any viewport reported beforehand stale.
+			if su.Type != jump {
+				select {
+				case viewport = <-viewports:
+				default:
+				}
 			}
 
 			// Fetch new contents and list of compacted content.
 			contents, compacted := compact.Compact(viewport.Start, viewport.End)
+			if len(cleared) > 0 {
+				// Elements cleared by a jump are compacted too, unless they
+				// were loaded again.
+				kept := make(map[Serial]bool, len(contents))
+				for _, elem := range contents {
+					kept[elem.Serial()] = true
+				}
+				for _, serial := range cleared {
+					if !kept[serial] {
+						compacted = append(compacted, serial)
+					}
+				}
+			}
 			su.CompactedSerials = compacted
 			// Synthesize elements based on new contents.
 			synthesis = Synthesize(contents, hooks.Synthesizer)
@@ -76,6 +76,15 @@ func (c *Compact) Apply(insertOrUpdate []Element, updateOnly []Element, remove [
 	})
 }
 
+// Clear removes all elements, returning their serials.
+func (c *Compact) Clear() (removed []Serial) {
+	for _, elem := range c.elements {
+		removed = append(removed, elem.Serial())
+	}
+	c.elements = nil
+	return removed
+}
+
 // Compact returns a compacted slice of the elements managed by the Compact.
 // The resulting elements are garanteed to be sorted using the
 // Compact's Comparator and there will usually be no more than c.Size elements.
@@ -68,6 +68,15 @@ type Comparator func(a, b Element) bool
 // will invoke the Loader hook to get more.
 type Loader func(direction Direction, relativeTo Serial) (elems []Element, more bool)
 
+// AroundLoader is a function that can fulfill requests to jump to an
+// arbitrary element. It returns the elements surrounding the one with the
+// provided serial, including that element if it exists, and whether more
+// elements exist before and after the returned ones.
+//
+// AroundLoader allows the manager to present elements far from the ones
+// already loaded, see Manager.JumpTo.
+type AroundLoader func(serial Serial) (elems []Element, moreBefore, moreAfter bool)
+
 // Presenter is a function that can transform the data for an Element
 // into a widget to be laid out in the user interface. It must not return
 // nil. The state parameter may be nil if the Element either has no
@@ -90,6 +99,8 @@ type Hooks struct {
 	Loader
 	Presenter
 	Allocator
+	// AroundLoader is optional. Without it, Manager.JumpTo has no effect.
+	AroundLoader
 	// Invalidator triggers a new frame in the window displaying the managed
 	// list.
 	Invalidator func()
@@ -226,6 +237,12 @@ type loadRequest struct {
 	viewport
 }
 
+// jumpRequest represents a request to replace the managed elements with
+// the ones surrounding the element with the given serial.
+type jumpRequest struct {
+	Serial Serial
+}
+
 // modificationRequest represents a request to insert or update some elements
 // within the managed list.
 type modificationRequest struct {
@@ -245,6 +245,23 @@ func (m *Manager) Remove(remove []Serial) {
 	}
 }
 
+// JumpTo discards the managed elements and replaces them with the ones
+// surrounding the element with the provided serial, as returned by the
+// AroundLoader hook. Once they are loaded, the list passed to UpdatedLen
+// is positioned with that element at its start. Paging then carries on
+// from the new elements in both directions.
+//
+// JumpTo has no effect if the AroundLoader hook is nil.
+//
+// This method may block, and should not be called from the goroutine that
+// is performing layout.
+func (m *Manager) JumpTo(serial Serial) {
+	if m.hooks.AroundLoader == nil || serial == NoSerial {
+		return
+	}
+	m.requests <- jumpRequest{Serial: serial}
+}
+
 // Layout the element at the given index.
 func (m *Manager) Layout(gtx layout.Context, index int) layout.Dimensions {
 	if index < 0 {
@@ -325,7 +342,16 @@ func (m *Manager) UpdatedLen(list *layout.List) int {
 		for ii := range pending {
 			su := pending[ii]
 			m.ignoring = su.Ignore
-			if len(m.elements.Elements) > 0 {
+			if su.Type == jump {
+				// Position the list on the element jumped to, or in the
+				// middle of the new elements if it does not exist.
+				index, ok := su.SerialToIndex[su.Jump]
+				if !ok {
+					index = len(su.Elements) / 2
+				}
+				list.Position = layout.Position{First: index, BeforeEnd: true}
+				stickToEnd, stickToBeginning = false, false
+			} else if len(m.elements.Elements) > 0 {
 				// Resolve the current element at the start of the viewport within
 				// the old element list.
 				listStart := min(list.Position.First, len(m.elements.Elements)-1)
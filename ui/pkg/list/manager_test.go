@@ -1,6 +1,7 @@
 package list
 
 import (
+	"fmt"
 	"image"
 	"runtime"
 	"strconv"
@@ -546,6 +547,101 @@ func TestManagerViewportOnRemoval(t *testing.T) {
 	}
 }
 
+// TestManagerJumpTo ensures that jumping replaces the managed elements with
+// the ones surrounding the target, and positions the list on it.
+func TestManagerJumpTo(t *testing.T) {
+	var ops op.Ops
+	gtx := layout.NewContext(&ops, system.FrameEvent{
+		Now: time.Now(),
+		Metric: unit.Metric{
+			PxPerDp: 1,
+			PxPerSp: 1,
+		},
+		Size: image.Pt(10, 10),
+	})
+	var data []Element
+	for i := 0; i < 100; i++ {
+		data = append(data, testElement{serial: fmt.Sprintf("%03d", i)})
+	}
+	indexOf := func(serial Serial) int {
+		for ii, elem := range data {
+			if elem.Serial() == serial {
+				return ii
+			}
+		}
+		return -1
+	}
+	const page = 5
+	hooks := testHooks
+	hooks.Comparator = func(a, b Element) bool { return a.Serial() < b.Serial() }
+	hooks.Synthesizer = func(a, b, c Element) []Element { return []Element{b} }
+	hooks.Loader = func(dir Direction, relativeTo Serial) ([]Element, bool) {
+		idx := indexOf(relativeTo)
+		switch {
+		case relativeTo == NoSerial:
+			return dupSlice(data[:page]), true
+		case dir == Before:
+			start := max(0, idx-page)
+			return dupSlice(data[start:idx]), start > 0
+		default:
+			end := min(len(data), idx+1+page)
+			return dupSlice(data[idx+1 : end]), end < len(data)
+		}
+	}
+	hooks.AroundLoader = func(serial Serial) ([]Element, bool, bool) {
+		idx := indexOf(serial)
+		start, end := max(0, idx-page), min(len(data), idx+1+page)
+		return dupSlice(data[start:end]), start > 0, end < len(data)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		target Serial
+		ignore Direction
+	}{
+		{
+			name:   "middle of the data",
+			target: data[50].Serial(),
+			ignore: NoDirection,
+		},
+		{
+			name:   "start of the data",
+			target: data[2].Serial(),
+			ignore: Before,
+		},
+		{
+			name:   "end of the data",
+			target: data[98].Serial(),
+			ignore: After,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var list layout.List
+			m := NewManager(30, hooks)
+			defer m.Shutdown()
+			m.JumpTo(tc.target)
+
+			// Wait for the jump to be processed before laying out, as an empty
+			// manager would otherwise request more elements.
+			deadline := time.Now().Add(time.Second)
+			for len(m.stateUpdates) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("timed out waiting for jump")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			list.Layout(gtx, m.UpdatedLen(&list), m.Layout)
+
+			if got := m.elements.SerialAt(list.Position.First); got != tc.target {
+				t.Errorf("expected list to start at %v, got %v", tc.target, got)
+			}
+			if m.ignoring != tc.ignore {
+				t.Errorf("expected ignoring %v, got %v", tc.ignore, m.ignoring)
+			}
+		})
+	}
+}
+
 // testHooks allocates default hooks for testing.
 var testHooks = Hooks{
 	Allocator: func(e Element) interface{} {
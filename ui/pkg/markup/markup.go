@@ -0,0 +1,325 @@
+/*
+Package markup parses the lightweight formatting of message content, and
+detects the links within it.
+
+The formatting follows the common chat conventions:
+
+	**bold**  *italic*  _italic_  ~~strikethrough~~  `code`
+
+and fenced blocks of code, which may name their language on the line of
+the opening fence:
+
+	```go
+	fmt.Println("hello")
+	```
+
+Code is taken literally: it holds neither formatting nor links. Markers
+that are not closed, or that enclose text starting or ending with a space,
+are presented as typed, so that "2 * 3 * 4" and "snake_case_name" keep
+their characters.
+
+Links are web addresses, starting with a scheme or with "www.", email
+addresses and phone numbers of 7 to 15 digits.
+*/
+package markup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Style is a set of formats applied to a span of text.
+type Style uint8
+
+const (
+	Bold Style = 1 << iota
+	Italic
+	Strike
+	// Code is inline code.
+	Code
+	// CodeBlock is a fenced block of code, presented on lines of its own.
+	CodeBlock
+)
+
+// Link enumerates the kinds of links detected in text.
+type Link uint8
+
+const (
+	NoLink Link = iota
+	URL
+	Email
+	Phone
+)
+
+// Span is a run of text sharing the same style, or a single link.
+type Span struct {
+	Text  string
+	Style Style
+	Link  Link
+}
+
+// Target returns the URL a link span refers to: web addresses with their
+// scheme, defaulting to https, email addresses as mailto URLs and phone
+// numbers as tel URLs. It returns the empty string for other spans.
+func (s Span) Target() string {
+	switch s.Link {
+	case URL:
+		if strings.Contains(s.Text, "://") {
+			return s.Text
+		}
+		return "https://" + s.Text
+	case Email:
+		return "mailto:" + s.Text
+	case Phone:
+		return "tel:" + strings.Map(func(r rune) rune {
+			if r == ' ' || r == '-' {
+				return -1
+			}
+			return r
+		}, s.Text)
+	}
+	return ""
+}
+
+// Plain returns text without its markers, as the concatenated text of its
+// spans.
+func Plain(text string) string {
+	var b strings.Builder
+	for _, s := range Parse(text) {
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+// fence delimits blocks of code.
+const fence = "```"
+
+// Parse splits text into spans by formatting and links. Concatenating the
+// text of the spans gives text without its markers.
+func Parse(text string) []Span {
+	var spans []Span
+	for {
+		start := strings.Index(text, fence)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+len(fence):], fence)
+		if end < 0 {
+			break
+		}
+		spans = appendInline(spans, text[:start], 0)
+		if code := codeBlock(text[start+len(fence) : start+len(fence)+end]); code != "" {
+			spans = append(spans, Span{Text: code, Style: CodeBlock})
+		}
+		text = text[start+end+2*len(fence):]
+	}
+	return merge(appendInline(spans, text, 0))
+}
+
+// codeBlock returns the code within fences, without the language named
+// on the line of the opening fence nor the line breaks next to the fences.
+func codeBlock(code string) string {
+	if nl := strings.IndexByte(code, '\n'); nl >= 0 && isWord(code[:nl]) {
+		code = code[nl:]
+	}
+	code = strings.TrimPrefix(strings.TrimPrefix(code, "\r"), "\n")
+	return strings.TrimSuffix(strings.TrimSuffix(code, "\n"), "\r")
+}
+
+// isWord reports whether s is made of letters, digits and the symbols of
+// language names, such as "c++".
+func isWord(s string) bool {
+	for _, r := range s {
+		if !isAlnum(r) && !strings.ContainsRune("+#-_.", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// marker is an inline formatting delimiter.
+type marker struct {
+	delim string
+	style Style
+}
+
+// markers are the inline delimiters, "**" ahead of "*" so that it is not
+// taken for two of them.
+var markers = []marker{
+	{delim: "`", style: Code},
+	{delim: "**", style: Bold},
+	{delim: "~~", style: Strike},
+	{delim: "*", style: Italic},
+	{delim: "_", style: Italic},
+}
+
+// appendInline appends the spans of text, formatted with style in addition
+// to its own markers, to spans.
+func appendInline(spans []Span, text string, style Style) []Span {
+	plain := 0
+	for i := 0; i < len(text); {
+		if m, ok := opening(text, i); ok {
+			if end := closing(text, i, m); end >= 0 {
+				spans = appendLinks(spans, text[plain:i], style)
+				inner := text[i+len(m.delim) : end]
+				if m.style == Code {
+					spans = append(spans, Span{Text: inner, Style: style | Code})
+				} else {
+					spans = appendInline(spans, inner, style|m.style)
+				}
+				i = end + len(m.delim)
+				plain = i
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return appendLinks(spans, text[plain:], style)
+}
+
+// opening returns the marker opening formatted text at offset i of text,
+// if any: it must be followed by text, not by a space, and single
+// character markers other than code must not follow a letter or digit.
+func opening(text string, i int) (marker, bool) {
+	for _, m := range markers {
+		if !strings.HasPrefix(text[i:], m.delim) {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(text[i+len(m.delim):])
+		switch {
+		case next == utf8.RuneError:
+			return marker{}, false
+		case m.style == Code && next == '`':
+			// A run of backticks, such as an unclosed fence.
+			return marker{}, false
+		case m.style != Code && unicode.IsSpace(next):
+			return marker{}, false
+		}
+		if len(m.delim) == 1 && m.style != Code {
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			if isAlnum(prev) {
+				return marker{}, false
+			}
+		}
+		return m, true
+	}
+	return marker{}, false
+}
+
+// closing returns the offset of the marker closing the text opened by m at
+// offset i of text, or -1 if it is not closed: the closing marker must not
+// follow a space, and single character markers other than code must not be
+// followed by a letter or digit. Markers ending a run of the same
+// character close text, so that the others close the text nested in it.
+func closing(text string, i int, m marker) int {
+	for k := i + len(m.delim) + 1; k <= len(text)-len(m.delim); k++ {
+		if !strings.HasPrefix(text[k:], m.delim) {
+			continue
+		}
+		if m.style == Code {
+			return k
+		}
+		run := len(text[k:]) - len(strings.TrimLeft(text[k:], m.delim[:1]))
+		if m.delim == "*" && run == 2 {
+			// The marker of nested bold text.
+			k++
+			continue
+		}
+		// Runs such as "***" close the nested text first.
+		k += run - len(m.delim)
+		prev, _ := utf8.DecodeLastRuneInString(text[:k])
+		if unicode.IsSpace(prev) {
+			continue
+		}
+		if len(m.delim) == 1 {
+			next, _ := utf8.DecodeRuneInString(text[k+1:])
+			if isAlnum(next) {
+				continue
+			}
+		}
+		return k
+	}
+	return -1
+}
+
+// isAlnum reports whether r is a letter or digit.
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// linkPattern matches web addresses, email addresses and phone numbers,
+// in its first, second and third group.
+var linkPattern = regexp.MustCompile(`(?i)(\b(?:https?://|www\.)[^\s<>"]+)|([a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,})|(\+?\d(?:[\- ]?\d){6,14})`)
+
+// datePattern matches dates, which are not taken for phone numbers.
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// appendLinks appends text, formatted with style, to spans, splitting the
+// links out of it. Code holds no links.
+func appendLinks(spans []Span, text string, style Style) []Span {
+	if text == "" {
+		return spans
+	}
+	if style&Code != 0 {
+		return append(spans, Span{Text: text, Style: style})
+	}
+	plain := 0
+	for _, m := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end, link := m[0], m[1], Link(0)
+		switch {
+		case m[2] >= 0:
+			link = URL
+			end = start + len(trimURL(text[start:end]))
+		case m[4] >= 0:
+			link = Email
+		case m[6] >= 0:
+			link = Phone
+			prev, _ := utf8.DecodeLastRuneInString(text[:start])
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			if isAlnum(prev) || prev == '.' || isAlnum(next) || datePattern.MatchString(text[start:end]) {
+				continue
+			}
+		}
+		if start > plain {
+			spans = append(spans, Span{Text: text[plain:start], Style: style})
+		}
+		spans = append(spans, Span{Text: text[start:end], Style: style, Link: link})
+		plain = end
+	}
+	if plain < len(text) {
+		spans = append(spans, Span{Text: text[plain:], Style: style})
+	}
+	return spans
+}
+
+// trimURL drops the punctuation ending the sentence a web address is part
+// of. Closing parentheses are kept if the address opens them.
+func trimURL(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		if !strings.ContainsRune(".,;:!?'\")]}", rune(last)) {
+			break
+		}
+		if last == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+// merge joins the adjacent spans of the same style that are not links.
+func merge(spans []Span) []Span {
+	var merged []Span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.Link == NoLink && merged[n-1].Link == NoLink && merged[n-1].Style == s.Style {
+			merged[n-1].Text += s.Text
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
@@ -0,0 +1,155 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type testcase struct {
+		name string
+		text string
+		want []Span
+	}
+	for _, tc := range []testcase{
+		{
+			name: "plain",
+			text: "hello world",
+			want: []Span{{Text: "hello world"}},
+		},
+		{
+			name: "formats",
+			text: "a **b** *c* _d_ ~~e~~ `f`",
+			want: []Span{
+				{Text: "a "},
+				{Text: "b", Style: Bold},
+				{Text: " "},
+				{Text: "c", Style: Italic},
+				{Text: " "},
+				{Text: "d", Style: Italic},
+				{Text: " "},
+				{Text: "e", Style: Strike},
+				{Text: " "},
+				{Text: "f", Style: Code},
+			},
+		},
+		{
+			name: "nested",
+			text: "**bold *both***",
+			want: []Span{
+				{Text: "bold ", Style: Bold},
+				{Text: "both", Style: Bold | Italic},
+			},
+		},
+		{
+			name: "literal markers",
+			text: "2 * 3 * 4 and snake_case_name and **open",
+			want: []Span{{Text: "2 * 3 * 4 and snake_case_name and **open"}},
+		},
+		{
+			name: "code is literal",
+			text: "`**not bold** www.example.com`",
+			want: []Span{{Text: "**not bold** www.example.com", Style: Code}},
+		},
+		{
+			name: "code block",
+			text: "see\n```go\nfmt.Println(\"*hi*\")\n```\ndone",
+			want: []Span{
+				{Text: "see\n"},
+				{Text: "fmt.Println(\"*hi*\")", Style: CodeBlock},
+				{Text: "\ndone"},
+			},
+		},
+		{
+			name: "nested italic",
+			text: "*a **b** c*",
+			want: []Span{
+				{Text: "a ", Style: Italic},
+				{Text: "b", Style: Italic | Bold},
+				{Text: " c", Style: Italic},
+			},
+		},
+		{
+			name: "unclosed fence",
+			text: "```code",
+			want: []Span{{Text: "```code"}},
+		},
+		{
+			name: "url",
+			text: "visit https://example.com/a?b=1.",
+			want: []Span{
+				{Text: "visit "},
+				{Text: "https://example.com/a?b=1", Link: URL},
+				{Text: "."},
+			},
+		},
+		{
+			name: "url with parentheses",
+			text: "(see www.example.com/wiki/Go_(language))",
+			want: []Span{
+				{Text: "(see "},
+				{Text: "www.example.com/wiki/Go_(language)", Link: URL},
+				{Text: ")"},
+			},
+		},
+		{
+			name: "formatted link",
+			text: "**mail bob@example.org**",
+			want: []Span{
+				{Text: "mail ", Style: Bold},
+				{Text: "bob@example.org", Style: Bold, Link: Email},
+			},
+		},
+		{
+			name: "phone",
+			text: "call +86 138-0013-8000 now",
+			want: []Span{
+				{Text: "call "},
+				{Text: "+86 138-0013-8000", Link: Phone},
+				{Text: " now"},
+			},
+		},
+		{
+			name: "not phones",
+			text: "on 2023-10-18 pi is 3.1415926 and id abc1234567",
+			want: []Span{{Text: "on 2023-10-18 pi is 3.1415926 and id abc1234567"}},
+		},
+		{
+			name: "chinese",
+			text: "你好**世界**",
+			want: []Span{
+				{Text: "你好"},
+				{Text: "世界", Style: Bold},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Parse(tc.text); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	for _, tc := range []struct {
+		span Span
+		want string
+	}{
+		{Span{Text: "www.example.com", Link: URL}, "https://www.example.com"},
+		{Span{Text: "http://example.com", Link: URL}, "http://example.com"},
+		{Span{Text: "bob@example.org", Link: Email}, "mailto:bob@example.org"},
+		{Span{Text: "+86 138-0013-8000", Link: Phone}, "tel:+8613800138000"},
+		{Span{Text: "plain"}, ""},
+	} {
+		if got := tc.span.Target(); got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.span, tc.want, got)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	if got, want := Plain("**hi** `x` www.example.com"), "hi x www.example.com"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Package notify presents notifications of incoming messages outside of the
+window, through the Notifier interface which the notification services of
+each platform implement, and decides when the user does not want to be
+disturbed.
+*/
+package notify
+
+import "time"
+
+// Notification describes an incoming message to the user.
+type Notification struct {
+	// Title names who sent the message, and where.
+	Title string
+	// Body previews the message.
+	Body string
+	// Tag identifies what the notification is about, such as the room the
+	// message was sent to, so that notifiers can replace the previous
+	// notification with the same tag.
+	Tag string
+}
+
+// Notifier presents notifications to the user, such as through the
+// notification center of the operating system. Notify may block while the
+// notification is presented.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Nop is a Notifier presenting nothing, for platforms without a
+// notification service.
+type Nop struct{}
+
+// Notify discards n.
+func (Nop) Notify(n Notification) error {
+	return nil
+}
+
+// Schedule is a daily period during which the user does not want to be
+// disturbed. The zero value is disabled.
+type Schedule struct {
+	// Enabled reports whether the schedule applies.
+	Enabled bool `json:"enabled,omitempty"`
+	// Start and End are the times of day the period starts and ends at, as
+	// offsets from midnight in local time. Periods ending before they start
+	// span midnight, such as from 22:00 to 08:00. Periods that end when
+	// they start last the whole day.
+	Start time.Duration `json:"start,omitempty"`
+	End   time.Duration `json:"end,omitempty"`
+}
+
+// Active reports whether t falls within the period.
+func (s Schedule) Active(t time.Time) bool {
+	if !s.Enabled {
+		return false
+	}
+	y, m, d := t.Date()
+	now := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	switch {
+	case s.Start == s.End:
+		return true
+	case s.Start < s.End:
+		return now >= s.Start && now < s.End
+	default:
+		return now >= s.Start || now < s.End
+	}
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2023, 5, 1, hour, min, 0, 0, time.Local)
+	}
+	clock := func(hour, min int) time.Duration {
+		return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute
+	}
+	for _, tc := range []struct {
+		name   string
+		s      Schedule
+		t      time.Time
+		active bool
+	}{
+		{"disabled", Schedule{Start: clock(0, 0), End: clock(23, 59)}, at(12, 0), false},
+		{"within", Schedule{true, clock(9, 0), clock(17, 0)}, at(12, 0), true},
+		{"at start", Schedule{true, clock(9, 0), clock(17, 0)}, at(9, 0), true},
+		{"at end", Schedule{true, clock(9, 0), clock(17, 0)}, at(17, 0), false},
+		{"before", Schedule{true, clock(9, 0), clock(17, 0)}, at(8, 59), false},
+		{"overnight evening", Schedule{true, clock(22, 0), clock(8, 0)}, at(23, 30), true},
+		{"overnight morning", Schedule{true, clock(22, 0), clock(8, 0)}, at(7, 59), true},
+		{"overnight day", Schedule{true, clock(22, 0), clock(8, 0)}, at(12, 0), false},
+		{"whole day", Schedule{true, clock(8, 0), clock(8, 0)}, at(3, 0), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.s.Active(tc.t); got != tc.active {
+				t.Errorf("expected %v, got %v", tc.active, got)
+			}
+		})
+	}
+}
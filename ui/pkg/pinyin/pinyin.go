@@ -0,0 +1,111 @@
+/*
+Package pinyin derives the initial letters used to group and sort names
+that mix latin and Chinese characters, as contact lists do.
+
+Chinese characters are mapped to the initial of their pinyin through
+their GB2312 encoding: the first level of GB2312 orders the common
+characters by pinyin, so the code ranges of each initial are contiguous.
+Characters outside that level, such as rare characters, have no known
+initial.
+*/
+package pinyin
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Other is the initial of names that do not start with a latin letter or
+// a known Chinese character. It sorts after all letters.
+const Other = '#'
+
+// initials holds the first GB2312 code of each pinyin initial, in order.
+// There are no pinyin starting with I, U or V.
+var initials = []struct {
+	code    int
+	initial rune
+}{
+	{0xB0A1, 'A'}, {0xB0C5, 'B'}, {0xB2C1, 'C'}, {0xB4EE, 'D'},
+	{0xB6EA, 'E'}, {0xB7A2, 'F'}, {0xB8C1, 'G'}, {0xB9FE, 'H'},
+	{0xBBF7, 'J'}, {0xBFA6, 'K'}, {0xC0AC, 'L'}, {0xC2E8, 'M'},
+	{0xC4C3, 'N'}, {0xC5B6, 'O'}, {0xC5BE, 'P'}, {0xC6DA, 'Q'},
+	{0xC8BB, 'R'}, {0xC8F6, 'S'}, {0xCBFA, 'T'}, {0xCDDA, 'W'},
+	{0xCEF4, 'X'}, {0xD1B9, 'Y'}, {0xD4D1, 'Z'},
+}
+
+// lastCode is the last code of the first level of GB2312.
+const lastCode = 0xD7F9
+
+// Initial returns the upper case initial of r: the letter itself for
+// latin letters, the initial of the pinyin for Chinese characters, and
+// Other for anything else.
+func Initial(r rune) rune {
+	if r < unicode.MaxASCII {
+		if unicode.IsLetter(r) {
+			return unicode.ToUpper(r)
+		}
+		return Other
+	}
+	if !unicode.Is(unicode.Han, r) {
+		return Other
+	}
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(string(r))
+	if err != nil || len(encoded) != 2 {
+		return Other
+	}
+	code := int(encoded[0])<<8 | int(encoded[1])
+	if code < initials[0].code || code > lastCode {
+		return Other
+	}
+	ii := sort.Search(len(initials), func(ii int) bool {
+		return initials[ii].code > code
+	})
+	return initials[ii-1].initial
+}
+
+// Abbreviate returns the initials of every character of s, in upper
+// case. Spaces are skipped. It serves as a sort key for names.
+func Abbreviate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if i := Initial(r); i != Other {
+			b.WriteRune(i)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Group returns the initial a name is grouped under: the initial of its
+// first character.
+func Group(name string) rune {
+	for _, r := range name {
+		return Initial(r)
+	}
+	return Other
+}
+
+// Less reports whether name a sorts before name b. Names are ordered by
+// group first, with Other last, then by the initials of their
+// characters, then by the names themselves.
+func Less(a, b string) bool {
+	ga, gb := Group(a), Group(b)
+	if ga != gb {
+		if ga == Other || gb == Other {
+			return gb == Other
+		}
+		return ga < gb
+	}
+	ka, kb := Abbreviate(a), Abbreviate(b)
+	if ka != kb {
+		return ka < kb
+	}
+	return a < b
+}
@@ -0,0 +1,44 @@
+package pinyin
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestInitial(t *testing.T) {
+	for r, want := range map[rune]rune{
+		'a': 'A',
+		'Z': 'Z',
+		'1': Other,
+		'_': Other,
+		'阿': 'A',
+		'八': 'B',
+		'陈': 'C',
+		'李': 'L',
+		'刘': 'L',
+		'欧': 'O',
+		'王': 'W',
+		'张': 'Z',
+		'赵': 'Z',
+		'座': 'Z',
+		'亍': Other, // second level of GB2312, ordered by radical.
+		'é': Other,
+	} {
+		if got := Initial(r); got != want {
+			t.Errorf("Initial(%q): expected %q, got %q", r, want, got)
+		}
+	}
+}
+
+func TestLess(t *testing.T) {
+	names := []string{"张三", "bob", "1st", "Alice", "李四", "阿强", "Zed", "李明"}
+	sort.Slice(names, func(ii, jj int) bool {
+		return Less(names[ii], names[jj])
+	})
+	want := []string{"Alice", "阿强", "bob", "李明", "李四", "Zed", "张三", "1st"}
+	for ii := range want {
+		if names[ii] != want[ii] {
+			t.Fatalf("expected %v, got %v", want, names)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package material
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	chatlayout "wechat_ui/ui/pkg/layout"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// MaxBadgeCount is the largest count a badge presents as is. Larger counts
+// are presented as "99+".
+const MaxBadgeCount = 99
+
+// BadgeStyle configures the presentation of a count, such as the number of
+// unread messages, in a pill over a corner of an icon or avatar.
+type BadgeStyle struct {
+	// Count configures the presentation of the count.
+	Count material.LabelStyle
+	// Background is the color of the pill.
+	Background color.NRGBA
+	// Size is the height of the pill, and its minimum width.
+	Size unit.Dp
+	// Padding separates the count from the ends of the pill.
+	Padding unit.Dp
+}
+
+// Badge constructs a BadgeStyle presenting count with sensible defaults.
+func Badge(th *material.Theme, count int) BadgeStyle {
+	label := strconv.Itoa(count)
+	if count > MaxBadgeCount {
+		label = strconv.Itoa(MaxBadgeCount) + "+"
+	}
+	b := BadgeStyle{
+		Count:      material.Label(th, unit.Sp(10), label),
+		Background: color.NRGBA{R: 0xfa, G: 0x51, B: 0x51, A: 0xff},
+		Size:       unit.Dp(16),
+		Padding:    unit.Dp(4),
+	}
+	b.Count.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	b.Count.Alignment = text.Middle
+	b.Count.MaxLines = 1
+	return b
+}
+
+// Dot constructs a BadgeStyle presenting a dot without a count, such as
+// for the unread messages of a muted room.
+func Dot(th *material.Theme) BadgeStyle {
+	return BadgeStyle{
+		Background: color.NRGBA{R: 0xb2, G: 0xb2, B: 0xb2, A: 0xff},
+		Size:       unit.Dp(10),
+	}
+}
+
+// Layout the badge. A badge without a count is laid out as a dot.
+func (b BadgeStyle) Layout(gtx C) D {
+	size := gtx.Dp(b.Size)
+	gtx.Constraints.Min.X, gtx.Constraints.Min.Y = size, size
+	gtx.Constraints.Max.Y = size
+	return chatlayout.Rounded(b.Size/2).Layout(gtx, func(gtx C) D {
+		return chatlayout.Background(b.Background).Layout(gtx, func(gtx C) D {
+			if b.Count.Text == "" {
+				return D{Size: image.Pt(size, size)}
+			}
+			return layout.Inset{Left: b.Padding, Right: b.Padding}.Layout(gtx, func(gtx C) D {
+				return layout.Center.Layout(gtx, b.Count.Layout)
+			})
+		})
+	})
+}
+
+// Corner lays out w with the badge centered on its top right corner.
+func (b BadgeStyle) Corner(gtx C, w layout.Widget) D {
+	dims := w(gtx)
+	gtx.Constraints.Min = image.Point{}
+	macro := op.Record(gtx.Ops)
+	badge := b.Layout(gtx)
+	call := macro.Stop()
+	defer op.Offset(image.Pt(dims.Size.X-badge.Size.X/2, -badge.Size.Y/2)).Push(gtx.Ops).Pop()
+	call.Add(gtx.Ops)
+	return dims
+}
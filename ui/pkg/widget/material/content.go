@@ -0,0 +1,122 @@
+package material
+
+import (
+	"image/color"
+	"strings"
+	"wechat_ui/ui/pkg/emoji"
+	"wechat_ui/ui/pkg/markup"
+
+	"gioui.org/font"
+	"gioui.org/x/richtext"
+)
+
+// Highlight colors of mentions and links, for text on light and dark
+// surfaces.
+var (
+	DefaultHighlightColor       = color.NRGBA{R: 0x1e, G: 0x6f, B: 0xd9, A: 255}
+	DefaultHighlightColorOnDark = color.NRGBA{R: 0x9c, G: 0xc8, B: 0xff, A: 255}
+)
+
+// CodeTypeface is the font family code is presented with.
+const CodeTypeface font.Typeface = "Go Mono, monospace"
+
+// LinkKey is the metadata key of the interactive spans presenting links,
+// holding the URL they refer to, as returned by markup.Span.Target.
+const LinkKey = "link"
+
+// strikeMark is the combining long stroke overlay, which strikes through the
+// rune it follows. Rich text cannot decorate text otherwise.
+const strikeMark = '\u0336'
+
+// HighlightColor returns the color highlighting mentions and links among
+// text of the provided color.
+func HighlightColor(text color.NRGBA) color.NRGBA {
+	if Luminance(text) > .5 {
+		return DefaultHighlightColorOnDark
+	}
+	return DefaultHighlightColor
+}
+
+// ContentSpans splits the content of span into spans presenting it
+// formatted by its markup, as parsed by markup.Parse. Code blocks are
+// presented on lines of their own. Links are interactive spans, holding
+// their URL under LinkKey, and mentions of names are split by
+// MentionSpans; both are highlighted with HighlightColor. It returns the
+// spans along with the indices of the highlighted ones among them.
+func ContentSpans(span richtext.SpanStyle, names []string) (spans []richtext.SpanStyle, highlights []int) {
+	parsed := markup.Parse(span.Content)
+	for ii, p := range parsed {
+		s := span
+		s.Content = p.Text
+		if p.Style&markup.Bold != 0 {
+			s.Font.Weight = font.Bold
+		}
+		if p.Style&markup.Italic != 0 {
+			s.Font.Style = font.Italic
+		}
+		if p.Style&(markup.Code|markup.CodeBlock) != 0 {
+			s.Font.Typeface = CodeTypeface
+		}
+		if p.Style&markup.CodeBlock != 0 {
+			if ii > 0 && !strings.HasSuffix(parsed[ii-1].Text, "\n") {
+				s.Content = "\n" + s.Content
+			}
+			if ii < len(parsed)-1 && !strings.HasPrefix(parsed[ii+1].Text, "\n") {
+				s.Content += "\n"
+			}
+		}
+		var split []richtext.SpanStyle
+		switch {
+		case p.Link != markup.NoLink:
+			s.Interactive = true
+			s.Set(LinkKey, p.Target())
+			s.Color = HighlightColor(span.Color)
+			highlights = append(highlights, len(spans))
+			split = []richtext.SpanStyle{s}
+		case p.Style&(markup.Code|markup.CodeBlock) != 0:
+			split = []richtext.SpanStyle{s}
+		default:
+			var mentions []int
+			split, mentions = MentionSpans(s, names)
+			for _, m := range mentions {
+				highlights = append(highlights, len(spans)+m)
+			}
+		}
+		if p.Style&markup.Strike != 0 {
+			for jj := range split {
+				if split[jj].Font.Typeface != emoji.Typeface {
+					split[jj].Content = strike(split[jj].Content)
+				}
+			}
+		}
+		spans = append(spans, split...)
+	}
+	if len(spans) == 0 {
+		spans = append(spans, span)
+	}
+	return spans, highlights
+}
+
+// strike returns text with each rune struck through.
+func strike(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		b.WriteRune(r)
+		if r != '\n' {
+			b.WriteRune(strikeMark)
+		}
+	}
+	return b.String()
+}
+
+// WithTextColor sets the color of the content to col, highlighting its
+// mentions and links with HighlightColor.
+func (c MessageStyle) WithTextColor(col color.NRGBA) MessageStyle {
+	for i := range c.Content.Styles {
+		c.Content.Styles[i].Color = col
+	}
+	for _, i := range c.highlights {
+		c.Content.Styles[i].Color = HighlightColor(col)
+	}
+	return c
+}
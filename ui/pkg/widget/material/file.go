@@ -0,0 +1,97 @@
+package material
+
+import (
+	"fmt"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+// FileIcon is the material design file indicator.
+var FileIcon *widget.Icon = mustIcon(icons.EditorInsertDriveFile)
+
+// FileCardStyle configures the presentation of a file sent as a message.
+type FileCardStyle struct {
+	// Icon is displayed before the name of the file.
+	Icon      *widget.Icon
+	IconColor color.NRGBA
+	IconSize  unit.Dp
+	// Name configures the presentation of the file name.
+	Name material.LabelStyle
+	// Size configures the presentation of the file size.
+	Size material.LabelStyle
+	// Open is the button performing the open action of the file.
+	Open material.ButtonStyle
+	// Padding separates the card contents from the edges of the bubble.
+	Padding layout.Inset
+	// Width of the card.
+	Width unit.Dp
+}
+
+// FileCard constructs a FileCardStyle with sensible defaults.
+func FileCard(th *material.Theme, open *widget.Clickable, name string, size int64) FileCardStyle {
+	fc := FileCardStyle{
+		Icon:      FileIcon,
+		IconColor: th.ContrastBg,
+		IconSize:  unit.Dp(40),
+		Name:      material.Body1(th, name),
+		Size:      material.Caption(th, FormatSize(size)),
+		Open:      material.Button(th, open, "Open"),
+		Padding:   layout.UniformInset(unit.Dp(12)),
+		Width:     unit.Dp(260),
+	}
+	fc.Name.MaxLines = 2
+	fc.Open.TextSize = unit.Sp(12)
+	fc.Open.Inset = layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(12), Right: unit.Dp(12)}
+	return fc
+}
+
+// Layout the file card.
+func (f FileCardStyle) Layout(gtx C) D {
+	width := gtx.Dp(f.Width)
+	if width > gtx.Constraints.Max.X {
+		width = gtx.Constraints.Max.X
+	}
+	gtx.Constraints.Min.X = width
+	gtx.Constraints.Max.X = width
+	return f.Padding.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						return layoutIcon(gtx, f.Icon, f.IconColor, f.IconSize)
+					}),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Flexed(1, func(gtx C) D {
+						return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+							layout.Rigid(f.Name.Layout),
+							layout.Rigid(f.Size.Layout),
+						)
+					}),
+				)
+			}),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+			layout.Rigid(func(gtx C) D {
+				return layout.E.Layout(gtx, f.Open.Layout)
+			}),
+		)
+	})
+}
+
+// FormatSize formats a size in bytes for humans, such as "1.5 MB".
+func FormatSize(size int64) string {
+	const k = 1024
+	if size < k {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(k), 0
+	for n := size / k; n >= k; n /= k {
+		div *= k
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,62 @@
+package material
+
+import (
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/x/richtext"
+)
+
+// MentionSpans splits the content of span into spans, presenting each "@"
+// followed by one of names as a mention, in bold and highlighted with
+// HighlightColor. The longest name wins where several match. The other spans
+// are split further by EmojiSpans. It returns the spans along with the
+// indices of the mentions among them.
+func MentionSpans(span richtext.SpanStyle, names []string) (spans []richtext.SpanStyle, mentions []int) {
+	content := span.Content
+	for len(content) > 0 {
+		at := strings.IndexByte(content, '@')
+		if at < 0 {
+			break
+		}
+		name := longestPrefix(content[at+1:], names)
+		if name == "" {
+			// Not a mention: keep the "@" with the text before it.
+			text := span
+			text.Content = content[:at+1]
+			spans = append(spans, EmojiSpans(text)...)
+			content = content[at+1:]
+			continue
+		}
+		if at > 0 {
+			text := span
+			text.Content = content[:at]
+			spans = append(spans, EmojiSpans(text)...)
+		}
+		mention := span
+		mention.Content = "@" + name
+		mention.Font.Weight = font.Bold
+		mention.Color = HighlightColor(span.Color)
+		mentions = append(mentions, len(spans))
+		spans = append(spans, mention)
+		content = content[at+1+len(name):]
+	}
+	if content != "" || len(spans) == 0 {
+		text := span
+		text.Content = content
+		spans = append(spans, EmojiSpans(text)...)
+	}
+	return spans, mentions
+}
+
+// longestPrefix returns the longest of names prefixing s, empty if none
+// does.
+func longestPrefix(s string, names []string) string {
+	longest := ""
+	for _, name := range names {
+		if len(name) > len(longest) && strings.HasPrefix(s, name) {
+			longest = name
+		}
+	}
+	return longest
+}
@@ -3,6 +3,7 @@ package material
 import (
 	"image"
 	"image/color"
+	"wechat_ui/ui/pkg/emoji"
 	chatlayout "wechat_ui/ui/pkg/layout"
 	"wechat_ui/ui/pkg/ninepatch"
 	widget2 "wechat_ui/ui/pkg/widget"
@@ -30,9 +31,32 @@ var ErrorIcon *widget.Icon = func() *widget.Icon {
 	return icon
 }()
 
+// Delivery progress indicators, from the material design icon set.
+var (
+	// PendingIcon indicates a message that is being sent.
+	PendingIcon *widget.Icon = mustIcon(icons.DeviceAccessTime)
+	// SentIcon indicates a message that has reached the server.
+	SentIcon *widget.Icon = mustIcon(icons.NavigationCheck)
+	// DeliveredIcon indicates a message that has reached its recipients.
+	// Read messages use the same icon in a highlight color.
+	DeliveredIcon *widget.Icon = mustIcon(icons.ActionDoneAll)
+)
+
+func mustIcon(data []byte) *widget.Icon {
+	icon, err := widget.NewIcon(data)
+	if err != nil {
+		panic(err)
+	}
+	return icon
+}
+
 // FailedToSend is the message that is displayed to the user when there was a
 // problem sending a chat message.
-const FailedToSend = "Sending failed"
+const FailedToSend = "Sending failed, click the icon to retry"
+
+// EditedMarker is displayed beneath the content of messages edited by their
+// sender.
+const EditedMarker = "(edited)"
 
 type (
 	C = layout.Context
@@ -109,22 +133,22 @@ type MessageStyle struct {
 	*ninepatch.NinePatch
 	// Content is the actual styled text of the message.
 	Content richtext.TextStyle
+	// Edited, if its text is set, marks the content as edited beneath it.
+	Edited material.LabelStyle
 	// Image is the optional image content of the message.
 	Image
+	// highlights are the indices of the spans of Content presenting
+	// mentions and links.
+	highlights []int
 }
 
 // Message constructs a MessageStyle with sensible defaults.
 func Message(th *material.Theme, interact *widget2.Message, content string, img image.Image) MessageStyle {
 	interact.Image.Cache(img)
-	l := material.Body1(th, "")
+	spans, highlights := ContentSpans(contentSpan(th, content), nil)
 	return MessageStyle{
-		BubbleStyle: Bubble(th),
-		Content: richtext.Text(&interact.InteractiveText, th.Shaper, richtext.SpanStyle{
-			Font:    l.Font,
-			Size:    l.TextSize,
-			Color:   th.Fg,
-			Content: content,
-		}),
+		BubbleStyle:    Bubble(th),
+		Content:        richtext.Text(&interact.InteractiveText, th.Shaper, spans...),
 		ContentPadding: layout.UniformInset(unit.Dp(8)),
 		Image: Image{
 			Width:  unit.Dp(400),
@@ -139,6 +163,18 @@ func Message(th *material.Theme, interact *widget2.Message, content string, img
 		MaxMessageWidth: DefaultMaxMessageWidth,
 		MaxImageHeight:  DefaultMaxImageHeight,
 		Interaction:     interact,
+		highlights:      highlights,
+	}
+}
+
+// contentSpan returns the span presenting content in the body text style.
+func contentSpan(th *material.Theme, content string) richtext.SpanStyle {
+	l := material.Body1(th, "")
+	return richtext.SpanStyle{
+		Font:    l.Font,
+		Size:    l.TextSize,
+		Color:   th.Fg,
+		Content: content,
 	}
 }
 
@@ -157,9 +193,7 @@ func (c MessageStyle) WithNinePatch(th *material.Theme, np ninepatch.NinePatch)
 	// Only considers color.NRGBA colors.
 	if cl, ok := np.Image.At(b.Dx()/2, b.Dy()/2).(color.NRGBA); ok {
 		if Luminance(cl) < 0.5 {
-			for i := range c.Content.Styles {
-				c.Content.Styles[i].Color = th.Bg
-			}
+			c = c.WithTextColor(th.Bg)
 		}
 	}
 	return c
@@ -169,9 +203,7 @@ func (c MessageStyle) WithNinePatch(th *material.Theme, np ninepatch.NinePatch)
 func (c MessageStyle) WithBubbleColor(th *material.Theme, col color.NRGBA, luminance float64) MessageStyle {
 	c.BubbleStyle.Color = col
 	if luminance < .5 {
-		for i := range c.Content.Styles {
-			c.Content.Styles[i].Color = th.Bg
-		}
+		c = c.WithTextColor(th.Bg)
 	}
 	return c
 }
@@ -190,7 +222,13 @@ func (m MessageStyle) Layout(gtx C) D {
 		}
 		return surface(gtx, func(gtx C) D {
 			return m.ContentPadding.Layout(gtx, func(gtx C) D {
-				return m.Content.Layout(gtx)
+				if m.Edited.Text == "" {
+					return m.Content.Layout(gtx)
+				}
+				return layout.Flex{Axis: layout.Vertical, Alignment: layout.End}.Layout(gtx,
+					layout.Rigid(m.Content.Layout),
+					layout.Rigid(m.Edited.Layout),
+				)
 			})
 		})
 	}
@@ -205,3 +243,22 @@ func (m MessageStyle) Layout(gtx C) D {
 func Luminance(c color.NRGBA) float64 {
 	return (float64(float64(0.299)*float64(c.R) + float64(0.587)*float64(c.G) + float64(0.114)*float64(c.B))) / 255
 }
+
+// EmojiSpans splits the content of span into spans shaping its runs of emoji
+// with emoji.Typeface, so that they are drawn with a color emoji font
+// rather than the monochrome glyphs, if any, of the text font.
+func EmojiSpans(span richtext.SpanStyle) []richtext.SpanStyle {
+	segments := emoji.Segments(span.Content)
+	if len(segments) == 0 {
+		return []richtext.SpanStyle{span}
+	}
+	spans := make([]richtext.SpanStyle, len(segments))
+	for ii, seg := range segments {
+		spans[ii] = span
+		spans[ii].Content = seg.Text
+		if seg.Emoji {
+			spans[ii].Font.Typeface = emoji.Typeface
+		}
+	}
+	return spans
+}
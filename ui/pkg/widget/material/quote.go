@@ -0,0 +1,88 @@
+package material
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+)
+
+// QuoteStyle configures the presentation of a compact preview of a quoted
+// message, such as the message a reply refers to.
+type QuoteStyle struct {
+	// Sender configures the presentation of the quoted message's sender.
+	Sender material.LabelStyle
+	// Content configures the presentation of the quoted text, limited to
+	// a single line.
+	Content material.LabelStyle
+	// BarColor is the color of the bar drawn along the leading edge.
+	BarColor color.NRGBA
+	// BarWidth is the width of that bar.
+	BarWidth unit.Dp
+	// Background is the color beneath the preview.
+	Background color.NRGBA
+	// Padding separates the text from the edges of the background.
+	Padding layout.Inset
+	// MaxWidth constrains the display width of the preview.
+	MaxWidth unit.Dp
+	// Clickable, if set, makes the preview clickable.
+	Clickable *widget.Clickable
+}
+
+// Quote constructs a QuoteStyle with sensible defaults.
+func Quote(th *material.Theme, clickable *widget.Clickable, sender, content string) QuoteStyle {
+	qs := QuoteStyle{
+		Sender:     material.Body2(th, sender),
+		Content:    material.Body2(th, content),
+		BarColor:   th.ContrastBg,
+		BarWidth:   unit.Dp(3),
+		Background: component.WithAlpha(th.Fg, 20),
+		Padding:    layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)},
+		MaxWidth:   DefaultMaxMessageWidth,
+		Clickable:  clickable,
+	}
+	qs.Sender.Color = th.ContrastBg
+	qs.Content.Color = component.WithAlpha(th.Fg, 180)
+	qs.Content.MaxLines = 1
+	return qs
+}
+
+// Layout the quote.
+func (q QuoteStyle) Layout(gtx C) D {
+	if limit := gtx.Dp(q.MaxWidth); q.MaxWidth > 0 && gtx.Constraints.Max.X > limit {
+		gtx.Constraints.Max.X = limit
+	}
+	gtx.Constraints.Min = image.Point{}
+	if q.Clickable != nil {
+		return q.Clickable.Layout(gtx, q.layout)
+	}
+	return q.layout(gtx)
+}
+
+func (q QuoteStyle) layout(gtx C) D {
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx C) D {
+			size := gtx.Constraints.Min
+			paint.FillShape(gtx.Ops, q.Background, clip.Rect(image.Rectangle{Max: size}).Op())
+			bar := image.Pt(gtx.Dp(q.BarWidth), size.Y)
+			paint.FillShape(gtx.Ops, q.BarColor, clip.Rect(image.Rectangle{Max: bar}).Op())
+			return D{Size: size}
+		}),
+		layout.Stacked(func(gtx C) D {
+			inset := q.Padding
+			inset.Left += q.BarWidth
+			return inset.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(q.Sender.Layout),
+					layout.Rigid(q.Content.Layout),
+				)
+			})
+		}),
+	)
+}
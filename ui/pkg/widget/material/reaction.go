@@ -0,0 +1,101 @@
+package material
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"wechat_ui/ui/pkg/emoji"
+	chatlayout "wechat_ui/ui/pkg/layout"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+	"gioui.org/x/outlay"
+)
+
+// ReactionConfig describes the reactions to a message with an emoji.
+type ReactionConfig struct {
+	Emoji string
+	// Count of the users who reacted with Emoji.
+	Count int
+	// Mine reports whether the local user is among them.
+	Mine bool
+}
+
+// ReactionChipStyle configures the presentation of the reactions to a
+// message with an emoji, as a chip holding the emoji and its count.
+type ReactionChipStyle struct {
+	// Emoji configures the presentation of the emoji.
+	Emoji material.LabelStyle
+	// Count configures the presentation of the number of reactions.
+	Count material.LabelStyle
+	// Background is the color of the chip.
+	Background color.NRGBA
+	// Border, if not transparent, outlines the chip. It highlights the
+	// reactions of the local user.
+	Border color.NRGBA
+	// Padding separates the contents from the edges of the chip.
+	Padding layout.Inset
+	// Clickable, if set, makes the chip clickable.
+	Clickable *widget.Clickable
+}
+
+// ReactionChip constructs a ReactionChipStyle with sensible defaults.
+func ReactionChip(th *material.Theme, clickable *widget.Clickable, r ReactionConfig) ReactionChipStyle {
+	rc := ReactionChipStyle{
+		Emoji:      material.Body2(th, r.Emoji),
+		Count:      material.Caption(th, strconv.Itoa(r.Count)),
+		Background: component.WithAlpha(th.Fg, 20),
+		Padding:    layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(2), Left: unit.Dp(8), Right: unit.Dp(8)},
+		Clickable:  clickable,
+	}
+	rc.Emoji.Font.Typeface = emoji.Typeface
+	rc.Emoji.MaxLines = 1
+	rc.Count.Color = component.WithAlpha(th.Fg, 180)
+	if r.Mine {
+		rc.Background = component.WithAlpha(th.ContrastBg, 40)
+		rc.Border = th.ContrastBg
+		rc.Count.Color = th.ContrastBg
+	}
+	return rc
+}
+
+// Layout the chip.
+func (r ReactionChipStyle) Layout(gtx C) D {
+	gtx.Constraints.Min = image.Point{}
+	if r.Clickable != nil {
+		return r.Clickable.Layout(gtx, r.layout)
+	}
+	return r.layout(gtx)
+}
+
+func (r ReactionChipStyle) layout(gtx C) D {
+	radius := unit.Dp(12)
+	content := func(gtx C) D {
+		return chatlayout.Rounded(radius).Layout(gtx, func(gtx C) D {
+			return chatlayout.Background(r.Background).Layout(gtx, func(gtx C) D {
+				return r.Padding.Layout(gtx, func(gtx C) D {
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+						layout.Rigid(r.Emoji.Layout),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(4)}.Layout),
+						layout.Rigid(r.Count.Layout),
+					)
+				})
+			})
+		})
+	}
+	if r.Border.A == 0 {
+		return content(gtx)
+	}
+	return widget.Border{Color: r.Border, CornerRadius: radius, Width: unit.Dp(1)}.Layout(gtx, content)
+}
+
+// layoutReactions lays out the reaction chips beneath the chat bubble,
+// wrapping them onto several lines as needed.
+func (c RowStyle) layoutReactions(gtx C) D {
+	return outlay.FlowWrap{}.Layout(gtx, len(c.Reactions), func(gtx C, ii int) D {
+		return layout.Inset{Right: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, c.Reactions[ii].Layout)
+	})
+}
@@ -33,10 +33,32 @@ type RowStyle struct {
 	// StatusMessage defines a warning message to be displayed beneath the
 	// chat message.
 	StatusMessage material.LabelStyle
+	// Retry, if set, makes the StatusIcon clickable to re-submit a message
+	// that failed to send.
+	Retry *widget.Clickable
+	// ProgressIcon is an optional icon displayed next to the timestamp to
+	// indicate the delivery progress of the message.
+	ProgressIcon *widget.Icon
+	// ProgressIconColor is the color of the progress icon, if any is set.
+	ProgressIconColor color.NRGBA
+	// ProgressIconSize defines the size of the ProgressIcon (if it is set).
+	ProgressIconSize unit.Dp
 	// UserInfoStyle configures how the sender's information is displayed.
 	UserInfoStyle
 	// MessageStyle configures how the text and its background are presented.
 	MessageStyle
+	// Quote, if set, previews the message this one replies to above the
+	// chat bubble.
+	Quote *QuoteStyle
+	// File, if set, presents the file sent as the message within the
+	// chat bubble, instead of the MessageStyle contents.
+	File *FileCardStyle
+	// Voice, if set, presents the voice message within the chat bubble,
+	// instead of the MessageStyle contents.
+	Voice *VoiceStyle
+	// Reactions present the reactions to the message beneath the chat
+	// bubble.
+	Reactions []ReactionChipStyle
 	// Interaction holds the interactive state of this message.
 	Interaction *chatwidget.Row
 	// Menu configures the right-click context menu for this message.
@@ -52,9 +74,48 @@ type RowConfig struct {
 	SentAt  time.Time
 	Image   image.Image
 	Local   bool
-	Status  string
+	Status  Status
+	// ReplyTo describes the message this one replies to, if any.
+	ReplyTo *QuoteConfig
+	// File describes the file sent as the message, if any. Images are
+	// presented with the Image field instead.
+	File *FileConfig
+	// Voice describes the voice message, if any.
+	Voice *VoiceConfig
+	// Reactions to the message, in display order.
+	Reactions []ReactionConfig
+	// Edited reports whether the sender edited the content.
+	Edited bool
+	// Mentions are the names of the users the content mentions, as "@"
+	// followed by the name, which are highlighted.
+	Mentions []string
 }
 
+// FileConfig describes a file sent as a message.
+type FileConfig struct {
+	Name string
+	Size int64
+}
+
+// QuoteConfig describes a quoted message.
+type QuoteConfig struct {
+	Sender  string
+	Content string
+}
+
+// Status enumerates the delivery states of a message a row can present.
+type Status int
+
+const (
+	// StatusNone presents no delivery state, as for received messages.
+	StatusNone Status = iota
+	StatusPending
+	StatusSent
+	StatusDelivered
+	StatusRead
+	StatusFailed
+)
+
 // NewRow creates a style type that can lay out the data for a message.
 func NewRow(th *material.Theme, interact *chatwidget.Row, menu *component.MenuState, msg RowConfig) RowStyle {
 	if interact == nil {
@@ -70,40 +131,88 @@ func NewRow(th *material.Theme, interact *chatwidget.Row, menu *component.MenuSt
 			Gutter:         layout2.Gutter(),
 			Direction:      layout.W,
 		},
-		Time:          material.Body2(th, msg.SentAt.Local().Format("15:04")),
-		Local:         msg.Local,
-		IconSize:      unit.Dp(32),
-		UserInfoStyle: UserInfo(th, &interact.UserInfo, msg.Sender, msg.Avatar),
-		Interaction:   interact,
-		Menu:          component.Menu(th, menu),
-		MessageStyle:  Message(th, &interact.Message, msg.Content, msg.Image),
+		Time:             material.Body2(th, msg.SentAt.Local().Format("15:04")),
+		Local:            msg.Local,
+		IconSize:         unit.Dp(32),
+		ProgressIconSize: unit.Dp(14),
+		UserInfoStyle:    UserInfo(th, &interact.UserInfo, msg.Sender, msg.Avatar),
+		Interaction:      interact,
+		Menu:             component.Menu(th, menu),
+		MessageStyle:     Message(th, &interact.Message, msg.Content, msg.Image),
 	}
 	ms.UserInfoStyle.Local = msg.Local
+	if len(msg.Mentions) > 0 {
+		ms.MessageStyle.Content.Styles, ms.MessageStyle.highlights = ContentSpans(contentSpan(th, msg.Content), msg.Mentions)
+	}
+	if msg.Edited {
+		ms.MessageStyle.Edited = material.Caption(th, EditedMarker)
+	}
+	if msg.ReplyTo != nil {
+		quote := Quote(th, &interact.Quote, msg.ReplyTo.Sender, msg.ReplyTo.Content)
+		ms.Quote = &quote
+	}
+	if msg.File != nil {
+		file := FileCard(th, &interact.Open, msg.File.Name, msg.File.Size)
+		ms.File = &file
+	}
+	if msg.Voice != nil {
+		voice := Voice(th, &interact.Voice, *msg.Voice)
+		ms.Voice = &voice
+	}
+	if len(msg.Reactions) > len(interact.Reactions) {
+		interact.Reactions = append(interact.Reactions, make([]widget.Clickable, len(msg.Reactions)-len(interact.Reactions))...)
+	}
+	for ii, r := range msg.Reactions {
+		ms.Reactions = append(ms.Reactions, ReactionChip(th, &interact.Reactions[ii], r))
+	}
 	if msg.Local {
 		ms.Row.Direction = layout.E
 	}
-	if msg.Status != "" {
-		ms.StatusMessage = material.Body2(th, msg.Status)
+	ms.ProgressIconColor = ms.Time.Color
+	switch msg.Status {
+	case StatusPending:
+		ms.ProgressIcon = PendingIcon
+	case StatusSent:
+		ms.ProgressIcon = SentIcon
+	case StatusDelivered:
+		ms.ProgressIcon = DeliveredIcon
+	case StatusRead:
+		ms.ProgressIcon = DeliveredIcon
+		ms.ProgressIconColor = th.ContrastBg
+	case StatusFailed:
+		ms.StatusMessage = material.Body2(th, FailedToSend)
 		ms.StatusMessage.Color = DefaultDangerColor
 		ms.StatusIcon = ErrorIcon
 		ms.StatusIconColor = DefaultDangerColor
+		ms.Retry = &interact.Retry
 	}
 	return ms
 }
 
 // Layout the message.
 func (c RowStyle) Layout(gtx C) D {
-	return c.Row.Layout(gtx,
-		layout2.ContentRow(c.UserInfoStyle.Layout),
-		layout2.FullRow(nil, c.layoutBubble, c.layoutTimeOrIcon),
-		layout2.UnifiedRow(c.layoutStatusMessage),
-	)
+	rows := []layout2.RowChild{layout2.ContentRow(c.UserInfoStyle.Layout)}
+	if c.Quote != nil {
+		rows = append(rows, layout2.ContentRow(c.Quote.Layout))
+	}
+	rows = append(rows, layout2.FullRow(nil, c.layoutBubble, c.layoutTimeOrIcon))
+	if len(c.Reactions) > 0 {
+		rows = append(rows, layout2.ContentRow(c.layoutReactions))
+	}
+	rows = append(rows, layout2.UnifiedRow(c.layoutStatusMessage))
+	return c.Row.Layout(gtx, rows...)
 }
 
 // layoutBubble lays out the chat bubble.
 func (c RowStyle) layoutBubble(gtx C) D {
 	return layout.Stack{}.Layout(gtx,
 		layout.Stacked(func(gtx C) D {
+			switch {
+			case c.Voice != nil:
+				return c.layoutCard(gtx, c.Voice.Layout)
+			case c.File != nil:
+				return c.layoutCard(gtx, c.File.Layout)
+			}
 			return c.MessageStyle.Layout(gtx)
 		}),
 		layout.Expanded(func(gtx C) D {
@@ -115,21 +224,52 @@ func (c RowStyle) layoutBubble(gtx C) D {
 	)
 }
 
+// layoutCard lays out the card presenting a file or voice message atop
+// the message surface.
+func (c RowStyle) layoutCard(gtx C, card layout.Widget) D {
+	surface := c.MessageStyle.BubbleStyle.Layout
+	if c.MessageStyle.NinePatch != nil {
+		surface = c.MessageStyle.NinePatch.Layout
+	}
+	return surface(gtx, card)
+}
+
 // layoutTimeOrIcon lays out a status icon if one is set, and
-// otherwise lays out the time the messages was sent.
+// otherwise lays out the time the messages was sent, followed by the
+// progress icon if one is set.
 func (c RowStyle) layoutTimeOrIcon(gtx C) D {
 	return layout.Center.Layout(gtx, func(gtx C) D {
 		if c.StatusIcon == nil {
-			return c.Time.Layout(gtx)
+			if c.ProgressIcon == nil {
+				return c.Time.Layout(gtx)
+			}
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(c.Time.Layout),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(2)}.Layout),
+				layout.Rigid(func(gtx C) D {
+					return layoutIcon(gtx, c.ProgressIcon, c.ProgressIconColor, c.ProgressIconSize)
+				}),
+			)
+		}
+		icon := func(gtx C) D {
+			return layoutIcon(gtx, c.StatusIcon, c.StatusIconColor, c.IconSize)
 		}
-		sideLength := gtx.Dp(c.IconSize)
-		gtx.Constraints.Max.X = sideLength
-		gtx.Constraints.Max.Y = sideLength
-		gtx.Constraints.Min = gtx.Constraints.Constrain(gtx.Constraints.Min)
-		return c.StatusIcon.Layout(gtx, c.StatusIconColor)
+		if c.Retry != nil {
+			return c.Retry.Layout(gtx, icon)
+		}
+		return icon(gtx)
 	})
 }
 
+// layoutIcon lays out a square icon of the given size.
+func layoutIcon(gtx C, icon *widget.Icon, col color.NRGBA, size unit.Dp) D {
+	sideLength := gtx.Dp(size)
+	gtx.Constraints.Max.X = sideLength
+	gtx.Constraints.Max.Y = sideLength
+	gtx.Constraints.Min = gtx.Constraints.Constrain(gtx.Constraints.Min)
+	return icon.Layout(gtx, col)
+}
+
 // layoutStatusMessage lays out status message text, if any.
 func (c RowStyle) layoutStatusMessage(gtx C) D {
 	if c.StatusMessage.Text == "" {
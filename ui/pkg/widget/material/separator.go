@@ -2,6 +2,7 @@ package material
 
 import (
 	"image"
+	"image/color"
 	"time"
 
 	"gioui.org/layout"
@@ -9,6 +10,9 @@ import (
 	"gioui.org/op/paint"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
+	"gioui.org/x/component"
+
+	chatlayout "wechat_ui/ui/pkg/layout"
 )
 
 // SeparatorStyle configures the presentation of the unread indicator.
@@ -64,3 +68,37 @@ func (u SeparatorStyle) Layout(gtx layout.Context) layout.Dimensions {
 		layout.Flexed(.5, layoutLine),
 	)
 }
+
+// NoticeStyle configures the presentation of a notice in place of a message,
+// such as one recalled by its sender.
+type NoticeStyle struct {
+	Message    material.LabelStyle
+	Background color.NRGBA
+	Padding    layout.Inset
+	Margin     layout.Inset
+}
+
+// Notice fills in a NoticeStyle with sensible defaults.
+func Notice(th *material.Theme, text string) NoticeStyle {
+	n := NoticeStyle{
+		Message:    material.Caption(th, text),
+		Background: component.WithAlpha(th.Fg, 15),
+		Padding:    layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(8), Right: unit.Dp(8)},
+		Margin:     layout.UniformInset(unit.Dp(8)),
+	}
+	n.Message.Color = component.WithAlpha(th.Fg, 150)
+	return n
+}
+
+// Layout the notice, centered.
+func (n NoticeStyle) Layout(gtx layout.Context) layout.Dimensions {
+	return n.Margin.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return chatlayout.Rounded(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return chatlayout.Background(n.Background).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return n.Padding.Layout(gtx, n.Message.Layout)
+				})
+			})
+		})
+	})
+}
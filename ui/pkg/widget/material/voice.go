@@ -0,0 +1,137 @@
+package material
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+	chatwidget "wechat_ui/ui/pkg/widget"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/component"
+	"golang.org/x/exp/shiny/materialdesign/icons"
+)
+
+var (
+	// PlayIcon and PauseIcon are the material design playback controls.
+	PlayIcon  *widget.Icon = mustIcon(icons.AVPlayArrow)
+	PauseIcon *widget.Icon = mustIcon(icons.AVPause)
+)
+
+// VoiceConfig describes a voice message and its playback.
+type VoiceConfig struct {
+	Duration time.Duration
+	// Waveform holds the levels of the recording, from 0 for silence to
+	// 255 for the loudest.
+	Waveform []uint8
+	// Playing reports whether the message is being played.
+	Playing bool
+	// Position is the offset played up to in the message.
+	Position time.Duration
+}
+
+// VoiceStyle configures the presentation of a voice message: a button
+// playing and pausing it, the bars of its waveform, filled up to the
+// position played, and its duration, or the position while it is played.
+// Dragging across the waveform seeks within the message.
+type VoiceStyle struct {
+	State *chatwidget.Voice
+	// Button plays and pauses the message.
+	Button material.IconButtonStyle
+	// Waveform holds the levels of the bars.
+	Waveform []uint8
+	// Color of the bars not played yet, and Played of those played.
+	Color, Played color.NRGBA
+	// BarWidth and BarGap size and space the bars, which are at most
+	// BarHeight high.
+	BarWidth, BarGap, BarHeight unit.Dp
+	// Time presents the duration, or the position while playing.
+	Time material.LabelStyle
+	// Padding separates the contents from the edges of the bubble.
+	Padding layout.Inset
+}
+
+// Voice constructs a VoiceStyle with sensible defaults. Unless the
+// waveform is being scrubbed, its value is set to the position played.
+func Voice(th *material.Theme, state *chatwidget.Voice, v VoiceConfig) VoiceStyle {
+	icon, description := PlayIcon, "Play"
+	if v.Playing {
+		icon, description = PauseIcon, "Pause"
+	}
+	if !state.Scrubbing() {
+		state.Scrub.Value = 0
+		if v.Duration > 0 {
+			state.Scrub.Value = float32(v.Position) / float32(v.Duration)
+		}
+	}
+	label := v.Duration
+	if v.Playing || v.Position > 0 && v.Position < v.Duration {
+		label = v.Position
+	}
+	vs := VoiceStyle{
+		State:     state,
+		Button:    material.IconButton(th, &state.Play, icon, description),
+		Waveform:  v.Waveform,
+		Color:     component.WithAlpha(th.Fg, 90),
+		Played:    th.ContrastBg,
+		BarWidth:  unit.Dp(3),
+		BarGap:    unit.Dp(2),
+		BarHeight: unit.Dp(24),
+		Time:      material.Caption(th, FormatDuration(label)),
+		Padding:   layout.UniformInset(unit.Dp(8)),
+	}
+	vs.Button.Size = unit.Dp(18)
+	vs.Button.Inset = layout.UniformInset(unit.Dp(6))
+	return vs
+}
+
+// Layout the voice message.
+func (v VoiceStyle) Layout(gtx C) D {
+	return v.Padding.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(v.Button.Layout),
+			layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+			layout.Rigid(v.layoutWaveform),
+			layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+			layout.Rigid(v.Time.Layout),
+		)
+	})
+}
+
+// layoutWaveform lays out the bars of the waveform, beneath the area
+// tracking drags across them.
+func (v VoiceStyle) layoutWaveform(gtx C) D {
+	width, gap, height := gtx.Dp(v.BarWidth), gtx.Dp(v.BarGap), gtx.Dp(v.BarHeight)
+	size := image.Pt(len(v.Waveform)*(width+gap)-gap, height)
+	if size.X < 0 {
+		size.X = 0
+	}
+	played := int(v.State.Scrub.Value * float32(size.X))
+	for ii, level := range v.Waveform {
+		h := int(level) * height / 255
+		if h < width {
+			h = width
+		}
+		bar := image.Rect(ii*(width+gap), (height-h)/2, ii*(width+gap)+width, (height+h)/2)
+		col := v.Color
+		if bar.Min.X < played {
+			col = v.Played
+		}
+		paint.FillShape(gtx.Ops, col, clip.UniformRRect(bar, width/2).Op(gtx.Ops))
+	}
+	gtx.Constraints.Min = size
+	v.State.Scrub.Layout(gtx, layout.Horizontal, 0, 1, false, 0)
+	return D{Size: size}
+}
+
+// FormatDuration formats a duration as minutes and seconds, such as
+// "1:05".
+func FormatDuration(d time.Duration) string {
+	s := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -1,11 +1,28 @@
 package widget
 
-import "gioui.org/x/component"
+import (
+	"gioui.org/widget"
+	"gioui.org/x/component"
+)
 
 // Row holds persistent state for a single row of a chat.
 type Row struct {
 	// ContextArea holds the clicks state for the right-click context menu.
 	component.ContextArea
+	// Retry tracks clicks on the status icon of a message that failed to
+	// send.
+	Retry widget.Clickable
+	// Quote tracks clicks on the preview of the message a reply refers to.
+	Quote widget.Clickable
+	// Open tracks clicks on the open action of a file message.
+	Open widget.Clickable
+	// Voice holds the playback state of a voice message.
+	Voice Voice
+	// Select tracks clicks on the row while selecting messages.
+	Select widget.Clickable
+	// Reactions track clicks on the reactions to the message, in display
+	// order.
+	Reactions []widget.Clickable
 
 	Message
 	UserInfo
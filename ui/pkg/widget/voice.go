@@ -0,0 +1,36 @@
+package widget
+
+import (
+	"gioui.org/widget"
+)
+
+// Voice holds the state necessary to facilitate user interactions with
+// voice messages across frames.
+type Voice struct {
+	// Play tracks clicks on the button playing and pausing the message.
+	Play widget.Clickable
+	// Scrub tracks drags across the waveform of the message, to seek
+	// within it. Its value is the position played, from 0 to 1.
+	Scrub widget.Float
+	// scrubbed reports that Scrub changed since the last call to Seeked.
+	scrubbed bool
+}
+
+// Seeked returns the position the waveform was scrubbed to, from 0 to 1,
+// once the drag is released.
+func (v *Voice) Seeked() (float32, bool) {
+	if v.Scrub.Changed() {
+		v.scrubbed = true
+	}
+	if !v.scrubbed || v.Scrub.Dragging() {
+		return 0, false
+	}
+	v.scrubbed = false
+	return v.Scrub.Value, true
+}
+
+// Scrubbing reports whether the waveform is being scrubbed, during which
+// the value of Scrub follows the drag rather than playback.
+func (v *Voice) Scrubbing() bool {
+	return v.scrubbed || v.Scrub.Dragging()
+}
@@ -0,0 +1,41 @@
+package theme
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// querySystem reports whether the system prefers dark, asking gsettings on
+// Linux, the user defaults on macOS and the registry on Windows.
+func querySystem() (bool, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// The key is only set in dark mode.
+		out, err := exec.Command("defaults", "read", "-g", "AppleInterfaceStyle").Output()
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading appearance: %w", err)
+		}
+		return bytes.Contains(out, []byte("Dark")), nil
+	case "windows":
+		out, err := exec.Command("reg", "query",
+			`HKCU\Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`,
+			"/v", "AppsUseLightTheme").Output()
+		if err != nil {
+			return false, fmt.Errorf("reading appearance: %w", err)
+		}
+		return bytes.Contains(out, []byte("0x0")), nil
+	default:
+		out, err := exec.Command("gsettings", "get", "org.gnome.desktop.interface", "color-scheme").Output()
+		if err != nil {
+			return false, fmt.Errorf("reading appearance: %w", err)
+		}
+		return bytes.Contains(out, []byte("prefer-dark")), nil
+	}
+}
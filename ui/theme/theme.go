@@ -0,0 +1,161 @@
+/*
+Package theme is the single source of the palette the whole application is
+presented with, so that every page and widget can switch between light and
+dark at runtime.
+
+Pages and widgets read the palette with Current while laying out, and the
+window applies it to assets.Theme with Apply before every frame. Gio does
+not report the light or dark preference of the system, so the System mode
+queries it from the tools of each platform, and polls for changes.
+*/
+package theme
+
+import (
+	"log"
+	"sync"
+	"time"
+	"wechat_ui/ui/values"
+
+	"gioui.org/widget/material"
+)
+
+// Mode enumerates how the palette is chosen.
+type Mode int
+
+const (
+	// Light always presents the light palette.
+	Light Mode = iota
+	// Dark always presents the dark palette.
+	Dark
+	// System follows the preference of the system, light if unknown.
+	System
+)
+
+// SystemPoll is how often the preference of the system is queried while
+// following it.
+var SystemPoll = 5 * time.Second
+
+// Service chooses the palette the application is presented with. It is
+// safe for concurrent use.
+type Service struct {
+	// LightPalette and DarkPalette are the palettes presented in light and
+	// dark mode.
+	LightPalette, DarkPalette values.Palette
+	// QuerySystem reports whether the system prefers dark. Defaults to
+	// querying the tools of the platform.
+	QuerySystem func() (bool, error)
+	// Invalidate, if set, is called when the preference of the system
+	// changes, to present the new palette.
+	Invalidate func()
+
+	mu   sync.Mutex
+	mode Mode
+	// systemDark is the latest preference of the system, while following
+	// it.
+	systemDark bool
+	// stop stops polling the system, nil when not following it.
+	stop chan struct{}
+}
+
+// Default is the service of the application.
+var Default = &Service{
+	LightPalette: values.LightPalette,
+	DarkPalette:  values.DarkPalette,
+}
+
+// Current returns the palette of Default.
+func Current() values.Palette {
+	return Default.Palette()
+}
+
+// IsDark reports whether Default presents the dark palette.
+func IsDark() bool {
+	return Default.IsDark()
+}
+
+// SetMode changes the mode of Default.
+func SetMode(m Mode) {
+	Default.SetMode(m)
+}
+
+// Apply colors th with the palette of Default.
+func Apply(th *material.Theme) {
+	Default.Apply(th)
+}
+
+// Mode returns how the palette is chosen.
+func (s *Service) Mode() Mode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mode
+}
+
+// SetMode changes how the palette is chosen. Following the system starts
+// polling its preference, until the mode changes again.
+func (s *Service) SetMode(m Mode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m == s.mode {
+		return
+	}
+	s.mode = m
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if m == System {
+		s.stop = make(chan struct{})
+		go s.follow(s.stop)
+	}
+}
+
+// IsDark reports whether the dark palette is presented.
+func (s *Service) IsDark() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mode == Dark || s.mode == System && s.systemDark
+}
+
+// Palette returns the palette presented.
+func (s *Service) Palette() values.Palette {
+	if s.IsDark() {
+		return s.DarkPalette
+	}
+	return s.LightPalette
+}
+
+// Apply colors the text and background of th with the palette presented.
+func (s *Service) Apply(th *material.Theme) {
+	p := s.Palette()
+	th.Fg, th.Bg = p.Text, p.Bg
+}
+
+// follow polls the preference of the system until stop is closed.
+func (s *Service) follow(stop chan struct{}) {
+	query := s.QuerySystem
+	if query == nil {
+		query = querySystem
+	}
+	ticker := time.NewTicker(SystemPoll)
+	defer ticker.Stop()
+	for {
+		dark, err := query()
+		if err != nil {
+			log.Printf("querying system appearance: %v", err)
+		}
+		s.mu.Lock()
+		changed := s.stop == stop && dark != s.systemDark
+		if changed {
+			s.systemDark = dark
+		}
+		s.mu.Unlock()
+		if changed && s.Invalidate != nil {
+			s.Invalidate()
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
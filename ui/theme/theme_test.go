@@ -0,0 +1,68 @@
+package theme
+
+import (
+	"testing"
+	"time"
+	"wechat_ui/ui/values"
+
+	"gioui.org/widget/material"
+)
+
+func TestModes(t *testing.T) {
+	s := &Service{LightPalette: values.LightPalette, DarkPalette: values.DarkPalette}
+	if s.IsDark() || s.Palette() != values.LightPalette {
+		t.Errorf("expected light by default")
+	}
+	s.SetMode(Dark)
+	if !s.IsDark() || s.Palette() != values.DarkPalette {
+		t.Errorf("expected dark")
+	}
+	var th material.Theme
+	s.Apply(&th)
+	if th.Fg != values.DarkPalette.Text || th.Bg != values.DarkPalette.Bg {
+		t.Errorf("expected the dark palette applied, got fg %v bg %v", th.Fg, th.Bg)
+	}
+	s.SetMode(Light)
+	if s.IsDark() {
+		t.Errorf("expected light")
+	}
+}
+
+func TestSystem(t *testing.T) {
+	defer func(poll time.Duration) { SystemPoll = poll }(SystemPoll)
+	SystemPoll = time.Millisecond
+	dark := make(chan bool, 1)
+	dark <- true
+	var prefers bool
+	invalidated := make(chan struct{}, 10)
+	s := &Service{
+		QuerySystem: func() (bool, error) {
+			select {
+			case prefers = <-dark:
+			default:
+			}
+			return prefers, nil
+		},
+		Invalidate: func() { invalidated <- struct{}{} },
+	}
+	s.SetMode(System)
+	defer s.SetMode(Light)
+	wait := func(want bool) {
+		t.Helper()
+		select {
+		case <-invalidated:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the change of the system to invalidate")
+		}
+		if s.IsDark() != want {
+			t.Errorf("expected dark %v", want)
+		}
+	}
+	wait(true)
+	dark <- false
+	wait(false)
+	s.SetMode(Dark)
+	if !s.IsDark() {
+		t.Errorf("expected dark regardless of the system")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/values"
 
 	"gioui.org/io/semantic"
@@ -23,7 +24,10 @@ type Button struct {
 	isEnabled          bool
 	disabledBackground color.NRGBA
 	disabledTextColor  color.NRGBA
-	HighlightColor     color.NRGBA
+	// HighlightColor 点击时的水波纹颜色, 为零值时使用当前调色板的 Highlight.
+	HighlightColor color.NRGBA
+	// outline 描边按钮禁用时保持透明背景.
+	outline bool
 
 	Margin layout.Inset
 }
@@ -72,10 +76,9 @@ func NewButton(txt string) Button {
 func NewOutlineButton(txt string) Button {
 	btn := NewButton(txt)
 	btn.Background = color.NRGBA{}
-	btn.HighlightColor = values.SurfaceHighlight
+	btn.HighlightColor = color.NRGBA{}
 	btn.Color = values.Primary
-	btn.disabledBackground = color.NRGBA{}
-	btn.disabledTextColor = values.Gray3
+	btn.outline = true
 
 	return btn
 }
@@ -119,8 +122,14 @@ func (b *Button) SetEnabled(enabled bool) {
 }
 
 func (b *Button) setDisabledColors() {
-	b.disabledBackground = values.Gray3
-	b.disabledTextColor = values.Surface
+	p := theme.Current()
+	if b.outline {
+		b.disabledBackground = color.NRGBA{}
+		b.disabledTextColor = p.Disabled
+		return
+	}
+	b.disabledBackground = p.Disabled
+	b.disabledTextColor = p.OnDisabled
 }
 
 func (b *Button) Enabled() bool {
@@ -181,8 +190,12 @@ func (b Button) buttonStyleLayout(gtx layout.Context, w layout.Widget) layout.Di
 			}
 
 			paint.Fill(gtx.Ops, background)
+			highlight := b.HighlightColor
+			if highlight == (color.NRGBA{}) {
+				highlight = theme.Current().Highlight
+			}
 			for _, c := range b.clickable.History() {
-				drawInk(gtx, c, b.HighlightColor)
+				drawInk(gtx, c, highlight)
 			}
 
 			return layout.Dimensions{Size: gtx.Constraints.Min}
@@ -208,7 +221,7 @@ func (bl ButtonLayout) Layout(gtx layout.Context, w layout.Widget) layout.Dimens
 	return bl.ButtonLayoutStyle.Layout(gtx, w)
 }
 
-func (ib IconButton) ChangeColorStyle(colorStyle *values.ColorStyle) {
+func (ib *IconButton) ChangeColorStyle(colorStyle *values.ColorStyle) {
 	ib.colorStyle = colorStyle
 }
 
@@ -225,9 +238,10 @@ func (ib IconButton) Layout(gtx layout.Context) layout.Dimensions {
 }
 
 type TextAndIconButton struct {
-	Button          *widget.Clickable
-	icon            *Icon
-	text            string
+	Button *widget.Clickable
+	icon   *Icon
+	text   string
+	// Color 文字和图标的颜色, 为零值时使用当前调色板的 Surface.
 	Color           color.NRGBA
 	BackgroundColor color.NRGBA
 }
@@ -237,7 +251,6 @@ func NewTextAndIconButton(text string, icon *widget.Icon) TextAndIconButton {
 		Button:          new(widget.Clickable),
 		icon:            NewIcon(icon),
 		text:            text,
-		Color:           values.Surface,
 		BackgroundColor: values.Primary,
 	}
 }
@@ -245,6 +258,10 @@ func NewTextAndIconButton(text string, icon *widget.Icon) TextAndIconButton {
 func (b TextAndIconButton) Layout(gtx layout.Context) layout.Dimensions {
 	btnLayout := material.ButtonLayout(assets.Theme, b.Button)
 	btnLayout.Background = b.BackgroundColor
+	fg := b.Color
+	if fg == (color.NRGBA{}) {
+		fg = theme.Current().Surface
+	}
 
 	return btnLayout.Layout(gtx, func(gtx C) D {
 		return layout.UniformInset(unit.Dp(0)).Layout(gtx, func(gtx C) D {
@@ -255,7 +272,7 @@ func (b TextAndIconButton) Layout(gtx layout.Context) layout.Dimensions {
 				return layout.Inset{Left: textIconSpacer}.Layout(gtx, func(gtx C) D {
 					var d D
 					size := gtx.Dp(unit.Dp(46)) - 2*gtx.Dp(unit.Dp(16))
-					b.icon.Color = b.Color
+					b.icon.Color = fg
 					b.icon.Layout(gtx, unit.Dp(14))
 					d = layout.Dimensions{
 						Size: image.Point{X: size, Y: size},
@@ -267,7 +284,7 @@ func (b TextAndIconButton) Layout(gtx layout.Context) layout.Dimensions {
 			layLabel := layout.Rigid(func(gtx C) D {
 				return layout.Inset{Left: textIconSpacer}.Layout(gtx, func(gtx C) D {
 					l := material.Label(assets.Theme, unit.Sp(14), b.text)
-					l.Color = b.Color
+					l.Color = fg
 					return l.Layout(gtx)
 				})
 			})
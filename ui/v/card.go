@@ -3,7 +3,7 @@ package v
 import (
 	"image"
 	"image/color"
-	"wechat_ui/ui/values"
+	"wechat_ui/ui/theme"
 
 	"gioui.org/layout"
 	"gioui.org/op/clip"
@@ -13,6 +13,7 @@ import (
 
 type Card struct {
 	layout.Inset
+	// Color 卡片的背景色, 为零值时使用当前调色板的 Surface.
 	Color      color.NRGBA
 	HoverColor color.NRGBA
 	Radius     CornerRadius
@@ -54,12 +55,18 @@ const (
 
 func NewCard() Card {
 	return Card{
-		Color:      values.Surface,
-		HoverColor: values.Gray4,
-		Radius:     Radius(defaultRadius),
+		Radius: Radius(defaultRadius),
 	}
 }
 
+// color 返回卡片的背景色.
+func (c Card) color() color.NRGBA {
+	if c.Color == (color.NRGBA{}) {
+		return theme.Current().Surface
+	}
+	return c.Color
+}
+
 func (c Card) Layout(gtx layout.Context, w layout.Widget) layout.Dimensions {
 	dims := c.Inset.Layout(gtx, func(gtx C) D {
 		return layout.Stack{}.Layout(gtx,
@@ -75,7 +82,7 @@ func (c Card) Layout(gtx layout.Context, w layout.Widget) layout.Dimensions {
 					}},
 					NW: tl, NE: tr, SE: br, SW: bl,
 				}.Push(gtx.Ops).Pop()
-				return fill(gtx, c.Color)
+				return fill(gtx, c.color())
 			}),
 			layout.Stacked(w),
 		)
@@ -85,7 +92,7 @@ func (c Card) Layout(gtx layout.Context, w layout.Widget) layout.Dimensions {
 }
 
 func (c Card) HoverLayout(gtx layout.Context, btn *Clickable, w layout.Widget) layout.Dimensions {
-	background := c.Color
+	background := c.color()
 	dims := c.Inset.Layout(gtx, func(gtx C) D {
 		return layout.Stack{}.Layout(gtx,
 			layout.Expanded(func(gtx C) D {
@@ -102,7 +109,7 @@ func (c Card) HoverLayout(gtx layout.Context, btn *Clickable, w layout.Widget) l
 				}.Push(gtx.Ops).Pop()
 
 				if btn.Hover && btn.Button.Hovered() {
-					background = btn.style.HoverColor
+					background = btn.Style().HoverColor
 				}
 
 				return fill(gtx, background)
@@ -2,6 +2,7 @@ package v
 
 import (
 	"image"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/values"
 
 	"gioui.org/layout"
@@ -22,13 +23,16 @@ type Clickable struct {
 func NewClickable(hover bool) *Clickable {
 	return &Clickable{
 		Button:    &widget.Clickable{},
-		style:     &values.ClickableStyle{Color: values.SurfaceHighlight, HoverColor: values.Gray5},
 		Hover:     hover,
 		isEnabled: true,
 	}
 }
 
+// Style 返回点击效果的颜色. 没有通过 ChangeStyle 设置时, 颜色随当前调色板变化.
 func (cl *Clickable) Style() values.ClickableStyle {
+	if cl.style == nil {
+		return values.ClickableStyle{Color: theme.Current().Highlight, HoverColor: theme.Current().Hover}
+	}
 	return *cl.style
 }
 
@@ -61,6 +65,7 @@ func (cl *Clickable) Enabled() bool {
 }
 
 func (cl *Clickable) Layout(gtx C, w layout.Widget) D {
+	style := cl.Style()
 	return cl.Button.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		return layout.Stack{}.Layout(gtx,
 			layout.Expanded(func(gtx layout.Context) layout.Dimensions {
@@ -78,11 +83,11 @@ func (cl *Clickable) Layout(gtx C, w layout.Widget) D {
 				clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
 
 				if cl.Hover && cl.Button.Hovered() {
-					paint.Fill(gtx.Ops, cl.style.HoverColor)
+					paint.Fill(gtx.Ops, style.HoverColor)
 				}
 
 				for _, c := range cl.Button.History() {
-					drawInk(gtx, c, cl.style.Color)
+					drawInk(gtx, c, style.Color)
 				}
 				return layout.Dimensions{Size: gtx.Constraints.Min}
 			}),
@@ -1,9 +1,12 @@
 package v
 
 import (
+	"image/color"
+
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/values"
 )
 
@@ -57,7 +60,16 @@ func NewLabel(size unit.Sp, txt string) Label {
 	return labelWithDefaultColor(Label{material.Label(assets.Theme, size, txt)})
 }
 
+// labelWithDefaultColor 清除标签的颜色, 使其以当前调色板的文字颜色绘制.
 func labelWithDefaultColor(l Label) Label {
-	l.Color = values.DeepBlue
+	l.Color = color.NRGBA{}
 	return l
 }
+
+// Layout 绘制标签. 没有设置颜色时使用当前调色板的文字颜色.
+func (l Label) Layout(gtx C) D {
+	if l.Color == (color.NRGBA{}) {
+		l.Color = theme.Current().Text
+	}
+	return l.LabelStyle.Layout(gtx)
+}
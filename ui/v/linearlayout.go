@@ -66,12 +66,12 @@ func (ll LinearLayout) Layout(gtx C, children ...layout.FlexChild) D {
 						}
 
 						if ll.Clickable.Hover && ll.Clickable.Button.Hovered() {
-							background = ll.Clickable.style.HoverColor
+							background = ll.Clickable.Style().HoverColor
 						}
 						fill(gtx, background)
 
 						for _, c := range ll.Clickable.Button.History() {
-							drawInk(gtx, c, ll.Clickable.style.Color)
+							drawInk(gtx, c, ll.Clickable.Style().Color)
 						}
 
 						return ll.Clickable.Button.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
@@ -157,7 +157,7 @@ func (ll LinearLayout) GradientLayout(gtx C, children ...layout.FlexChild) D {
 						}
 
 						for _, c := range ll.Clickable.Button.History() {
-							drawInk(gtx, c, ll.Clickable.style.Color)
+							drawInk(gtx, c, ll.Clickable.Style().Color)
 						}
 
 						return ll.Clickable.Button.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
@@ -3,7 +3,6 @@ package v
 import (
 	"image"
 	"image/color"
-	"wechat_ui/ui/values"
 
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -13,14 +12,12 @@ import (
 )
 
 type Shadow struct {
-	surface         color.NRGBA
 	shadowElevation float32
 	shadowRadius    float32
 }
 
 func NewShadow() *Shadow {
 	return &Shadow{
-		surface:         values.Surface,
 		shadowRadius:    8, // raduis of the shadow
 		shadowElevation: 7, // height/spread of the shadow
 	}
@@ -0,0 +1,57 @@
+package values
+
+import "image/color"
+
+// Palette holds the colors of the application chrome that depend on
+// whether it is presented light or dark. Brand colors, such as Primary and
+// Danger, are shared by both.
+type Palette struct {
+	// Bg is the background of the window, and Surface the background of
+	// the cards, sheets and menus laid over it.
+	Bg, Surface color.NRGBA
+	// Hover is the background of hovered surfaces, and Highlight the ink
+	// drawn where they are clicked.
+	Hover, Highlight color.NRGBA
+	// Nav is the background of the navigation drawer.
+	Nav color.NRGBA
+	// Input is the background of text fields.
+	Input color.NRGBA
+	// Text is the color of text, and Hint the color of secondary text.
+	Text, Hint color.NRGBA
+	// Icon is the color of the icons of buttons without a background.
+	Icon color.NRGBA
+	// Disabled is the background of disabled buttons, and OnDisabled the
+	// color of their text.
+	Disabled, OnDisabled color.NRGBA
+}
+
+var (
+	// LightPalette presents dark text on light backgrounds.
+	LightPalette = Palette{
+		Bg:         Gray4,
+		Surface:    Surface,
+		Hover:      Gray5,
+		Highlight:  Gray2,
+		Nav:        DarkGray,
+		Input:      Gray1,
+		Text:       DeepBlue,
+		Hint:       GrayText3,
+		Icon:       Gray1,
+		Disabled:   Gray3,
+		OnDisabled: Surface,
+	}
+	// DarkPalette presents light text on dark backgrounds.
+	DarkPalette = Palette{
+		Bg:         rgb(0x191919),
+		Surface:    rgb(0x262626),
+		Hover:      rgb(0x333333),
+		Highlight:  rgb(0x404040),
+		Nav:        rgb(0x0F0F0F),
+		Input:      rgb(0x3A3A3A),
+		Text:       rgb(0xE6E6E6),
+		Hint:       rgb(0x8C8C8C),
+		Icon:       rgb(0x8C8C8C),
+		Disabled:   rgb(0x4D4D4D),
+		OnDisabled: rgb(0x999999),
+	}
+)
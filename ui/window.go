@@ -3,7 +3,9 @@ package ui
 import (
 	"wechat_ui/app"
 	"wechat_ui/ui/assets"
+	"wechat_ui/ui/components"
 	"wechat_ui/ui/page"
+	"wechat_ui/ui/theme"
 	"wechat_ui/ui/v"
 	"wechat_ui/ui/values"
 
@@ -17,16 +19,14 @@ import (
 type Window struct {
 	*giouiApp.Window
 	navigator app.WindowNavigator
+	// toasts 显示在所有页面和模态框之上的通知.
+	toasts *components.Toasts
 }
 
 type (
 	C = layout.Context
 	D = layout.Dimensions
 )
-type WriteClipboard struct {
-	Text string
-}
-
 func CreateWindow() (*Window, error) {
 	giouiWindow := giouiApp.NewWindow(giouiApp.MinSize(values.AppWidth, values.AppHeight),
 		giouiApp.Title("wechat"),
@@ -34,10 +34,12 @@ func CreateWindow() (*Window, error) {
 
 	// 全局
 	assets.Window = giouiWindow
+	theme.Default.Invalidate = giouiWindow.Invalidate
 
 	win := &Window{
 		Window:    giouiWindow,
 		navigator: app.NewSimpleWindowNavigator(giouiWindow.Invalidate),
+		toasts:    components.NewToasts(giouiWindow.Invalidate),
 	}
 
 	return win, nil
@@ -64,10 +66,13 @@ func (win *Window) HandleEvents() {
 
 // handleFrameEvent 处理事件
 func (win *Window) handleFrameEvent(evt system.FrameEvent) *op.Ops {
+	// 每帧都按照当前调色板设置全局主题, 以便运行时切换外观.
+	theme.Apply(assets.Theme)
+
 	switch {
 	case win.navigator.CurrentPage() == nil:
 		// 直接进入主页面.
-		win.navigator.Display(page.NewMainPage())
+		win.navigator.Display(page.NewMainPage(win.toasts))
 
 	default:
 		// 应用程序窗口可能已经接收到一些触发此 FrameEvent 的用户交互，例如按键、按钮单击等。
@@ -79,22 +84,34 @@ func (win *Window) handleFrameEvent(evt system.FrameEvent) *op.Ops {
 		}
 	}
 
-	// 将窗口的 UI 组件绘制到屏幕上
-	ops := win.prepareToDisplayUI(evt)
+	ops := &op.Ops{}
 
-	// 监听键盘事件
+	// 监听键盘事件. 必须在绘制 UI 组件之前添加, 这样才能收到页面中没有被其它组件处理的按键事件.
 	win.addKeyEventRequestsToOps(ops)
 
+	// 将窗口的 UI 组件绘制到屏幕上
+	win.prepareToDisplayUI(ops, evt)
+
 	return ops
 }
 
-// handleRelevantKeyPresses 检查任何打开的模态框或页面是否是 load.KeyEventHandler
+// handleRelevantKeyPresses 检查任何打开的模态框或页面是否是 app.KeyEventHandler
 // 以及提供的 system.FrameEvent 是否包含模式或页面的按键事件
 func (win *Window) handleRelevantKeyPresses(evt system.FrameEvent) {
 	handleKeyPressFor := func(tag string, maybeHandler interface{}) {
+		handler, isHandler := maybeHandler.(app.KeyEventHandler)
+		_, isModal := maybeHandler.(app.Modal)
 		for _, event := range evt.Queue.Events(tag) {
-			if keyEvent, isKeyEvent := event.(key.Event); isKeyEvent && keyEvent.State == key.Press {
-
+			keyEvent, isKeyEvent := event.(key.Event)
+			if !isKeyEvent || keyEvent.State != key.Press {
+				continue
+			}
+			// 第一个监听键盘事件的组件会收到所有未被处理的按键事件, 因此需要过滤掉不关心的按键.
+			if isHandler && handler.KeysToHandle().Contains(keyEvent.Name, keyEvent.Modifiers) {
+				handler.HandleKeyPress(&keyEvent)
+			} else if isModal && keyEvent.Name == key.NameEscape {
+				// 模态框没有处理 Esc 时, 默认关闭模态框.
+				win.navigator.DismissModal(tag)
 			}
 		}
 	}
@@ -106,9 +123,9 @@ func (win *Window) handleRelevantKeyPresses(evt system.FrameEvent) {
 	}
 }
 
-func (win *Window) prepareToDisplayUI(evt system.FrameEvent) *op.Ops {
+func (win *Window) prepareToDisplayUI(ops *op.Ops, evt system.FrameEvent) {
 	backgroundWidget := layout.Expanded(func(gtx C) D {
-		return v.Fill(gtx, values.Gray4)
+		return v.Fill(gtx, theme.Current().Bg)
 	})
 
 	currentPageWidget := layout.Stacked(func(gtx C) D {
@@ -126,17 +143,36 @@ func (win *Window) prepareToDisplayUI(evt system.FrameEvent) *op.Ops {
 		return modal.Layout(gtx)
 	})
 
-	ops := &op.Ops{}
+	toastsLayout := layout.Expanded(win.toasts.Layout)
+
 	gtx := layout.NewContext(ops, evt)
 	layout.Stack{Alignment: layout.N}.Layout(
 		gtx,
 		backgroundWidget,
 		currentPageWidget,
 		topModalLayout,
+		toastsLayout,
 	)
-
-	return ops
 }
 
+// addKeyEventRequestsToOps 为最顶层的模态框或者当前页面注册需要监听的按键.
+// 模态框总是监听 Esc 以便关闭.
 func (win *Window) addKeyEventRequestsToOps(ops *op.Ops) {
+	var tag string
+	var keys key.Set
+	if modal := win.navigator.TopModal(); modal != nil {
+		tag, keys = modal.ID(), key.NameEscape
+		if handler, ok := modal.(app.KeyEventHandler); ok {
+			keys = app.CombineKeys(keys, handler.KeysToHandle())
+		}
+	} else if page := win.navigator.CurrentPage(); page != nil {
+		tag = page.ID()
+		if handler, ok := page.(app.KeyEventHandler); ok {
+			keys = handler.KeysToHandle()
+		}
+	}
+	if keys == "" {
+		return
+	}
+	key.InputOp{Tag: tag, Keys: keys}.Add(ops)
 }